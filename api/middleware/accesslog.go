@@ -0,0 +1,124 @@
+// The `RequestID` and `AccessLog` functions give every request an ID and log one structured entry per
+// request once it is handled, with the caller, the route template, the status, the latency and, for
+// failed requests, the error returned to the caller.
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"project/logging"
+	"strings"
+	"time"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	// maxRequestIDLength bounds the IDs accepted from callers, longer ones are replaced
+	maxRequestIDLength = 128
+	// maxErrorDetail bounds the part of an error response copied to the log
+	maxErrorDetail = 512
+)
+
+// RequestID reuses the X-Request-ID header sent by the caller, or a proxy in front of the server, or
+// generates a new ID. The ID is echoed in the response and carried in the request context.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range requestID {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+// accessEntry collects what the inner middleware learn about the request, i.e. the subject set by
+// Authenticate.
+type accessEntry struct {
+	subject string
+}
+
+type accessEntryKey struct{}
+
+// setSubject records the caller in the access log entry of the request, if it is logged.
+func setSubject(ctx context.Context, subject string) {
+	if entry, ok := ctx.Value(accessEntryKey{}).(*accessEntry); ok {
+		entry.subject = subject
+	}
+}
+
+// accessRecorder remembers the status and size of the response, and the start of the body of error
+// responses.
+type accessRecorder struct {
+	statusRecorder
+	bytes  int
+	detail []byte
+}
+
+func (r *accessRecorder) Write(b []byte) (int, error) {
+	if r.status >= http.StatusBadRequest && len(r.detail) < maxErrorDetail {
+		r.detail = append(r.detail, b[:min(len(b), maxErrorDetail-len(r.detail))]...)
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// AccessLog logs every request at info level, requests failing with a 4xx status at warn level and
+// with a 5xx status at error level.
+func AccessLog(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			entry := &accessEntry{}
+			recorder := &accessRecorder{statusRecorder: statusRecorder{ResponseWriter: w, status: http.StatusOK}}
+			r = r.WithContext(context.WithValue(r.Context(), accessEntryKey{}, entry))
+			next.ServeHTTP(recorder, r)
+
+			level := slog.LevelInfo
+			switch {
+			case recorder.status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case recorder.status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", routeTemplate(r)),
+				slog.Int("status", recorder.status),
+				slog.Int("bytes", recorder.bytes),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("subject", entry.subject),
+				slog.String("remote_addr", r.RemoteAddr),
+			}
+			if len(recorder.detail) > 0 {
+				attrs = append(attrs, slog.String("error", strings.TrimSpace(string(recorder.detail))))
+			}
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
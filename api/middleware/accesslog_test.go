@@ -0,0 +1,72 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"project/api/middleware"
+	"project/auth"
+	"project/logging"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog(t *testing.T) {
+	var output bytes.Buffer
+	logger := slog.New(logging.NewHandler(&output, logging.FormatJSON, slog.LevelDebug))
+
+	router := mux.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(middleware.AccessLog(logger))
+	router.Use(middleware.Authenticate(&auth.TokenStore{}))
+	router.HandleFunc("/configs/{name}/{version}", func(w http.ResponseWriter, r *http.Request) {
+		logger.DebugContext(r.Context(), "repository call")
+		http.Error(w, "no configuration found", http.StatusNotFound)
+	}).Methods("GET")
+
+	// entries sends the request and returns the log entries it wrote
+	entries := func(request *http.Request) (*httptest.ResponseRecorder, []map[string]interface{}) {
+		output.Reset()
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		var logged []map[string]interface{}
+		decoder := json.NewDecoder(&output)
+		for decoder.More() {
+			var entry map[string]interface{}
+			require.NoError(t, decoder.Decode(&entry))
+			logged = append(logged, entry)
+		}
+		return response, logged
+	}
+
+	// The request ID of the caller is kept, echoed and shared by every entry of the request
+	request := httptest.NewRequest(http.MethodGet, "/configs/db/1.0", nil)
+	request.Header.Set(middleware.RequestIDHeader, "req-42")
+	response, logged := entries(request)
+	assert.Equal(t, "req-42", response.Header().Get(middleware.RequestIDHeader))
+	require.Len(t, logged, 2)
+	assert.Equal(t, "repository call", logged[0]["msg"])
+	assert.Equal(t, "req-42", logged[0]["request_id"])
+	access := logged[1]
+	assert.Equal(t, "req-42", access["request_id"])
+	assert.Equal(t, "WARN", access["level"])
+	assert.Equal(t, "/configs/{name}/{version}", access["route"])
+	assert.Equal(t, float64(http.StatusNotFound), access["status"])
+	assert.Equal(t, auth.Anonymous.Subject, access["subject"])
+	assert.Equal(t, "no configuration found", access["error"])
+	assert.Contains(t, access, "latency_ms")
+
+	// Invalid IDs are replaced by a generated one
+	request = httptest.NewRequest(http.MethodGet, "/configs/db/1.0", nil)
+	request.Header.Set(middleware.RequestIDHeader, "bad id\n")
+	response, logged = entries(request)
+	generated := response.Header().Get(middleware.RequestIDHeader)
+	assert.Len(t, generated, 32)
+	assert.Equal(t, generated, logged[1]["request_id"])
+}
@@ -0,0 +1,37 @@
+// The `Authenticate` function attaches the identity of the caller, found by its bearer token or its
+// verified client certificate, to the request context. Requests with neither are anonymous, requests
+// with an unknown token are rejected.
+package middleware
+
+import (
+	"net/http"
+	"project/auth"
+	"strings"
+)
+
+func Authenticate(tokens *auth.TokenStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				identity := auth.Anonymous
+				if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+					identity = tokens.LookupCertificate(r.TLS.VerifiedChains[0][0].Subject)
+					r = r.WithContext(auth.WithIdentity(r.Context(), identity))
+				}
+				setSubject(r.Context(), identity.Subject)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			identity, known := tokens.Lookup(token)
+			if !ok || !known {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			setSubject(r.Context(), identity.Subject)
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// The `Consistency` function selects the Consul read mode of a request and reports how current the
+// data of the response is, from the reads the request made.
+package middleware
+
+import (
+	"net/http"
+	"project/data"
+	"strconv"
+)
+
+const (
+	// ConsistencyHeader selects the read mode like the consistency query parameter, which takes
+	// precedence. The response echoes the mode used
+	ConsistencyHeader = "X-Config-Consistency"
+	// IndexHeader is the highest Consul index of the reads of the request
+	IndexHeader = "X-Config-Index"
+	// StalenessHeader is how stale the reads of the request may have been, in milliseconds
+	StalenessHeader = "X-Config-Staleness"
+)
+
+// readInfoWriter adds the headers describing the reads right before the response is started.
+type readInfoWriter struct {
+	http.ResponseWriter
+	consistency data.Consistency
+	info        *data.ReadInfo
+	written     bool
+}
+
+func (w *readInfoWriter) writeHeaders() {
+	if w.written {
+		return
+	}
+	w.written = true
+	header := w.Header()
+	header.Set(ConsistencyHeader, string(w.consistency))
+	if info := w.info.Snapshot(); info.Reads > 0 {
+		header.Set(IndexHeader, strconv.FormatUint(info.Index, 10))
+		header.Set(StalenessHeader, strconv.FormatInt(info.Staleness.Milliseconds(), 10))
+	}
+}
+
+func (w *readInfoWriter) WriteHeader(status int) {
+	w.writeHeaders()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *readInfoWriter) Write(b []byte) (int, error) {
+	w.writeHeaders()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *readInfoWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Consistency reads the read mode from the consistency query parameter or the X-Config-Consistency
+// header, answering 400 to an unknown one, and passes it to the store in the request context.
+func Consistency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.URL.Query().Get("consistency")
+		if value == "" {
+			value = r.Header.Get(ConsistencyHeader)
+		}
+		consistency, err := data.ParseConsistency(value)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		ctx, info := data.WithReadInfo(data.WithConsistency(r.Context(), consistency))
+		writer := &readInfoWriter{ResponseWriter: w, consistency: consistency, info: info}
+		next.ServeHTTP(writer, r.WithContext(ctx))
+		// A handler which wrote nothing still gets the headers with its empty response
+		writer.writeHeaders()
+	})
+}
@@ -0,0 +1,63 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"project/api/middleware"
+	"project/data"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsistency(t *testing.T) {
+	var seen data.Consistency
+	handler := middleware.Consistency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = data.ConsistencyFromContext(r.Context())
+	}))
+
+	// The query parameter takes precedence over the header
+	request := httptest.NewRequest(http.MethodGet, "/configs/db/1.0?consistency=stale", nil)
+	request.Header.Set(middleware.ConsistencyHeader, "consistent")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	assert.Equal(t, data.ConsistencyStale, seen)
+	assert.Equal(t, "stale", response.Header().Get(middleware.ConsistencyHeader))
+	// No reads, no index
+	assert.Empty(t, response.Header().Get(middleware.IndexHeader))
+
+	request = httptest.NewRequest(http.MethodGet, "/configs/db/1.0", nil)
+	request.Header.Set(middleware.ConsistencyHeader, "consistent")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	assert.Equal(t, data.ConsistencyConsistent, seen)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/configs/db/1.0", nil))
+	assert.Equal(t, data.ConsistencyDefault, seen)
+
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/configs/db/1.0?consistency=linearizable", nil))
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Equal(t, "application/problem+json", response.Header().Get("Content-Type"))
+}
+
+func TestConsistency_ReadInfo(t *testing.T) {
+	db, err := data.NewDatabase(data.Options{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Put(context.Background(), "consistency-test/a", "1"))
+
+	handler := middleware.Consistency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var value string
+		assert.NoError(t, db.Get(r.Context(), "consistency-test/a", &value))
+		w.Write([]byte(value))
+	}))
+	for _, consistency := range []string{"default", "consistent", "stale"} {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/?consistency="+consistency, nil))
+		assert.Equal(t, "1", response.Body.String())
+		assert.NotEmpty(t, response.Header().Get(middleware.IndexHeader), consistency)
+		assert.NotEqual(t, "0", response.Header().Get(middleware.IndexHeader), consistency)
+		assert.Equal(t, "0", response.Header().Get(middleware.StalenessHeader), consistency)
+	}
+}
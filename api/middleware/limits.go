@@ -0,0 +1,33 @@
+// The `MaxBytes` and `Timeout` functions bound the requests of a route: the size of the body the
+// handler can read and the time the handler is given before its context is cancelled, which aborts
+// its calls to the store.
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+func MaxBytes(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Timeout cancels the context of the request after the timeout, 0 leaves it unbounded.
+func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
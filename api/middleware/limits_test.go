@@ -0,0 +1,44 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"project/api/middleware"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxBytes(t *testing.T) {
+	handler := middleware.MaxBytes(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var tooLarge *http.MaxBytesError
+		if _, err := io.ReadAll(r.Body); errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+	}))
+
+	for body, status := range map[string]int{"small": http.StatusOK, "much too large": http.StatusRequestEntityTooLarge} {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/configs", strings.NewReader(body)))
+		assert.Equal(t, status, response.Code, body)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	handler := middleware.Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+	}))
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/configs/db/1.0", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, response.Code)
+}
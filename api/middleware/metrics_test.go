@@ -0,0 +1,46 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"project/api/middleware"
+	"project/metrics"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(middleware.Metrics)
+	router.Handle("/configs/{name}/{version}", middleware.NewRateLimiter("test", 1, 1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))).Methods("GET")
+
+	for _, path := range []string{"/configs/a/1", "/configs/b/2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Requests are labelled with the route template, so both share the same series
+	route := "/configs/{name}/{version}"
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(route, "GET", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(route, "GET", "429")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.RateLimited.WithLabelValues("test")))
+}
+
+func TestMetrics_Panic(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(middleware.Metrics)
+	router.Use(middleware.Recover)
+	router.HandleFunc("/config-groups", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}).Methods("POST")
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/config-groups", nil))
+
+	// The 500 written by Recover is counted
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/config-groups", "POST", "500")))
+}
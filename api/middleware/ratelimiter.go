@@ -1,21 +1,27 @@
-// The `RateLimiter` function implements rate limiting for HTTP requests using a token bucket
+// The `NewRateLimiter` function implements rate limiting for HTTP requests using a token bucket
 // algorithm.
 package middleware
 
 import (
 	"net/http"
+	"project/metrics"
 
 	"golang.org/x/time/rate"
 )
 
-var limiter = rate.NewLimiter(1, 5) // 1 request per second with a burst of 5 requests
-
-func RateLimiter(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !limiter.Allow() {
-			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+// NewRateLimiter returns a middleware sharing a single token bucket between every handler it wraps,
+// refilled at requestsPerSecond and holding at most burst requests. Rejected requests are counted under
+// the policy name.
+func NewRateLimiter(policy string, requestsPerSecond float64, burst int) func(http.Handler) http.Handler {
+	limiter := rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.Allow() {
+				metrics.RateLimited.WithLabelValues(policy).Inc()
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
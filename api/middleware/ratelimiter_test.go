@@ -1,4 +1,4 @@
-// The TestRateLimiter function tests the RateLimiter middleware by simulating multiple requests and
+// The TestRateLimiter function tests the NewRateLimiter middleware by simulating multiple requests and
 // checking the response status codes.
 package middleware_test
 
@@ -17,12 +17,13 @@ func TestRateLimiter(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	limiter := middleware.RateLimiter(handler)
+	// 1 request per second with a burst of 5 requests
+	limiter := middleware.NewRateLimiter("test", 1, 5)(handler)
 
 	// Create a new request
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 
-	// Call the RateLimiter middleware 6 times
+	// Call the rate limiter 6 times
 	for i := 0; i < 6; i++ {
 		// Create a new response recorder for each request
 		res := httptest.NewRecorder()
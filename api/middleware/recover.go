@@ -0,0 +1,78 @@
+// The `Recover` function turns a panic in a handler into a logged error and a 500 problem+json
+// response, instead of a connection dropped without a response.
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"project/logging"
+	"runtime/debug"
+)
+
+// problem is an RFC 9457 problem details response.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: logging.RequestID(r.Context()),
+	})
+}
+
+// writeTracker remembers whether the handler started the response.
+type writeTracker struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *writeTracker) WriteHeader(status int) {
+	w.started = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *writeTracker) Write(b []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *writeTracker) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracker := &writeTracker{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// ErrAbortHandler is the way to abort a response on purpose, the server handles it silently
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			slog.ErrorContext(r.Context(), "panic handling request", "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+			if tracker.started {
+				// Part of the response was sent, so it can only be cut short
+				panic(http.ErrAbortHandler)
+			}
+			writeProblem(w, r, http.StatusInternalServerError, "the server failed to handle the request")
+		}()
+		next.ServeHTTP(tracker, r)
+	})
+}
@@ -0,0 +1,31 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"project/api/middleware"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecover(t *testing.T) {
+	handler := middleware.RequestID(middleware.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var group map[string]string
+		group["configs"] = "nil map"
+	})))
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/config-groups", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.Equal(t, "application/problem+json", response.Header().Get("Content-Type"))
+	var problem map[string]interface{}
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &problem))
+	assert.Equal(t, float64(http.StatusInternalServerError), problem["status"])
+	assert.Equal(t, "/config-groups", problem["instance"])
+	assert.Equal(t, response.Header().Get(middleware.RequestIDHeader), problem["requestId"])
+}
@@ -0,0 +1,52 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"project/api/middleware"
+	"project/tracing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	router := mux.NewRouter()
+	router.Use(middleware.Tracing)
+	router.HandleFunc("/configs/{name}/{version}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.Start(r.Context(), "ConfigService.Get")
+		span.End()
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+
+	request := httptest.NewRequest(http.MethodGet, "/configs/a/1", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	child, server := spans[0], spans[1]
+
+	// The server span continues the trace of the caller and is named after the route template
+	assert.Equal(t, "GET /configs/{name}/{version}", server.Name())
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	assert.Contains(t, server.Attributes(), attribute.Int("http.response.status_code", http.StatusNotFound))
+
+	// Spans started by the handler are children of the server span
+	assert.Equal(t, server.SpanContext().SpanID(), child.Parent().SpanID())
+}
@@ -3,30 +3,88 @@
 package api
 
 import (
+	"log/slog"
 	"net/http"
 	"project/api/middleware"
+	"project/auth"
 	"project/handlers"
+	"project/metrics"
+	"project/settings"
 
 	"github.com/gorilla/mux"
 )
 
-func NewRouter(configHandler *handlers.ConfigHandler, configGroupHandler *handlers.ConfigGroupHandler) *mux.Router {
+func NewRouter(configHandler *handlers.ConfigHandler, configGroupHandler *handlers.ConfigGroupHandler, changeRequestHandler *handlers.ChangeRequestHandler, lockHandler *handlers.LockHandler, trashHandler *handlers.TrashHandler, applyHandler *handlers.ApplyHandler, adminHandler *handlers.AdminHandler, healthHandler *handlers.HealthHandler, tokens *auth.TokenStore, rateLimit settings.RateLimitSettings, routes map[string]settings.RouteLimits) *mux.Router {
 	router := mux.NewRouter()
+	policy := policies(rateLimit, routes)
+
+	// Identify, log, trace, count and time every request, recover from panics in its handler, identify
+	// its caller and select the read mode of the store. Recover runs inside Tracing and Metrics, so the
+	// 500 written for a panic is recorded like any other response
+	router.Use(middleware.RequestID)
+	router.Use(middleware.AccessLog(slog.Default()))
+	router.Use(middleware.Tracing)
+	router.Use(middleware.Metrics)
+	router.Use(middleware.Recover)
+	router.Use(middleware.Authenticate(tokens))
+	router.Use(middleware.Consistency)
 
 	// Registration of routes for ConfigHandler
-	router.Handle("/configs", middleware.RateLimiter(http.HandlerFunc(configHandler.Add))).Methods("POST")
-	router.Handle("/configs/{name}/{version}", middleware.RateLimiter(http.HandlerFunc(configHandler.Get))).Methods("GET")
-	router.Handle("/configs/{name}/{version}", middleware.RateLimiter(http.HandlerFunc(configHandler.Delete))).Methods("DELETE")
+	router.Handle("/configs", policy(settings.PolicyWrite)(http.HandlerFunc(configHandler.Add))).Methods("POST")
+	router.Handle("/configs/{name}/{version}", policy(settings.PolicyRead)(http.HandlerFunc(configHandler.Get))).Methods("GET")
+	router.Handle("/configs/{name}/{version}", policy(settings.PolicyWrite)(http.HandlerFunc(configHandler.Delete))).Methods("DELETE")
 
 	// Registration of routes for ConfigGroupHandler
-	router.Handle("/config-groups", middleware.RateLimiter(http.HandlerFunc(configGroupHandler.AddGroup))).Methods("POST")
-	router.Handle("/config-groups/{name}/{version}", middleware.RateLimiter(http.HandlerFunc(configGroupHandler.GetGroup))).Methods("GET")
-	router.Handle("/config-groups/{name}/{version}", middleware.RateLimiter(http.HandlerFunc(configGroupHandler.RemoveGroup))).Methods("DELETE")
-	router.Handle("/config-groups/{name}/{version}/{configName}/{configVersion}", middleware.RateLimiter(http.HandlerFunc(configGroupHandler.AddConfigToGroup))).Methods("POST")
-	router.Handle("/config-groups/{name}/{version}/configs/{labels}/{configName}/{configVersion}", middleware.RateLimiter(http.HandlerFunc(configGroupHandler.SearchConfigsWithLabelsInGroup))).Methods("GET")
-	router.Handle("/config-groups/{name}/{version}/configs", middleware.RateLimiter(http.HandlerFunc(configGroupHandler.AddConfigWithLabelToGroup))).Methods("POST")
-	router.Handle("/config-groups/{name}/{version}/configs/{labels}/{configName}/{configVersion}", middleware.RateLimiter(http.HandlerFunc(configGroupHandler.RemoveConfigsWithLabelsFromGroup))).Methods("DELETE")
-	router.Handle("/config-groups/{name}/{version}/configs/{configName}/{configVersion}", middleware.RateLimiter(http.HandlerFunc(configGroupHandler.RemoveConfigFromGroup))).Methods("DELETE")
+	router.Handle("/config-groups", policy(settings.PolicyWrite)(http.HandlerFunc(configGroupHandler.AddGroup))).Methods("POST")
+	router.Handle("/config-groups/{name}/{version}", policy(settings.PolicyRead)(http.HandlerFunc(configGroupHandler.GetGroup))).Methods("GET")
+	router.Handle("/config-groups/{name}/{version}", policy(settings.PolicyWrite)(http.HandlerFunc(configGroupHandler.RemoveGroup))).Methods("DELETE")
+	router.Handle("/config-groups/{name}/{version}/{configName}/{configVersion}", policy(settings.PolicyWrite)(http.HandlerFunc(configGroupHandler.AddConfigToGroup))).Methods("POST")
+	router.Handle("/config-groups/{name}/{version}/configs/{labels}/{configName}/{configVersion}", policy(settings.PolicyRead)(http.HandlerFunc(configGroupHandler.SearchConfigsWithLabelsInGroup))).Methods("GET")
+	router.Handle("/config-groups/{name}/{version}/configs", policy(settings.PolicyWrite)(http.HandlerFunc(configGroupHandler.AddConfigWithLabelToGroup))).Methods("POST")
+	router.Handle("/config-groups/{name}/{version}/configs/{labels}/{configName}/{configVersion}", policy(settings.PolicyWrite)(http.HandlerFunc(configGroupHandler.RemoveConfigsWithLabelsFromGroup))).Methods("DELETE")
+	router.Handle("/config-groups/{name}/{version}/configs/{configName}/{configVersion}", policy(settings.PolicyWrite)(http.HandlerFunc(configGroupHandler.RemoveConfigFromGroup))).Methods("DELETE")
+
+	// Registration of routes for ChangeRequestHandler
+	router.Handle("/change-requests", policy(settings.PolicyWrite)(http.HandlerFunc(changeRequestHandler.Create))).Methods("POST")
+	router.Handle("/change-requests", policy(settings.PolicyRead)(http.HandlerFunc(changeRequestHandler.List))).Methods("GET")
+	router.Handle("/change-requests/{id}", policy(settings.PolicyRead)(http.HandlerFunc(changeRequestHandler.Get))).Methods("GET")
+	router.Handle("/change-requests/{id}/approve", policy(settings.PolicyWrite)(http.HandlerFunc(changeRequestHandler.Approve))).Methods("POST")
+	router.Handle("/change-requests/{id}/reject", policy(settings.PolicyWrite)(http.HandlerFunc(changeRequestHandler.Reject))).Methods("POST")
+
+	// Registration of routes for LockHandler
+	router.Handle("/configs/{name}/{version}/lock", policy(settings.PolicyWrite)(http.HandlerFunc(lockHandler.LockConfig))).Methods("PUT")
+	router.Handle("/configs/{name}/{version}/lock", policy(settings.PolicyWrite)(http.HandlerFunc(lockHandler.UnlockConfig))).Methods("DELETE")
+	router.Handle("/configs/{name}/{version}/lock", policy(settings.PolicyRead)(http.HandlerFunc(lockHandler.GetConfigLock))).Methods("GET")
+	router.Handle("/config-groups/{name}/{version}/lock", policy(settings.PolicyWrite)(http.HandlerFunc(lockHandler.LockGroup))).Methods("PUT")
+	router.Handle("/config-groups/{name}/{version}/lock", policy(settings.PolicyWrite)(http.HandlerFunc(lockHandler.UnlockGroup))).Methods("DELETE")
+	router.Handle("/config-groups/{name}/{version}/lock", policy(settings.PolicyRead)(http.HandlerFunc(lockHandler.GetGroupLock))).Methods("GET")
+	router.Handle("/locks", policy(settings.PolicyRead)(http.HandlerFunc(lockHandler.List))).Methods("GET")
+
+	// Registration of routes for TrashHandler
+	router.Handle("/trash", policy(settings.PolicyRead)(http.HandlerFunc(trashHandler.List))).Methods("GET")
+	router.Handle("/trash/{id}/restore", policy(settings.PolicyWrite)(http.HandlerFunc(trashHandler.Restore))).Methods("POST")
+
+	// Registration of route for ApplyHandler
+	router.Handle("/apply", policy(settings.PolicyWrite)(http.HandlerFunc(applyHandler.Apply))).Methods("POST")
+
+	// Registration of routes for AdminHandler
+	router.Handle("/admin/export", policy(settings.PolicyAdmin)(http.HandlerFunc(adminHandler.Export))).Methods("GET")
+	router.Handle("/admin/import", policy(settings.PolicyAdmin)(http.HandlerFunc(adminHandler.Import))).Methods("POST")
+	router.Handle("/admin/reencryption", policy(settings.PolicyAdmin)(http.HandlerFunc(adminHandler.StartReencryption))).Methods("POST")
+	router.Handle("/admin/reencryption", policy(settings.PolicyAdmin)(http.HandlerFunc(adminHandler.GetReencryption))).Methods("GET")
+	router.Handle("/admin/migrations", policy(settings.PolicyAdmin)(http.HandlerFunc(adminHandler.RunMigrations))).Methods("POST")
+	router.Handle("/admin/migrations", policy(settings.PolicyAdmin)(http.HandlerFunc(adminHandler.GetMigrations))).Methods("GET")
+	router.Handle("/admin/fsck", policy(settings.PolicyAdmin)(http.HandlerFunc(adminHandler.Fsck))).Methods("GET")
+	router.Handle("/admin/fsck", policy(settings.PolicyAdmin)(http.HandlerFunc(adminHandler.RepairFsck))).Methods("POST")
+	router.Handle("/admin/schedule", policy(settings.PolicyAdmin)(http.HandlerFunc(adminHandler.GetSchedule))).Methods("GET")
+
+	// Registration of routes for HealthHandler, not rate limited so probes keep working under load
+	router.HandleFunc("/healthz", healthHandler.Healthz).Methods("GET")
+	router.HandleFunc("/readyz", healthHandler.Readyz).Methods("GET")
+	router.HandleFunc("/status", healthHandler.Status).Methods("GET")
+
+	// Registration of route for the Prometheus metrics
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
 
 	// Registration of route for serving the frontend
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -35,3 +93,42 @@ func NewRouter(configHandler *handlers.ConfigHandler, configGroupHandler *handle
 
 	return router
 }
+
+// policies returns the middleware of each policy: its rate limit, then its body size limit and its
+// handler timeout.
+func policies(rateLimit settings.RateLimitSettings, routes map[string]settings.RouteLimits) func(policy string) func(http.Handler) http.Handler {
+	limit := rateLimiters(rateLimit)
+	return func(policy string) func(http.Handler) http.Handler {
+		limits, ok := routes[policy]
+		if !ok {
+			limits = routes[settings.PolicyDefault]
+		}
+		return func(next http.Handler) http.Handler {
+			if limits.MaxBodyBytes > 0 {
+				next = middleware.MaxBytes(limits.MaxBodyBytes)(next)
+			}
+			return limit(policy)(middleware.Timeout(limits.Timeout)(next))
+		}
+	}
+}
+
+// rateLimiters returns the rate-limit middleware of each policy. Routes of the same policy share a
+// single token bucket, and policies which aren't configured share the bucket of the default policy.
+func rateLimiters(rateLimit settings.RateLimitSettings) func(policy string) func(http.Handler) http.Handler {
+	if !rateLimit.Enabled {
+		return func(string) func(http.Handler) http.Handler {
+			return func(next http.Handler) http.Handler { return next }
+		}
+	}
+
+	limiters := make(map[string]func(http.Handler) http.Handler, len(rateLimit.Policies))
+	for name, policy := range rateLimit.Policies {
+		limiters[name] = middleware.NewRateLimiter(name, policy.RequestsPerSecond, policy.Burst)
+	}
+	return func(policy string) func(http.Handler) http.Handler {
+		if limiter, ok := limiters[policy]; ok {
+			return limiter
+		}
+		return limiters[settings.PolicyDefault]
+	}
+}
@@ -1,26 +1,59 @@
-// The `RunServer` function starts an HTTP server with a given router and handles graceful shutdown on
-// receiving SIGINT or SIGTERM signals.
+// The `RunServer` function starts an HTTP or HTTPS server with a given router and handles graceful
+// shutdown on receiving SIGINT or SIGTERM signals. beforeShutdown is called as soon as the signal is
+// received, e.g. to fail the readiness probe, and the server keeps serving for the shutdown delay so
+// load balancers can notice before the listener is closed. Requests still running when the shutdown
+// timeout expires have their context cancelled, which aborts their calls to the store.
 package api
 
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"project/settings"
 	"syscall"
 	"time"
 )
 
-func RunServer(router http.Handler) {
+func RunServer(router http.Handler, serverSettings settings.ServerSettings, tlsSettings settings.TLSSettings, beforeShutdown func()) {
+	// Every request context derives from the base context, which is cancelled once shutdown gives up
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 	server := &http.Server{
-		Addr:    "0.0.0.0:8000",
-		Handler: router,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+		Addr:              serverSettings.Address,
+		Handler:           router,
+		ReadHeaderTimeout: serverSettings.ReadHeaderTimeout,
+		ReadTimeout:       serverSettings.ReadTimeout,
+		WriteTimeout:      serverSettings.WriteTimeout,
+		IdleTimeout:       serverSettings.IdleTimeout,
+	}
+
+	// Load the certificates and watch their files for changes
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	if tlsSettings.Enabled() {
+		reloader, err := newCertificateReloader(tlsSettings)
+		if err != nil {
+			log.Fatalf("TLS error: %v", err)
+		}
+		server.TLSConfig = reloader.tlsConfig()
+		go reloader.watch(watchCtx)
 	}
 
 	// Start the server in a goroutine
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if tlsSettings.Enabled() {
+			log.Printf("Listening on https://%s", server.Addr)
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Listening on http://%s", server.Addr)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("ListenAndServe error: %v", err)
 		}
 	}()
@@ -29,10 +62,14 @@ func RunServer(router http.Handler) {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Server is shutting down...")
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	beforeShutdown()
+	time.Sleep(serverSettings.ShutdownDelay)
+	ctx, cancel := context.WithTimeout(context.Background(), serverSettings.ShutdownTimeout)
 	defer cancel()
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		cancelRequests()
+		log.Printf("Server forced to shutdown, in-flight requests cancelled: %v", err)
+		return
 	}
 	log.Println("Server exited gracefully")
 }
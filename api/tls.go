@@ -0,0 +1,147 @@
+// The `certificateReloader` serves the TLS certificate and client CAs read from files, and reloads
+// them when the files change so certificates can be renewed without restarting the server.
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"project/settings"
+	"sync"
+	"time"
+)
+
+type certificateReloader struct {
+	settings settings.TLSSettings
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	modTimes    map[string]time.Time
+}
+
+func newCertificateReloader(tlsSettings settings.TLSSettings) (*certificateReloader, error) {
+	reloader := &certificateReloader{settings: tlsSettings}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// files returns the files the TLS configuration is read from.
+func (r *certificateReloader) files() []string {
+	files := []string{r.settings.CertFile, r.settings.KeyFile}
+	if r.settings.ClientCAFile != "" {
+		files = append(files, r.settings.ClientCAFile)
+	}
+	return files
+}
+
+// load reads the certificate and the client CAs. On error the previous ones are kept.
+func (r *certificateReloader) load() error {
+	modTimes := make(map[string]time.Time, 3)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.settings.CertFile, r.settings.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+	var clientCAs *x509.CertPool
+	if r.settings.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.settings.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("loading client CAs: no certificate found in " + r.settings.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	return nil
+}
+
+// changed reports whether any of the files was modified since it was loaded.
+func (r *certificateReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			// A file being replaced may be missing for a moment, it is checked again on the next tick
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// watch reloads the files whenever they change until the context is cancelled.
+func (r *certificateReloader) watch(ctx context.Context) {
+	if r.settings.ReloadInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.settings.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.load(); err != nil {
+				log.Printf("Error reloading TLS certificates, keeping the previous ones: %v", err)
+				continue
+			}
+			log.Println("TLS certificates reloaded")
+		}
+	}
+}
+
+// tlsConfig returns the server TLS configuration. The certificate and client CAs are looked up on
+// every handshake, so reloaded files apply to new connections right away.
+func (r *certificateReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.certificate},
+				ClientCAs:    r.clientCAs,
+				ClientAuth:   clientAuthType(r.settings.ClientAuth),
+			}, nil
+		},
+	}
+}
+
+func clientAuthType(clientAuth string) tls.ClientAuthType {
+	switch clientAuth {
+	case settings.ClientAuthOptional:
+		return tls.VerifyClientCertIfGiven
+	case settings.ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert
+	default:
+		return tls.NoClientCert
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"project/api/middleware"
+	"project/auth"
+	"project/settings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue creates a certificate for the common name, signed by the parent or self-signed without one.
+func issue(t *testing.T, commonName string, parent *testCertificate) *testCertificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCertificate{cert: cert, key: key}
+}
+
+func (c *testCertificate) write(t *testing.T, certFile string, keyFile string) {
+	t.Helper()
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	if keyFile != "" {
+		der, err := x509.MarshalECPrivateKey(c.key)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600))
+	}
+}
+
+func (c *testCertificate) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func TestCertificateReloader(t *testing.T) {
+	dir := t.TempDir()
+	tlsSettings := settings.TLSSettings{
+		CertFile:       filepath.Join(dir, "server.crt"),
+		KeyFile:        filepath.Join(dir, "server.key"),
+		ClientCAFile:   filepath.Join(dir, "ca.crt"),
+		ClientAuth:     settings.ClientAuthOptional,
+		ReloadInterval: 10 * time.Millisecond,
+	}
+	ca := issue(t, "test-ca", nil)
+	ca.write(t, tlsSettings.ClientCAFile, "")
+	issue(t, "first.test", ca).write(t, tlsSettings.CertFile, tlsSettings.KeyFile)
+
+	reloader, err := newCertificateReloader(tlsSettings)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.watch(ctx)
+
+	tokens := &auth.TokenStore{}
+	handler := middleware.Authenticate(tokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(auth.FromContext(r.Context()).Subject))
+	}))
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = reloader.tlsConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	client := issue(t, "deployer", ca)
+	// get returns the common name of the certificate served and the subject the caller was identified as
+	get := func(certificates ...tls.Certificate) (string, string) {
+		// The certificate served changes names, so it is checked by the callers instead of verified here
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       certificates,
+		}}}
+		resp, err := httpClient.Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.TLS.PeerCertificates[0].Subject.CommonName, string(body)
+	}
+
+	// A verified client certificate identifies the caller, without one the caller is anonymous
+	served, subject := get(client.tlsCertificate())
+	assert.Equal(t, "first.test", served)
+	assert.Equal(t, "CN=deployer", subject)
+	_, subject = get()
+	assert.Equal(t, auth.Anonymous.Subject, subject)
+
+	// Replacing the files switches to the new certificate without restarting
+	time.Sleep(20 * time.Millisecond)
+	issue(t, "second.test", ca).write(t, tlsSettings.CertFile, tlsSettings.KeyFile)
+	now := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(tlsSettings.CertFile, now, now))
+	assert.Eventually(t, func() bool {
+		served, _ := get()
+		return served == "second.test"
+	}, 2*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,60 @@
+// Package auth defines the identity of the caller of a request and the permissions it holds.
+//
+// Identity is attached to the request context by the authentication middleware. Callers which don't
+// authenticate are anonymous and hold no permissions.
+package auth
+
+import "context"
+
+// PermissionRevealSecrets allows reading secret params in plain text with ?reveal=true.
+const PermissionRevealSecrets = "secrets:reveal"
+
+// PermissionRotateKeys allows starting the job which re-encrypts secret params with the primary key.
+const PermissionRotateKeys = "secrets:rotate"
+
+// PermissionMigrateStore allows applying the migrations and repairs of the keyspace through the admin
+// API.
+const PermissionMigrateStore = "store:migrate"
+
+// PermissionTransferStore allows exporting the whole store to an archive and importing one.
+const PermissionTransferStore = "store:transfer"
+
+// PermissionReviewChanges allows approving and rejecting the change requests of other callers.
+const PermissionReviewChanges = "changes:review"
+
+// PermissionBreakLocks allows unlocking configs and groups locked by other callers.
+const PermissionBreakLocks = "locks:break"
+
+type Identity struct {
+	Subject     string   `json:"subject" yaml:"subject"`
+	Permissions []string `json:"permissions" yaml:"permissions"`
+}
+
+type contextKey struct{}
+
+// Anonymous is the identity of callers which didn't authenticate.
+var Anonymous = Identity{Subject: "anonymous"}
+
+// Can reports whether the identity holds the permission.
+func (i Identity) Can(permission string) bool {
+	for _, granted := range i.Permissions {
+		if granted == permission || granted == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// WithIdentity returns a copy of ctx carrying the identity.
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the identity carried by ctx, or Anonymous.
+func FromContext(ctx context.Context) Identity {
+	identity, ok := ctx.Value(contextKey{}).(Identity)
+	if !ok {
+		return Anonymous
+	}
+	return identity
+}
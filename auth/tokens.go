@@ -0,0 +1,93 @@
+// The `TokenStore` struct maps the bearer tokens accepted by the API, and the subjects of verified
+// client certificates, to caller identities, loaded from a local JSON or YAML file.
+package auth
+
+import (
+	"crypto/subtle"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+type TokenStore struct {
+	tokens map[string]Identity
+	// certificates are indexed by the subject of the client certificate
+	certificates map[string]Identity
+}
+
+// tokensFile is the layout of the tokens file, e.g.
+//
+//	{"tokens": [{"token": "...", "subject": "alice", "permissions": ["secrets:reveal"]}],
+//	 "certificates": [{"subject": "deployer.example.com", "permissions": ["*"]}]}
+//
+// The subject of a certificate entry is either the common name or the whole distinguished name of the
+// client certificate, e.g. CN=deployer.example.com,O=Example.
+type tokensFile struct {
+	Tokens []struct {
+		Token    string `yaml:"token"`
+		Identity `yaml:",inline"`
+	} `yaml:"tokens"`
+	Certificates []Identity `yaml:"certificates"`
+}
+
+// LoadTokenStore reads the tokens file.
+func LoadTokenStore(path string) (*TokenStore, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file tokensFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("tokens file %s: %w", path, err)
+	}
+
+	store := &TokenStore{
+		tokens:       make(map[string]Identity, len(file.Tokens)),
+		certificates: make(map[string]Identity, len(file.Certificates)),
+	}
+	for _, entry := range file.Tokens {
+		if entry.Token == "" || entry.Subject == "" {
+			return nil, errors.New("tokens file " + path + ": every token needs a token and a subject")
+		}
+		store.tokens[entry.Token] = entry.Identity
+	}
+	for _, identity := range file.Certificates {
+		if identity.Subject == "" {
+			return nil, errors.New("tokens file " + path + ": every certificate needs a subject")
+		}
+		store.certificates[identity.Subject] = identity
+	}
+	return store, nil
+}
+
+// Lookup returns the identity the token belongs to.
+func (s *TokenStore) Lookup(token string) (Identity, bool) {
+	if s == nil {
+		return Identity{}, false
+	}
+	for known, identity := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			return identity, true
+		}
+	}
+	return Identity{}, false
+}
+
+// LookupCertificate returns the identity of a client presenting a verified certificate, matched by its
+// distinguished name and then by its common name. Certificates which aren't listed identify the caller
+// by the distinguished name but grant no permissions.
+func (s *TokenStore) LookupCertificate(subject pkix.Name) Identity {
+	distinguishedName := subject.String()
+	if s != nil {
+		if identity, ok := s.certificates[distinguishedName]; ok {
+			return identity
+		}
+		if identity, ok := s.certificates[subject.CommonName]; ok && subject.CommonName != "" {
+			return identity
+		}
+	}
+	return Identity{Subject: distinguishedName}
+}
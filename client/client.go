@@ -0,0 +1,356 @@
+// The `Client` struct is a thin HTTP client for the configuration API, used by the cfgctl command-line
+// tool instead of calling the routes registered in `api.NewRouter` by hand.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"project/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	server     string
+	token      string
+	httpClient *http.Client
+}
+
+// APIError is returned when the server answers with a non-2xx status code.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// NewClient creates a client for the API at server, authenticating with the bearer token if it is not
+// empty.
+func NewClient(server string, token string) *Client {
+	return &Client{
+		server:     strings.TrimRight(server, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Adds a new configuration
+func (c *Client) AddConfig(config model.Config) error {
+	return c.do(http.MethodPost, c.path("configs"), config, nil)
+}
+
+// ReadOptions are the options shared by the reads of configs and groups.
+type ReadOptions struct {
+	// Raw returns the object as stored, without inherited params and resolved references
+	Raw bool
+	// Strict fails on references which can't be resolved
+	Strict bool
+	// Reveal returns secret params in plain text, which requires the secrets:reveal permission
+	Reveal bool
+	// Consistency is the read mode of the store: default, consistent or stale. Empty is default
+	Consistency string
+}
+
+func (o ReadOptions) query() string {
+	query := url.Values{}
+	if o.Raw {
+		query.Set("effective", "false")
+	}
+	if o.Strict {
+		query.Set("strict", "true")
+	}
+	if o.Reveal {
+		query.Set("reveal", "true")
+	}
+	if o.Consistency != "" {
+		query.Set("consistency", o.Consistency)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+// Retrieves a configuration. Unless opts.Raw is set the params inherited from its parents are merged
+// in and references are resolved.
+func (c *Client) GetConfig(name string, version string, opts ReadOptions) (model.ResolvedConfig, error) {
+	var config model.ResolvedConfig
+	err := c.do(http.MethodGet, c.path("configs", name, version)+opts.query(), nil, &config)
+	return config, err
+}
+
+// Deletes a configuration
+func (c *Client) DeleteConfig(name string, version string) error {
+	return c.do(http.MethodDelete, c.path("configs", name, version), nil, nil)
+}
+
+// Adds a new configuration group
+func (c *Client) AddGroup(group model.ConfigGroup) error {
+	return c.do(http.MethodPost, c.path("config-groups"), group, nil)
+}
+
+// Retrieves a configuration group. Unless opts.Raw is set references in the params are resolved.
+func (c *Client) GetGroup(name string, version string, opts ReadOptions) (model.ConfigGroup, error) {
+	var group model.ConfigGroup
+	err := c.do(http.MethodGet, c.path("config-groups", name, version)+opts.query(), nil, &group)
+	return group, err
+}
+
+// Removes a configuration group
+func (c *Client) DeleteGroup(name string, version string) error {
+	return c.do(http.MethodDelete, c.path("config-groups", name, version), nil, nil)
+}
+
+// Adds an existing configuration to a group
+func (c *Client) AddConfigToGroup(groupName string, version string, configName string, configVersion string) error {
+	return c.do(http.MethodPost, c.path("config-groups", groupName, version, configName, configVersion), nil, nil)
+}
+
+// Adds a configuration with labels to a group
+func (c *Client) AddConfigWithLabelToGroup(groupName string, version string, config model.ConfigWithLabels) error {
+	return c.do(http.MethodPost, c.path("config-groups", groupName, version, "configs"), config, nil)
+}
+
+// Removes a configuration from a group
+func (c *Client) RemoveConfigFromGroup(groupName string, version string, configName string, configVersion string) error {
+	return c.do(http.MethodDelete, c.path("config-groups", groupName, version, "configs", configName, configVersion), nil, nil)
+}
+
+// Searches for configurations with labels in a group
+func (c *Client) SearchConfigsWithLabelsInGroup(groupName string, version string, labels []model.Label, configName string, configVersion string, opts ReadOptions) ([]*model.ConfigWithLabels, error) {
+	var configs []*model.ConfigWithLabels
+	err := c.do(http.MethodGet, c.path("config-groups", groupName, version, "configs", FormatLabels(labels), configName, configVersion)+opts.query(), nil, &configs)
+	return configs, err
+}
+
+// Locks a configuration against deletion
+func (c *Client) LockConfig(name string, version string, reason string) (model.Lock, error) {
+	var lock model.Lock
+	err := c.do(http.MethodPut, c.path("configs", name, version, "lock"), map[string]string{"reason": reason}, &lock)
+	return lock, err
+}
+
+// Unlocks a configuration
+func (c *Client) UnlockConfig(name string, version string) error {
+	return c.do(http.MethodDelete, c.path("configs", name, version, "lock"), nil, nil)
+}
+
+// Locks a configuration group, so neither it nor its configurations can be removed
+func (c *Client) LockGroup(name string, version string, reason string) (model.Lock, error) {
+	var lock model.Lock
+	err := c.do(http.MethodPut, c.path("config-groups", name, version, "lock"), map[string]string{"reason": reason}, &lock)
+	return lock, err
+}
+
+// Unlocks a configuration group
+func (c *Client) UnlockGroup(name string, version string) error {
+	return c.do(http.MethodDelete, c.path("config-groups", name, version, "lock"), nil, nil)
+}
+
+// Lists the locks of all configurations and configuration groups
+func (c *Client) ListLocks() ([]model.Lock, error) {
+	var locks []model.Lock
+	err := c.do(http.MethodGet, c.path("locks"), nil, &locks)
+	return locks, err
+}
+
+// Lists the deleted configurations and configuration groups, oldest first
+func (c *Client) ListTrash() ([]model.TrashEntry, error) {
+	var entries []model.TrashEntry
+	err := c.do(http.MethodGet, c.path("trash"), nil, &entries)
+	return entries, err
+}
+
+// Restores a deleted configuration or configuration group
+func (c *Client) RestoreTrash(id string) (model.TrashEntry, error) {
+	var entry model.TrashEntry
+	err := c.do(http.MethodPost, c.path("trash", id, "restore"), nil, &entry)
+	return entry, err
+}
+
+// Proposes a new state of a configuration group as a change request
+func (c *Client) CreateChangeRequest(group model.ConfigGroup, description string) (model.ChangeRequest, error) {
+	var request model.ChangeRequest
+	body := map[string]interface{}{"group": group, "description": description}
+	err := c.do(http.MethodPost, c.path("change-requests"), body, &request)
+	return request, err
+}
+
+// Lists the change requests, only those with the status unless it is empty
+func (c *Client) ListChangeRequests(status model.ChangeRequestStatus) ([]model.ChangeRequest, error) {
+	var requests []model.ChangeRequest
+	path := c.path("change-requests")
+	if status != "" {
+		path += "?status=" + url.QueryEscape(string(status))
+	}
+	err := c.do(http.MethodGet, path, nil, &requests)
+	return requests, err
+}
+
+// Retrieves a change request with its diff against the current group
+func (c *Client) GetChangeRequest(id string) (model.ChangeRequest, error) {
+	var request model.ChangeRequest
+	err := c.do(http.MethodGet, c.path("change-requests", id), nil, &request)
+	return request, err
+}
+
+// Approves a change request, which is applied once it has the required approvals
+func (c *Client) ApproveChangeRequest(id string) (model.ChangeRequest, error) {
+	var request model.ChangeRequest
+	err := c.do(http.MethodPost, c.path("change-requests", id, "approve"), nil, &request)
+	return request, err
+}
+
+// Rejects a change request
+func (c *Client) RejectChangeRequest(id string, reason string) (model.ChangeRequest, error) {
+	var request model.ChangeRequest
+	err := c.do(http.MethodPost, c.path("change-requests", id, "reject"), map[string]string{"reason": reason}, &request)
+	return request, err
+}
+
+// Applies a list of manifests, returning the plan computed by the server
+func (c *Client) Apply(manifests []model.Manifest, dryRun bool, prune bool) (model.ApplyPlan, error) {
+	var plan model.ApplyPlan
+	query := url.Values{}
+	query.Set("dryRun", strconv.FormatBool(dryRun))
+	query.Set("prune", strconv.FormatBool(prune))
+	err := c.do(http.MethodPost, c.path("apply")+"?"+query.Encode(), manifests, &plan)
+	return plan, err
+}
+
+// Exports the whole store as a tar.gz archive
+func (c *Client) Export() ([]byte, error) {
+	var archive []byte
+	err := c.send(http.MethodGet, c.path("admin", "export"), "", nil, &archive)
+	return archive, err
+}
+
+// Imports a tar.gz archive produced by Export
+func (c *Client) Import(archive []byte, strategy model.ImportStrategy) (model.ImportReport, error) {
+	var report model.ImportReport
+	query := url.Values{}
+	query.Set("strategy", string(strategy))
+	err := c.send(http.MethodPost, c.path("admin", "import")+"?"+query.Encode(), "application/gzip", bytes.NewReader(archive), &report)
+	return report, err
+}
+
+// Starts re-encrypting secret params with the primary key, or resumes the last job if it didn't complete
+func (c *Client) StartReencryption() (model.ReencryptionJob, error) {
+	var job model.ReencryptionJob
+	err := c.do(http.MethodPost, c.path("admin", "reencryption"), nil, &job)
+	return job, err
+}
+
+// Retrieves the progress of the running re-encryption job, or the result of the last one
+func (c *Client) GetReencryption() (model.ReencryptionJob, error) {
+	var job model.ReencryptionJob
+	err := c.do(http.MethodGet, c.path("admin", "reencryption"), nil, &job)
+	return job, err
+}
+
+// Retrieves the schema version of the store and the migrations it still needs
+func (c *Client) GetMigrations() (model.MigrationStatus, error) {
+	var status model.MigrationStatus
+	err := c.do(http.MethodGet, c.path("admin", "migrations"), nil, &status)
+	return status, err
+}
+
+// Checks the keyspace for inconsistencies, and with repair applies the repairs of the ones which can be
+// repaired
+func (c *Client) Fsck(repair bool) (model.FsckReport, error) {
+	method := http.MethodGet
+	if repair {
+		method = http.MethodPost
+	}
+	var report model.FsckReport
+	err := c.do(method, c.path("admin", "fsck"), nil, &report)
+	return report, err
+}
+
+// Retrieves the recent activations and expirations of configs and the upcoming ones
+func (c *Client) GetSchedule() (model.ScheduleStatus, error) {
+	var status model.ScheduleStatus
+	err := c.do(http.MethodGet, c.path("admin", "schedule"), nil, &status)
+	return status, err
+}
+
+// Runs the pending migrations of the keyspace, or with dryRun only plans them
+func (c *Client) RunMigrations(dryRun bool) (model.MigrationReport, error) {
+	var report model.MigrationReport
+	err := c.do(http.MethodPost, c.path("admin", "migrations")+"?dryRun="+strconv.FormatBool(dryRun), nil, &report)
+	return report, err
+}
+
+// FormatLabels renders labels in the key1:value1;key2:value2 format expected by the label routes.
+func FormatLabels(labels []model.Label) string {
+	pairs := make([]string, 0, len(labels))
+	for _, label := range labels {
+		pairs = append(pairs, label.Key+":"+label.Value)
+	}
+	return strings.Join(pairs, ";")
+}
+
+func (c *Client) path(segments ...string) string {
+	escaped := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		escaped = append(escaped, url.PathEscape(segment))
+	}
+	return c.server + "/" + strings.Join(escaped, "/")
+}
+
+func (c *Client) do(method string, url string, body interface{}, out interface{}) error {
+	if body == nil {
+		return c.send(method, url, "", nil, out)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return c.send(method, url, "application/json", bytes.NewReader(payload), out)
+}
+
+// send performs the request and decodes a JSON response into out, or copies the raw response when out
+// is a *[]byte.
+func (c *Client) send(method string, url string, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(respBody))}
+	}
+
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*out = respBody
+		return nil
+	default:
+		return json.Unmarshal(respBody, out)
+	}
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+)
+
+// TLSOptions configure HTTPS connections to the API. Without a CA file the system roots are trusted,
+// and the certificate and key, given together, authenticate the client to a server requiring mTLS.
+type TLSOptions struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// UseTLS configures the client to verify the server against the CA and present the client certificate.
+func (c *Client) UseTLS(options TLSOptions) error {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if options.CAFile != "" {
+		pem, err := os.ReadFile(options.CAFile)
+		if err != nil {
+			return err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return errors.New("no certificate found in " + options.CAFile)
+		}
+	}
+	if options.CertFile != "" || options.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	c.httpClient.Transport = transport
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"project/model"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func apply(g *globals, args []string) error {
+	flags := newFlagSet("apply", g)
+	path := flags.String("f", "", "manifest file or directory of manifests (- for stdin)")
+	dryRun := flags.Bool("dry-run", false, "only print the plan, without changing anything")
+	prune := flags.Bool("prune", false, "delete configs and groups which are not in the manifests")
+	if _, err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("missing -f FILE|DIR")
+	}
+
+	manifests, err := readManifests(*path)
+	if err != nil {
+		return err
+	}
+	plan, err := g.client().Apply(manifests, *dryRun, *prune)
+	if err != nil {
+		return err
+	}
+	return printPlan(g.output, plan)
+}
+
+// readManifests reads every manifest in a file, or in the .json, .yaml and .yml files of a directory.
+func readManifests(path string) ([]model.Manifest, error) {
+	if path == "-" {
+		return decodeManifests(os.Stdin, "-")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return readManifestFile(path)
+	}
+
+	var manifests []model.Manifest
+	err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".json", ".yaml", ".yml":
+			fileManifests, err := readManifestFile(file)
+			if err != nil {
+				return err
+			}
+			manifests = append(manifests, fileManifests...)
+		}
+		return nil
+	})
+	return manifests, err
+}
+
+func readManifestFile(path string) ([]model.Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return decodeManifests(file, path)
+}
+
+// decodeManifests decodes a JSON manifest or array of manifests, or a stream of YAML documents.
+func decodeManifests(r io.Reader, path string) ([]model.Manifest, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		trimmed := strings.TrimSpace(string(content))
+		if strings.HasPrefix(trimmed, "[") {
+			var manifests []model.Manifest
+			if err := json.Unmarshal(content, &manifests); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			return manifests, nil
+		}
+		var manifest model.Manifest
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return []model.Manifest{manifest}, nil
+	}
+
+	var manifests []model.Manifest
+	decoder := yaml.NewDecoder(strings.NewReader(string(content)))
+	for {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			return manifests, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		// A YAML document may also hold a list of manifests
+		if len(node.Content) == 1 && node.Content[0].Kind == yaml.SequenceNode {
+			var list []model.Manifest
+			if err := node.Decode(&list); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			manifests = append(manifests, list...)
+			continue
+		}
+		var manifest model.Manifest
+		if err := node.Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		manifests = append(manifests, manifest)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"project/model"
+)
+
+func changeCreate(g *globals, args []string) error {
+	fs := newFlagSet("change create", g)
+	file := fs.String("f", "", "JSON or YAML file describing the proposed group (- for stdin)")
+	description := fs.String("description", "", "what the change does and why")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("missing -f FILE")
+	}
+
+	var group model.ConfigGroup
+	if err := readInput(*file, &group); err != nil {
+		return err
+	}
+	request, err := g.client().CreateChangeRequest(group, *description)
+	if err != nil {
+		return err
+	}
+	return printChangeRequest(g.output, request)
+}
+
+func changeList(g *globals, args []string) error {
+	fs := newFlagSet("change list", g)
+	status := fs.String("status", "", "only list change requests with the status: pending, applied or rejected")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	requests, err := g.client().ListChangeRequests(model.ChangeRequestStatus(*status))
+	if err != nil {
+		return err
+	}
+	return printChangeRequests(g.output, requests)
+}
+
+func changeGet(g *globals, args []string) error {
+	id, err := idArg(newFlagSet("change get", g), args)
+	if err != nil {
+		return err
+	}
+
+	request, err := g.client().GetChangeRequest(id)
+	if err != nil {
+		return err
+	}
+	return printChangeRequest(g.output, request)
+}
+
+func changeApprove(g *globals, args []string) error {
+	id, err := idArg(newFlagSet("change approve", g), args)
+	if err != nil {
+		return err
+	}
+
+	request, err := g.client().ApproveChangeRequest(id)
+	if err != nil {
+		return err
+	}
+	return printChangeRequest(g.output, request)
+}
+
+func changeReject(g *globals, args []string) error {
+	fs := newFlagSet("change reject", g)
+	reason := fs.String("reason", "", "why the change is rejected")
+	id, err := idArg(fs, args)
+	if err != nil {
+		return err
+	}
+
+	request, err := g.client().RejectChangeRequest(id, *reason)
+	if err != nil {
+		return err
+	}
+	return printChangeRequest(g.output, request)
+}
+
+// idArg parses the flags and the single ID argument.
+func idArg(fs *flag.FlagSet, args []string) (string, error) {
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return "", err
+	}
+	if len(positional) != 1 {
+		return "", fmt.Errorf("expected 1 ID argument, got %d", len(positional))
+	}
+	return positional[0], nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"project/client"
+	"project/model"
+)
+
+func configGet(g *globals, args []string) error {
+	fs := newFlagSet("config get", g)
+	opts := readFlags(fs)
+	refs, err := refArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	ref := refs[0]
+
+	config, err := g.client().GetConfig(ref.Name, ref.Version, *opts)
+	if err != nil {
+		return err
+	}
+	return printConfig(g.output, config)
+}
+
+func configAdd(g *globals, args []string) error {
+	fs := newFlagSet("config add", g)
+	file := fs.String("f", "", "JSON or YAML file describing the config (- for stdin)")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("missing -f FILE")
+	}
+
+	var config model.Config
+	if err := readInput(*file, &config); err != nil {
+		return err
+	}
+	if err := g.client().AddConfig(config); err != nil {
+		return err
+	}
+	fmt.Printf("Config %s added\n", model.Ref{Name: config.Name, Version: config.Version})
+	return nil
+}
+
+func configDelete(g *globals, args []string) error {
+	refs, err := refArgs(newFlagSet("config delete", g), args, 1)
+	if err != nil {
+		return err
+	}
+	ref := refs[0]
+	if err := g.client().DeleteConfig(ref.Name, ref.Version); err != nil {
+		return err
+	}
+	fmt.Printf("Config %s deleted\n", ref)
+	return nil
+}
+
+// readFlags registers the flags shared by the commands reading configs and groups.
+func readFlags(fs *flag.FlagSet) *client.ReadOptions {
+	opts := &client.ReadOptions{}
+	fs.BoolVar(&opts.Raw, "raw", false, "show the object as stored, without inherited params and resolved references")
+	fs.BoolVar(&opts.Strict, "strict", false, "fail on references which can't be resolved")
+	fs.BoolVar(&opts.Reveal, "reveal", false, "show secret params in plain text (requires the secrets:reveal permission)")
+	fs.StringVar(&opts.Consistency, "consistency", "", "read mode of the store: default, consistent or stale")
+	return opts
+}
+
+// refArgs parses the flags of a subcommand which takes exactly n NAME@VERSION arguments.
+func refArgs(fs *flag.FlagSet, args []string, n int) ([]model.Ref, error) {
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(positional) != n {
+		return nil, fmt.Errorf("expected %d NAME@VERSION argument(s), got %d", n, len(positional))
+	}
+	refs := make([]model.Ref, 0, n)
+	for _, arg := range positional {
+		ref, err := model.ParseRef(arg)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
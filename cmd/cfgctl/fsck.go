@@ -0,0 +1,24 @@
+package main
+
+import "fmt"
+
+// fsck fails if issues are left after the run, so it can gate scripts.
+func fsck(g *globals, args []string) error {
+	fs := newFlagSet("fsck", g)
+	repair := fs.Bool("repair", false, "apply the repairs of the issues which can be repaired")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	report, err := g.client().Fsck(*repair)
+	if err != nil {
+		return err
+	}
+	if err := printFsckReport(g.output, report); err != nil {
+		return err
+	}
+	if left := len(report.Issues) - report.Repaired; left > 0 {
+		return fmt.Errorf("%d issues left in the store", left)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"project/client"
+	"project/model"
+)
+
+func groupGet(g *globals, args []string) error {
+	fs := newFlagSet("group get", g)
+	opts := readFlags(fs)
+	refs, err := refArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	group, err := g.client().GetGroup(refs[0].Name, refs[0].Version, *opts)
+	if err != nil {
+		return err
+	}
+	return printGroup(g.output, group)
+}
+
+func groupAdd(g *globals, args []string) error {
+	fs := newFlagSet("group add", g)
+	file := fs.String("f", "", "JSON or YAML file describing the group (- for stdin)")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("missing -f FILE")
+	}
+
+	var group model.ConfigGroup
+	if err := readInput(*file, &group); err != nil {
+		return err
+	}
+	if err := g.client().AddGroup(group); err != nil {
+		return err
+	}
+	fmt.Printf("Config group %s added\n", model.Ref{Name: group.Name, Version: group.Version})
+	return nil
+}
+
+func groupDelete(g *globals, args []string) error {
+	refs, err := refArgs(newFlagSet("group delete", g), args, 1)
+	if err != nil {
+		return err
+	}
+	if err := g.client().DeleteGroup(refs[0].Name, refs[0].Version); err != nil {
+		return err
+	}
+	fmt.Printf("Config group %s deleted\n", refs[0])
+	return nil
+}
+
+// groupClone copies every config of the source group, labels and variables included, into a new group.
+// Secret params are read in plain text, so cloning a group with secrets requires the secrets:reveal
+// permission.
+func groupClone(g *globals, args []string) error {
+	refs, err := refArgs(newFlagSet("group clone", g), args, 2)
+	if err != nil {
+		return err
+	}
+	source, target := refs[0], refs[1]
+
+	c := g.client()
+	group, err := c.GetGroup(source.Name, source.Version, client.ReadOptions{Raw: true, Reveal: hasSecrets(c, source)})
+	if err != nil {
+		return err
+	}
+	group.Name = target.Name
+	group.Version = target.Version
+	if err := c.AddGroup(group); err != nil {
+		return err
+	}
+	fmt.Printf("Config group %s cloned to %s\n", source, target)
+	return nil
+}
+
+func groupDiff(g *globals, args []string) error {
+	refs, err := refArgs(newFlagSet("group diff", g), args, 2)
+	if err != nil {
+		return err
+	}
+
+	c := g.client()
+	before, err := c.GetGroup(refs[0].Name, refs[0].Version, client.ReadOptions{Raw: true})
+	if err != nil {
+		return err
+	}
+	after, err := c.GetGroup(refs[1].Name, refs[1].Version, client.ReadOptions{Raw: true})
+	if err != nil {
+		return err
+	}
+	return printDiff(g.output, model.DiffGroups(before, after))
+}
+
+// groupAddConfig adds an existing config to a group, or a config with labels read from a file.
+func groupAddConfig(g *globals, args []string) error {
+	fs := newFlagSet("group add-config", g)
+	file := fs.String("f", "", "JSON or YAML file describing a config with labels (- for stdin)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return errors.New("missing GROUP@VERSION argument")
+	}
+	group, err := model.ParseRef(positional[0])
+	if err != nil {
+		return err
+	}
+
+	c := g.client()
+	switch {
+	case *file != "" && len(positional) == 1:
+		var config model.ConfigWithLabels
+		if err := readInput(*file, &config); err != nil {
+			return err
+		}
+		if err := c.AddConfigWithLabelToGroup(group.Name, group.Version, config); err != nil {
+			return err
+		}
+		fmt.Printf("Config %s added to group %s\n", model.Ref{Name: config.Name, Version: config.Version}, group)
+	case *file == "" && len(positional) == 2:
+		config, err := model.ParseRef(positional[1])
+		if err != nil {
+			return err
+		}
+		if err := c.AddConfigToGroup(group.Name, group.Version, config.Name, config.Version); err != nil {
+			return err
+		}
+		fmt.Printf("Config %s added to group %s\n", config, group)
+	default:
+		return errors.New("expected either CONFIG@VERSION or -f FILE")
+	}
+	return nil
+}
+
+// hasSecrets reports whether any config of the group has secret params.
+func hasSecrets(c *client.Client, ref model.Ref) bool {
+	group, err := c.GetGroup(ref.Name, ref.Version, client.ReadOptions{Raw: true})
+	if err != nil {
+		return false
+	}
+	for _, config := range group.Configs {
+		if len(config.Secrets) > 0 {
+			return true
+		}
+	}
+	return false
+}
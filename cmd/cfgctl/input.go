@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readInput decodes a JSON or YAML file into v. The format is chosen by the file extension, and
+// standard input (-) is read as YAML, which also accepts JSON documents.
+func readInput(path string, v interface{}) error {
+	var (
+		content []byte
+		err     error
+	)
+	if path == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return json.Unmarshal(content, v)
+	}
+	return yaml.Unmarshal(content, v)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"project/model"
+)
+
+func configLock(g *globals, args []string) error {
+	fs := newFlagSet("config lock", g)
+	reason := fs.String("reason", "", "why the config must not be deleted")
+	ref, err := lockArgs(fs, args, reason)
+	if err != nil {
+		return err
+	}
+	if _, err := g.client().LockConfig(ref.Name, ref.Version, *reason); err != nil {
+		return err
+	}
+	fmt.Printf("Config %s locked\n", ref)
+	return nil
+}
+
+func configUnlock(g *globals, args []string) error {
+	refs, err := refArgs(newFlagSet("config unlock", g), args, 1)
+	if err != nil {
+		return err
+	}
+	ref := refs[0]
+	if err := g.client().UnlockConfig(ref.Name, ref.Version); err != nil {
+		return err
+	}
+	fmt.Printf("Config %s unlocked\n", ref)
+	return nil
+}
+
+func groupLock(g *globals, args []string) error {
+	fs := newFlagSet("group lock", g)
+	reason := fs.String("reason", "", "why the group and its configs must not be removed")
+	ref, err := lockArgs(fs, args, reason)
+	if err != nil {
+		return err
+	}
+	if _, err := g.client().LockGroup(ref.Name, ref.Version, *reason); err != nil {
+		return err
+	}
+	fmt.Printf("Group %s locked\n", ref)
+	return nil
+}
+
+func groupUnlock(g *globals, args []string) error {
+	refs, err := refArgs(newFlagSet("group unlock", g), args, 1)
+	if err != nil {
+		return err
+	}
+	ref := refs[0]
+	if err := g.client().UnlockGroup(ref.Name, ref.Version); err != nil {
+		return err
+	}
+	fmt.Printf("Group %s unlocked\n", ref)
+	return nil
+}
+
+func locks(g *globals, args []string) error {
+	if _, err := parseFlags(newFlagSet("locks", g), args); err != nil {
+		return err
+	}
+
+	locks, err := g.client().ListLocks()
+	if err != nil {
+		return err
+	}
+	return printLocks(g.output, locks)
+}
+
+// lockArgs parses the NAME@VERSION argument of a lock command, which requires the --reason flag.
+func lockArgs(fs *flag.FlagSet, args []string, reason *string) (model.Ref, error) {
+	refs, err := refArgs(fs, args, 1)
+	if err != nil {
+		return model.Ref{}, err
+	}
+	if *reason == "" {
+		return model.Ref{}, errors.New("missing --reason TEXT")
+	}
+	return refs[0], nil
+}
@@ -0,0 +1,211 @@
+// The cfgctl command is a command-line tool for managing configurations and configuration groups
+// through the HTTP API, so operators don't have to call the routes with curl.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"project/client"
+	"sort"
+	"strings"
+)
+
+// The `globals` struct holds the flags shared by every subcommand.
+type globals struct {
+	server string
+	token  string
+	tls    client.TLSOptions
+	output string
+}
+
+type command struct {
+	usage string
+	run   func(g *globals, args []string) error
+}
+
+var commands = map[string]map[string]command{
+	"config": {
+		"get":    {usage: "config get NAME@VERSION [--raw] [--strict] [--reveal] [--consistency MODE]", run: configGet},
+		"add":    {usage: "config add -f FILE", run: configAdd},
+		"delete": {usage: "config delete NAME@VERSION", run: configDelete},
+		"lock":   {usage: "config lock NAME@VERSION --reason TEXT", run: configLock},
+		"unlock": {usage: "config unlock NAME@VERSION", run: configUnlock},
+	},
+	"group": {
+		"get":        {usage: "group get NAME@VERSION [--raw] [--strict] [--reveal] [--consistency MODE]", run: groupGet},
+		"add":        {usage: "group add -f FILE", run: groupAdd},
+		"delete":     {usage: "group delete NAME@VERSION", run: groupDelete},
+		"clone":      {usage: "group clone SOURCE@VERSION TARGET@VERSION", run: groupClone},
+		"diff":       {usage: "group diff NAME@VERSION NAME@VERSION", run: groupDiff},
+		"add-config": {usage: "group add-config GROUP@VERSION (CONFIG@VERSION | -f FILE)", run: groupAddConfig},
+		"lock":       {usage: "group lock NAME@VERSION --reason TEXT", run: groupLock},
+		"unlock":     {usage: "group unlock NAME@VERSION", run: groupUnlock},
+	},
+	"change": {
+		"create":  {usage: "change create -f FILE [--description TEXT]", run: changeCreate},
+		"list":    {usage: "change list [--status pending|applied|rejected]", run: changeList},
+		"get":     {usage: "change get ID", run: changeGet},
+		"approve": {usage: "change approve ID", run: changeApprove},
+		"reject":  {usage: "change reject ID [--reason TEXT]", run: changeReject},
+	},
+	"apply": {
+		"": {usage: "apply -f FILE|DIR [--dry-run] [--prune]", run: apply},
+	},
+	"export": {
+		"": {usage: "export -f FILE", run: exportStore},
+	},
+	"fsck": {
+		"": {usage: "fsck [--repair]", run: fsck},
+	},
+	"import": {
+		"": {usage: "import -f FILE [--strategy skip|overwrite|fail]", run: importStore},
+	},
+	"locks": {
+		"": {usage: "locks", run: locks},
+	},
+	"migrate": {
+		"run":    {usage: "migrate run [--dry-run]", run: migrateRun},
+		"status": {usage: "migrate status", run: migrateStatus},
+	},
+	"reencrypt": {
+		"start":  {usage: "reencrypt start [--wait]", run: reencryptStart},
+		"status": {usage: "reencrypt status [--wait]", run: reencryptStatus},
+	},
+	"schedule": {
+		"": {usage: "schedule", run: schedule},
+	},
+	"trash": {
+		"list":    {usage: "trash list", run: trashList},
+		"restore": {usage: "trash restore ID", run: trashRestore},
+	},
+	"search": {
+		"": {usage: "search GROUP@VERSION --selector KEY=VALUE[,KEY=VALUE] --config NAME@VERSION [--strict] [--reveal] [--consistency MODE]", run: search},
+	},
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	g := &globals{}
+	fs := newFlagSet("cfgctl", g)
+	fs.Usage = printUsage
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		printUsage()
+		return errors.New("missing command")
+	}
+
+	group, ok := commands[args[0]]
+	if !ok {
+		printUsage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	if cmd, ok := group[""]; ok {
+		return cmd.run(g, args[1:])
+	}
+	if len(args) < 2 {
+		printUsage()
+		return fmt.Errorf("missing %s subcommand", args[0])
+	}
+	cmd, ok := group[args[1]]
+	if !ok {
+		printUsage()
+		return fmt.Errorf("unknown command %q", args[0]+" "+args[1])
+	}
+	return cmd.run(g, args[2:])
+}
+
+// newFlagSet creates a flag set which also accepts the global flags, so they can be given after the
+// subcommand as well.
+func newFlagSet(name string, g *globals) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	server := g.server
+	if server == "" {
+		server = os.Getenv("CFGCTL_SERVER")
+	}
+	if server == "" {
+		server = "http://localhost:8000"
+	}
+	token := g.token
+	if token == "" {
+		token = os.Getenv("CFGCTL_TOKEN")
+	}
+	caFile := envDefault(g.tls.CAFile, "CFGCTL_CACERT")
+	certFile := envDefault(g.tls.CertFile, "CFGCTL_CERT")
+	keyFile := envDefault(g.tls.KeyFile, "CFGCTL_KEY")
+	output := g.output
+	if output == "" {
+		output = "table"
+	}
+	fs.StringVar(&g.server, "server", server, "address of the configuration API (env CFGCTL_SERVER)")
+	fs.StringVar(&g.token, "token", token, "bearer token identifying the caller (env CFGCTL_TOKEN)")
+	fs.StringVar(&g.tls.CAFile, "cacert", caFile, "CA certificate the server is verified against (env CFGCTL_CACERT)")
+	fs.StringVar(&g.tls.CertFile, "cert", certFile, "client certificate for mutual TLS (env CFGCTL_CERT)")
+	fs.StringVar(&g.tls.KeyFile, "key", keyFile, "private key of the client certificate (env CFGCTL_KEY)")
+	fs.StringVar(&g.output, "o", output, "output format: table, json or yaml")
+	return fs
+}
+
+// envDefault returns the value if it is already set, e.g. by a flag before the subcommand, or the
+// environment variable otherwise.
+func envDefault(value string, env string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(env)
+}
+
+// parseFlags parses the subcommand flags, allowing them to be mixed with positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func (g *globals) client() *client.Client {
+	c := client.NewClient(g.server, g.token)
+	if g.tls != (client.TLSOptions{}) {
+		if err := c.UseTLS(g.tls); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+	}
+	return c
+}
+
+func printUsage() {
+	var lines []string
+	for _, group := range commands {
+		for _, cmd := range group {
+			lines = append(lines, "  cfgctl "+cmd.usage)
+		}
+	}
+	sort.Strings(lines)
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, strings.Join(lines, "\n"))
+	fmt.Fprintln(os.Stderr, "\nGlobal flags:")
+	fmt.Fprintln(os.Stderr, "  --server URL   address of the configuration API (env CFGCTL_SERVER, default http://localhost:8000)")
+	fmt.Fprintln(os.Stderr, "  --token TOKEN  bearer token identifying the caller (env CFGCTL_TOKEN)")
+	fmt.Fprintln(os.Stderr, "  --cacert FILE  CA certificate the server is verified against (env CFGCTL_CACERT)")
+	fmt.Fprintln(os.Stderr, "  --cert FILE    client certificate for mutual TLS (env CFGCTL_CERT)")
+	fmt.Fprintln(os.Stderr, "  --key FILE     private key of the client certificate (env CFGCTL_KEY)")
+	fmt.Fprintln(os.Stderr, "  -o FORMAT      output format: table, json or yaml (default table)")
+}
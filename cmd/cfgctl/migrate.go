@@ -0,0 +1,28 @@
+package main
+
+func migrateRun(g *globals, args []string) error {
+	fs := newFlagSet("migrate run", g)
+	dryRun := fs.Bool("dry-run", false, "only report the changes the migrations would make")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	report, err := g.client().RunMigrations(*dryRun)
+	if err != nil {
+		return err
+	}
+	return printMigrationReport(g.output, report)
+}
+
+func migrateStatus(g *globals, args []string) error {
+	fs := newFlagSet("migrate status", g)
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	status, err := g.client().GetMigrations()
+	if err != nil {
+		return err
+	}
+	return printMigrationStatus(g.output, status)
+}
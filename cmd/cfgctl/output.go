@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"project/model"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func printConfig(format string, config model.ResolvedConfig) error {
+	var v interface{} = config
+	if config.Provenance == nil {
+		v = config.Config
+	}
+	return render(format, v, func(w io.Writer) {
+		if config.Extends != "" {
+			fmt.Fprintf(w, "EXTENDS\t%s\n\n", config.Extends)
+		}
+		fmt.Fprintln(w, "NAME\tVERSION\tPARAM\tVALUE\tSOURCE")
+		keys := sortedParams(config.Params)
+		if len(keys) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t\t\t\n", config.Name, config.Version)
+		}
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", config.Name, config.Version, key, config.Params[key], config.Provenance[key])
+		}
+	})
+}
+
+func printGroup(format string, group model.ConfigGroup) error {
+	return render(format, group, func(w io.Writer) {
+		fmt.Fprintf(w, "GROUP\t%s\n", model.Ref{Name: group.Name, Version: group.Version})
+		for _, name := range sortedParams(group.Variables) {
+			fmt.Fprintf(w, "VARIABLE\t%s=%s\n", name, group.Variables[name])
+		}
+		fmt.Fprintln(w)
+		writeConfigsTable(w, group.Configs)
+	})
+}
+
+func printConfigs(format string, configs []*model.ConfigWithLabels) error {
+	return render(format, configs, func(w io.Writer) {
+		writeConfigsTable(w, configs)
+	})
+}
+
+func printDiff(format string, diff model.GroupDiff) error {
+	return render(format, diff, func(w io.Writer) {
+		if diff.Empty() {
+			fmt.Fprintln(w, "No differences")
+			return
+		}
+		fmt.Fprintln(w, "CHANGE\tCONFIG\tDETAIL")
+		for _, config := range diff.Added {
+			fmt.Fprintf(w, "+\t%s\t%s\n", model.Ref{Name: config.Name, Version: config.Version}, formatLabels(config.Labels))
+		}
+		for _, config := range diff.Removed {
+			fmt.Fprintf(w, "-\t%s\t%s\n", model.Ref{Name: config.Name, Version: config.Version}, formatLabels(config.Labels))
+		}
+		for _, change := range diff.Changed {
+			ref := model.Ref{Name: change.Name, Version: change.Version}
+			if change.LabelsBefore != nil || change.LabelsAfter != nil {
+				fmt.Fprintf(w, "~\t%s\tlabels: %s -> %s\n", ref, formatLabels(change.LabelsBefore), formatLabels(change.LabelsAfter))
+			}
+			for _, param := range change.Params {
+				fmt.Fprintf(w, "~\t%s\t%s: %q -> %q\n", ref, param.Key, param.Old, param.New)
+			}
+		}
+		for _, variable := range diff.Variables {
+			fmt.Fprintf(w, "~\t<variables>\t%s: %q -> %q\n", variable.Key, variable.Old, variable.New)
+		}
+		if diff.Protected != nil {
+			fmt.Fprintf(w, "~\t<group>\tprotected: %t\n", *diff.Protected)
+		}
+	})
+}
+
+func printPlan(format string, plan model.ApplyPlan) error {
+	return render(format, plan, func(w io.Writer) {
+		if len(plan.Steps) == 0 {
+			fmt.Fprintln(w, "Nothing to apply, the store is up to date")
+			return
+		}
+		fmt.Fprintln(w, "ACTION\tKIND\tOBJECT\tDETAIL")
+		for _, step := range plan.Steps {
+			ref := model.Ref{Name: step.Name, Version: step.Version}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", step.Action, step.Kind, ref, stepDetail(step))
+		}
+		fmt.Fprintln(w)
+		switch {
+		case plan.Applied:
+			fmt.Fprintf(w, "Applied %d change(s)\n", len(plan.Steps))
+		case plan.DryRun:
+			fmt.Fprintf(w, "Dry run, %d change(s) not applied\n", len(plan.Steps))
+		}
+	})
+}
+
+func stepDetail(step model.PlanStep) string {
+	switch {
+	case step.Params != nil:
+		keys := make([]string, 0, len(step.Params))
+		for _, param := range step.Params {
+			keys = append(keys, param.Key)
+		}
+		return "params: " + strings.Join(keys, ",")
+	case step.Diff != nil:
+		return fmt.Sprintf("%d added, %d removed, %d changed", len(step.Diff.Added), len(step.Diff.Removed), len(step.Diff.Changed))
+	}
+	return ""
+}
+
+func printImportReport(format string, report model.ImportReport) error {
+	return render(format, report, func(w io.Writer) {
+		fmt.Fprintln(w, "RESULT\tOBJECT")
+		for _, id := range report.Created {
+			fmt.Fprintf(w, "created\t%s\n", id)
+		}
+		for _, id := range report.Overwritten {
+			fmt.Fprintf(w, "overwritten\t%s\n", id)
+		}
+		for _, id := range report.Skipped {
+			fmt.Fprintf(w, "skipped\t%s\n", id)
+		}
+		for _, id := range report.Refused {
+			fmt.Fprintf(w, "refused\t%s\n", id)
+		}
+	})
+}
+
+func printReencryptionJob(format string, job model.ReencryptionJob) error {
+	return render(format, job, func(w io.Writer) {
+		fmt.Fprintln(w, "JOB\tKEY\tSTATUS\tPROGRESS\tSCANNED\tREENCRYPTED\tFAILED")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.0f%%\t%d/%d\t%d\t%d\n", job.ID, job.KeyID, job.Status, job.Progress*100, job.Scanned, job.Total, job.Reencrypted, len(job.FailedKeys))
+		if job.Error != "" {
+			fmt.Fprintf(w, "error: %s\n", job.Error)
+		}
+		for _, key := range job.FailedKeys {
+			fmt.Fprintf(w, "failed: %s\n", key)
+		}
+	})
+}
+
+func printMigrationStatus(format string, status model.MigrationStatus) error {
+	return render(format, status, func(w io.Writer) {
+		fmt.Fprintf(w, "schema version %d of %d\n", status.Version, status.Latest)
+		if len(status.Pending) > 0 {
+			fmt.Fprintln(w, "VERSION\tNAME\tDESCRIPTION")
+		}
+		for _, migration := range status.Pending {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", migration.Version, migration.Name, migration.Description)
+		}
+	})
+}
+
+func printMigrationReport(format string, report model.MigrationReport) error {
+	return render(format, report, func(w io.Writer) {
+		fmt.Fprintln(w, "MIGRATION\tACTION\tKEY\tTARGET\tREASON")
+		for _, result := range report.Migrations {
+			for _, change := range result.Changes {
+				fmt.Fprintf(w, "%d %s\t%s\t%s\t%s\t%s\n", result.Version, result.Name, change.Action, change.Key, change.Target, change.Reason)
+			}
+			for _, conflict := range result.Conflicts {
+				fmt.Fprintf(w, "left: %s\n", conflict)
+			}
+		}
+		if report.DryRun {
+			fmt.Fprintf(w, "dry run, schema stays at version %d\n", report.ToVersion)
+		} else {
+			fmt.Fprintf(w, "schema migrated from version %d to %d\n", report.FromVersion, report.ToVersion)
+		}
+	})
+}
+
+func printFsckReport(format string, report model.FsckReport) error {
+	return render(format, report, func(w io.Writer) {
+		fmt.Fprintln(w, "KIND\tKEY\tDETAIL\tREPAIR")
+		for _, issue := range report.Issues {
+			repair := "manual"
+			switch {
+			case issue.Repaired:
+				repair = "repaired"
+			case len(issue.Repair) > 0:
+				repair = "available"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", issue.Kind, issue.Key, issue.Detail, repair)
+		}
+		fmt.Fprintf(w, "%d keys scanned, %d issues, %d repaired\n", report.Scanned, len(report.Issues), report.Repaired)
+	})
+}
+
+func printChangeRequest(format string, request model.ChangeRequest) error {
+	if err := render(format, request, func(w io.Writer) {
+		writeChangeRequestsTable(w, []model.ChangeRequest{request})
+		if request.Description != "" {
+			fmt.Fprintf(w, "\n%s\n", request.Description)
+		}
+		if request.Reason != "" {
+			fmt.Fprintf(w, "\nrejected: %s\n", request.Reason)
+		}
+	}); err != nil || format != "table" || request.Diff == nil {
+		return err
+	}
+	fmt.Println()
+	return printDiff(format, *request.Diff)
+}
+
+func printChangeRequests(format string, requests []model.ChangeRequest) error {
+	return render(format, requests, func(w io.Writer) {
+		writeChangeRequestsTable(w, requests)
+	})
+}
+
+func writeChangeRequestsTable(w io.Writer, requests []model.ChangeRequest) {
+	fmt.Fprintln(w, "ID\tGROUP\tAUTHOR\tSTATUS\tAPPROVALS")
+	for _, request := range requests {
+		reviewers := make([]string, 0, len(request.Approvals))
+		for _, approval := range request.Approvals {
+			reviewers = append(reviewers, approval.Reviewer)
+		}
+		approvals := fmt.Sprintf("%d/%d", len(request.Approvals), request.RequiredApprovals)
+		if len(reviewers) > 0 {
+			approvals += " (" + strings.Join(reviewers, ",") + ")"
+		}
+		ref := model.Ref{Name: request.Group.Name, Version: request.Group.Version}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", request.ID, ref, orNone(request.Author), request.Status, approvals)
+	}
+}
+
+func printLocks(format string, locks []model.Lock) error {
+	return render(format, locks, func(w io.Writer) {
+		fmt.Fprintln(w, "KIND\tOBJECT\tOWNER\tREASON\tLOCKED AT")
+		for _, lock := range locks {
+			ref := model.Ref{Name: lock.Name, Version: lock.Version}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", lock.Kind, ref, orNone(lock.Owner), lock.Reason, lock.LockedAt.Format(time.RFC3339))
+		}
+	})
+}
+
+func printTrash(format string, entries []model.TrashEntry) error {
+	return render(format, entries, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tKIND\tOBJECT\tDELETED AT\tEXPIRES AT")
+		for _, entry := range entries {
+			ref := model.Ref{Name: entry.Name, Version: entry.Version}
+			expiresAt := "<none>"
+			if entry.ExpiresAt != nil {
+				expiresAt = entry.ExpiresAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", entry.ID, entry.Kind, ref, entry.DeletedAt.Format(time.RFC3339), expiresAt)
+		}
+	})
+}
+
+func printScheduleStatus(format string, status model.ScheduleStatus) error {
+	return render(format, status, func(w io.Writer) {
+		fmt.Fprintln(w, "WHEN\tKIND\tCONFIG\tGROUP\tAT")
+		for _, event := range status.Events {
+			fmt.Fprintf(w, "done\t%s\t%s\t%s\t%s\n", event.Kind, event.Config, orNone(event.Group), event.At.Format(time.RFC3339))
+		}
+		for _, event := range status.Upcoming {
+			fmt.Fprintf(w, "upcoming\t%s\t%s\t%s\t%s\n", event.Kind, event.Config, orNone(event.Group), event.At.Format(time.RFC3339))
+		}
+		if !status.LastRun.IsZero() {
+			fmt.Fprintf(w, "last run at %s\n", status.LastRun.Format(time.RFC3339))
+		}
+	})
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+// render writes v to stdout in the requested format, using table to render the table format.
+func render(format string, v interface{}, table func(w io.Writer)) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case "yaml":
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q. Expected table, json or yaml", format)
+	}
+}
+
+func writeConfigsTable(w io.Writer, configs []*model.ConfigWithLabels) {
+	fmt.Fprintln(w, "NAME\tVERSION\tLABELS\tPARAMS")
+	for _, config := range configs {
+		params := make([]string, 0, len(config.Params))
+		for _, key := range sortedParams(config.Params) {
+			params = append(params, key+"="+config.Params[key])
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", config.Name, config.Version, formatLabels(config.Labels), strings.Join(params, ","))
+	}
+}
+
+func formatLabels(labels []model.Label) string {
+	pairs := make([]string, 0, len(labels))
+	for _, label := range labels {
+		pairs = append(pairs, label.Key+"="+label.Value)
+	}
+	if len(pairs) == 0 {
+		return "<none>"
+	}
+	return strings.Join(pairs, ",")
+}
+
+func sortedParams(params map[string]string) []string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
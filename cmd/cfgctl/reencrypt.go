@@ -0,0 +1,58 @@
+package main
+
+import (
+	"project/model"
+	"time"
+)
+
+// reencryptPollInterval is how often --wait checks the progress of the job.
+const reencryptPollInterval = time.Second
+
+func reencryptStart(g *globals, args []string) error {
+	fs := newFlagSet("reencrypt start", g)
+	wait := fs.Bool("wait", false, "wait for the job to finish")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	job, err := g.client().StartReencryption()
+	if err != nil {
+		return err
+	}
+	if *wait {
+		return waitReencryption(g)
+	}
+	return printReencryptionJob(g.output, job)
+}
+
+func reencryptStatus(g *globals, args []string) error {
+	fs := newFlagSet("reencrypt status", g)
+	wait := fs.Bool("wait", false, "wait for the job to finish")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *wait {
+		return waitReencryption(g)
+	}
+	job, err := g.client().GetReencryption()
+	if err != nil {
+		return err
+	}
+	return printReencryptionJob(g.output, job)
+}
+
+// waitReencryption polls the job until it is no longer running and prints its final state.
+func waitReencryption(g *globals) error {
+	c := g.client()
+	for {
+		job, err := c.GetReencryption()
+		if err != nil {
+			return err
+		}
+		if job.Status != model.ReencryptionRunning {
+			return printReencryptionJob(g.output, job)
+		}
+		time.Sleep(reencryptPollInterval)
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+func schedule(g *globals, args []string) error {
+	fs := newFlagSet("schedule", g)
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	status, err := g.client().GetSchedule()
+	if err != nil {
+		return err
+	}
+	return printScheduleStatus(g.output, status)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"project/model"
+	"strings"
+)
+
+func search(g *globals, args []string) error {
+	fs := newFlagSet("search", g)
+	selector := fs.String("selector", "", "labels to match, e.g. env=prod,team=core")
+	configRef := fs.String("config", "", "config to look for, as NAME@VERSION")
+	opts := readFlags(fs)
+	refs, err := refArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *configRef == "" {
+		return errors.New("missing --config NAME@VERSION")
+	}
+	config, err := model.ParseRef(*configRef)
+	if err != nil {
+		return err
+	}
+	labels, err := parseSelector(*selector)
+	if err != nil {
+		return err
+	}
+
+	configs, err := g.client().SearchConfigsWithLabelsInGroup(refs[0].Name, refs[0].Version, labels, config.Name, config.Version, *opts)
+	if err != nil {
+		return err
+	}
+	return printConfigs(g.output, configs)
+}
+
+// parseSelector parses a comma separated list of key=value (or key:value) label requirements.
+func parseSelector(selector string) ([]model.Label, error) {
+	var labels []model.Label
+	for _, requirement := range strings.Split(selector, ",") {
+		requirement = strings.TrimSpace(requirement)
+		if requirement == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(requirement, "=")
+		if !ok {
+			key, value, ok = strings.Cut(requirement, ":")
+		}
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("invalid selector %q. Expected format is key=value", requirement)
+		}
+		labels = append(labels, model.Label{Key: key, Value: value})
+	}
+	if len(labels) == 0 {
+		return nil, errors.New("missing --selector KEY=VALUE")
+	}
+	return labels, nil
+}
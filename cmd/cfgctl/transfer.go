@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"project/model"
+)
+
+func exportStore(g *globals, args []string) error {
+	fs := newFlagSet("export", g)
+	file := fs.String("f", "", "file to write the archive to (- for stdout)")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("missing -f FILE")
+	}
+
+	archive, err := g.client().Export()
+	if err != nil {
+		return err
+	}
+	if *file == "-" {
+		_, err = os.Stdout.Write(archive)
+		return err
+	}
+	if err := os.WriteFile(*file, archive, 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Store exported to %s\n", *file)
+	return nil
+}
+
+func importStore(g *globals, args []string) error {
+	fs := newFlagSet("import", g)
+	file := fs.String("f", "", "archive produced by export (- for stdin)")
+	strategy := fs.String("strategy", string(model.ImportFail), "what to do with existing objects: skip, overwrite or fail")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("missing -f FILE")
+	}
+
+	var (
+		archive []byte
+		err     error
+	)
+	if *file == "-" {
+		archive, err = io.ReadAll(os.Stdin)
+	} else {
+		archive, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return err
+	}
+
+	report, err := g.client().Import(archive, model.ImportStrategy(*strategy))
+	if err != nil {
+		return err
+	}
+	return printImportReport(g.output, report)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"project/model"
+)
+
+func trashList(g *globals, args []string) error {
+	if _, err := parseFlags(newFlagSet("trash list", g), args); err != nil {
+		return err
+	}
+
+	entries, err := g.client().ListTrash()
+	if err != nil {
+		return err
+	}
+	return printTrash(g.output, entries)
+}
+
+func trashRestore(g *globals, args []string) error {
+	id, err := idArg(newFlagSet("trash restore", g), args)
+	if err != nil {
+		return err
+	}
+
+	entry, err := g.client().RestoreTrash(id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %s restored\n", entry.Kind, model.Ref{Name: entry.Name, Version: entry.Version})
+	return nil
+}
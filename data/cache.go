@@ -0,0 +1,228 @@
+// The code below keeps the keys of selected prefixes of the Consul key-value store in memory, updated
+// by blocking queries, so reads of those prefixes can skip the round trip to Consul.
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"project/metrics"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// CacheOptions select the prefixes whose keys are kept in memory. Each prefix is watched with a Consul
+// blocking query, which returns as soon as a key under the prefix changes, so the copy is replaced
+// right after every change, whichever server made it.
+type CacheOptions struct {
+	// Prefixes are the cached key prefixes, e.g. configs/. No prefixes disable the cache
+	Prefixes []string
+	// MaxStale is how long after the watch last heard from Consul the copy is still served. Once it
+	// is older, e.g. while Consul is unreachable, reads go to Consul again
+	MaxStale time.Duration
+	// WaitTime bounds each blocking query, Consul answers at least this often without changes
+	WaitTime time.Duration
+}
+
+// watchRetryInterval is how long a watch waits after a failed blocking query.
+const watchRetryInterval = time.Second
+
+// prefixCache is the in-memory copy of the keys under a prefix.
+type prefixCache struct {
+	prefix string
+
+	mu       sync.RWMutex
+	pairs    map[string]*api.KVPair
+	syncedAt time.Time
+	// index and lastContact are the ones Consul reported with the copy
+	index       uint64
+	lastContact time.Duration
+	// synced is the number of writes the copy is known to reflect
+	synced uint64
+
+	// writes counts the writes under the prefix made through this Database. The copy is only served
+	// while it reflects all of them, so callers always read their own writes
+	writes atomic.Uint64
+}
+
+// cacheFor returns the cache of the prefix holding key, nil if the key isn't cached.
+func (db *Database) cacheFor(key string) *prefixCache {
+	for _, cache := range db.caches {
+		if strings.HasPrefix(key, cache.prefix) {
+			return cache
+		}
+	}
+	return nil
+}
+
+// cached returns the copy to serve a read of key from, nil if the read has to go to Consul. Reads of
+// cached prefixes are counted as hits or misses, consistent reads always go to Consul and aren't
+// counted.
+func (db *Database) cached(ctx context.Context, key string) *prefixCache {
+	cache := db.cacheFor(key)
+	if cache == nil || ConsistencyFromContext(ctx) == ConsistencyConsistent {
+		return nil
+	}
+	if !cache.fresh(db.maxStale) {
+		metrics.ObserveCache(cache.prefix, false)
+		return nil
+	}
+	metrics.ObserveCache(cache.prefix, true)
+	if info, ok := ctx.Value(readInfoKey{}).(*ReadInfo); ok {
+		index, staleness := cache.age()
+		info.record(index, staleness, true)
+	}
+	return cache
+}
+
+// invalidate marks the caches holding the key, or below it for a deleted tree, as behind until their
+// watch read them again.
+func (db *Database) invalidate(key string) {
+	for _, cache := range db.caches {
+		if strings.HasPrefix(key, cache.prefix) || strings.HasPrefix(cache.prefix, key) {
+			cache.writes.Add(1)
+		}
+	}
+}
+
+// WatchCache keeps the cached prefixes up to date until the context is cancelled. Reads go to Consul
+// until the first copy of a prefix was read.
+func (db *Database) WatchCache(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, cache := range db.caches {
+		wg.Add(1)
+		go func(cache *prefixCache) {
+			defer wg.Done()
+			db.watch(ctx, cache)
+		}(cache)
+	}
+	wg.Wait()
+}
+
+func (db *Database) watch(ctx context.Context, cache *prefixCache) {
+	var index uint64
+	for ctx.Err() == nil {
+		writes := cache.writes.Load()
+		options := &api.QueryOptions{WaitIndex: index, WaitTime: db.waitTime}
+		// A write made while the last query was running may not be in its answer, and blocking on
+		// the index of that answer could then wait for the next change
+		if cache.behind(writes) {
+			options.WaitIndex = 0
+		}
+		pairs, meta, err := db.client.KV().List(cache.prefix, options.WithContext(ctx))
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.WarnContext(ctx, "cache watch failed", "prefix", cache.prefix, "error", err.Error())
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(watchRetryInterval):
+			}
+			continue
+		}
+		// The index going backwards means the Consul state was reset, the watch starts over
+		index = meta.LastIndex
+		if index < options.WaitIndex {
+			index = 0
+		}
+		cache.store(pairs, meta, writes)
+	}
+}
+
+func (c *prefixCache) store(pairs api.KVPairs, meta *api.QueryMeta, writes uint64) {
+	copied := make(map[string]*api.KVPair, len(pairs))
+	for _, pair := range pairs {
+		copied[pair.Key] = pair
+	}
+	c.mu.Lock()
+	c.pairs = copied
+	c.syncedAt = time.Now()
+	c.index = meta.LastIndex
+	c.lastContact = meta.LastContact
+	c.synced = writes
+	c.mu.Unlock()
+	metrics.CacheEntries.WithLabelValues(c.prefix).Set(float64(len(copied)))
+}
+
+// age returns the index of the copy and how stale it is: the time since the watch last heard from
+// Consul, on top of the staleness Consul reported with it.
+func (c *prefixCache) age() (uint64, time.Duration) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.index, c.lastContact + time.Since(c.syncedAt)
+}
+
+// behind reports whether the copy misses some of the writes.
+func (c *prefixCache) behind(writes uint64) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.synced != writes
+}
+
+// fresh reports whether the copy can be served: it was read, reflects every write made through this
+// Database and the watch heard from Consul within maxStale.
+func (c *prefixCache) fresh(maxStale time.Duration) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.pairs == nil || c.synced != c.writes.Load() {
+		return false
+	}
+	return maxStale <= 0 || time.Since(c.syncedAt) <= maxStale
+}
+
+func (c *prefixCache) get(key string, value interface{}) error {
+	c.mu.RLock()
+	pair := c.pairs[key]
+	c.mu.RUnlock()
+	if pair == nil {
+		return nil
+	}
+	return json.Unmarshal(pair.Value, value)
+}
+
+func (c *prefixCache) list(prefix string) (map[string]interface{}, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := make(map[string]interface{})
+	for key, pair := range c.pairs {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(pair.Value, &value); err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+func (c *prefixCache) pairsUnder(prefix string) []Pair {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var kvPairs api.KVPairs
+	for key, pair := range c.pairs {
+		if strings.HasPrefix(key, prefix) {
+			kvPairs = append(kvPairs, pair)
+		}
+	}
+	return toPairs(kvPairs)
+}
+
+func (c *prefixCache) keys(prefix string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := make([]string, 0, len(c.pairs))
+	for key := range c.pairs {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
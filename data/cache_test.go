@@ -0,0 +1,79 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabase_Cache(t *testing.T) {
+	ctx := context.Background()
+	// Every run watches its own prefix, so runs sharing a Consul don't see each other's keys
+	prefix := fmt.Sprintf("cache-test-%x/", rand.Int63())
+	db, err := NewDatabase(Options{Cache: CacheOptions{Prefixes: []string{prefix}, MaxStale: 5 * time.Second, WaitTime: time.Second}})
+	require.NoError(t, err)
+	// Another server, writing to Consul directly
+	other, err := NewDatabase(Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, other.Txn(ctx, []TxnOp{{Verb: TxnDeleteTree, Key: prefix}}))
+	})
+	require.NoError(t, other.Put(ctx, prefix+"a", "1"))
+
+	// Nothing is served before the watch read the prefix
+	assert.Nil(t, db.cached(ctx, prefix+"a"))
+	watchCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go db.WatchCache(watchCtx)
+	assert.Eventually(t, func() bool { return db.cached(ctx, prefix+"a") != nil }, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, db.cached(ctx, "other/a"))
+
+	var value string
+	require.NoError(t, db.Get(ctx, prefix+"a", &value))
+	assert.Equal(t, "1", value)
+
+	// Writes through the Database are read right after, from Consul until the watch caught up
+	require.NoError(t, db.Put(ctx, prefix+"b", "2"))
+	require.NoError(t, db.Get(ctx, prefix+"b", &value))
+	assert.Equal(t, "2", value)
+	assert.Eventually(t, func() bool { return db.cached(ctx, prefix+"b") != nil }, 5*time.Second, 10*time.Millisecond)
+	keys, err := db.Keys(ctx, prefix)
+	require.NoError(t, err)
+	assert.Equal(t, []string{prefix + "a", prefix + "b"}, keys)
+	values, err := db.List(ctx, prefix+"b")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{prefix + "b": "2"}, values)
+
+	// Writes of other servers reach the copy through the watch
+	require.NoError(t, other.Put(ctx, prefix+"a", "3"))
+	assert.Eventually(t, func() bool {
+		return db.Get(ctx, prefix+"a", &value) == nil && value == "3" && db.cached(ctx, prefix+"a") != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Consistent reads always go to Consul, reads from the copy are reported with its index
+	assert.Nil(t, db.cached(WithConsistency(ctx, ConsistencyConsistent), prefix+"a"))
+	infoCtx, info := WithReadInfo(ctx)
+	require.NoError(t, db.Get(infoCtx, prefix+"a", &value))
+	read := info.Snapshot()
+	assert.Equal(t, 1, read.Reads)
+	assert.Equal(t, 1, read.Cached)
+	assert.NotZero(t, read.Index)
+
+	// A deleted tree is read from Consul until the watch saw it
+	require.NoError(t, db.Txn(ctx, []TxnOp{{Verb: TxnDeleteTree, Key: strings.TrimSuffix(prefix, "/")}}))
+	keys, err = db.Keys(ctx, prefix)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	// A copy the watch hasn't refreshed within MaxStale is not served
+	assert.Eventually(t, func() bool { return db.cached(ctx, prefix+"a") != nil }, 5*time.Second, 10*time.Millisecond)
+	stop()
+	db.maxStale = 10 * time.Millisecond
+	assert.Eventually(t, func() bool { return db.cached(ctx, prefix+"a") == nil }, 5*time.Second, 10*time.Millisecond)
+}
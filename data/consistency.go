@@ -0,0 +1,104 @@
+// The code below selects the Consul read mode of a request and records the index and staleness
+// Consul reported for its reads, so responses can tell how fresh their data is.
+package data
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// Consistency is the read mode of Consul used for the reads of a request.
+type Consistency string
+
+const (
+	// ConsistencyDefault reads from the leader, which may serve a stale value for a short while after
+	// a new leader was elected. Reads may be served from the cache
+	ConsistencyDefault Consistency = "default"
+	// ConsistencyConsistent makes the leader confirm it is still the leader before answering, and
+	// never reads from the cache
+	ConsistencyConsistent Consistency = "consistent"
+	// ConsistencyStale lets any server answer, also without a leader, with values at most as old as
+	// the staleness reported with them
+	ConsistencyStale Consistency = "stale"
+)
+
+// ParseConsistency parses a read mode, an empty one is ConsistencyDefault.
+func ParseConsistency(s string) (Consistency, error) {
+	switch consistency := Consistency(s); consistency {
+	case "":
+		return ConsistencyDefault, nil
+	case ConsistencyDefault, ConsistencyConsistent, ConsistencyStale:
+		return consistency, nil
+	}
+	return "", fmt.Errorf("consistency %q is not valid. Expected default, consistent or stale", s)
+}
+
+type consistencyKey struct{}
+
+// WithConsistency returns a copy of ctx whose reads use the read mode.
+func WithConsistency(ctx context.Context, consistency Consistency) context.Context {
+	return context.WithValue(ctx, consistencyKey{}, consistency)
+}
+
+// ConsistencyFromContext returns the read mode of the context, ConsistencyDefault if none was set.
+func ConsistencyFromContext(ctx context.Context) Consistency {
+	if consistency, ok := ctx.Value(consistencyKey{}).(Consistency); ok {
+		return consistency
+	}
+	return ConsistencyDefault
+}
+
+// ReadInfo collects what Consul reported about the reads made with a context, so a response can tell
+// how current the data it was built from is.
+type ReadInfo struct {
+	mu sync.Mutex
+	// Index is the highest Raft index of the reads, the state of the store they reflect
+	Index uint64
+	// Staleness is the longest time a server answering a read hadn't heard from the leader, or since
+	// the watch of a cached read last heard from Consul
+	Staleness time.Duration
+	// Reads counts the reads, Cached the ones served from the cache
+	Reads  int
+	Cached int
+}
+
+type readInfoKey struct{}
+
+// WithReadInfo returns a copy of ctx whose reads are recorded in the returned ReadInfo.
+func WithReadInfo(ctx context.Context) (context.Context, *ReadInfo) {
+	info := &ReadInfo{}
+	return context.WithValue(ctx, readInfoKey{}, info), info
+}
+
+// Snapshot returns a copy of the info, safe to read while reads are still recorded.
+func (i *ReadInfo) Snapshot() ReadInfo {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return ReadInfo{Index: i.Index, Staleness: i.Staleness, Reads: i.Reads, Cached: i.Cached}
+}
+
+func (i *ReadInfo) record(index uint64, staleness time.Duration, cached bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.Reads++
+	if cached {
+		i.Cached++
+	}
+	if index > i.Index {
+		i.Index = index
+	}
+	if staleness > i.Staleness {
+		i.Staleness = staleness
+	}
+}
+
+// recordRead adds a read answered by Consul to the ReadInfo of the context, if any.
+func recordRead(ctx context.Context, meta *api.QueryMeta) {
+	if info, ok := ctx.Value(readInfoKey{}).(*ReadInfo); ok && meta != nil {
+		info.record(meta.LastIndex, meta.LastContact, false)
+	}
+}
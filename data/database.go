@@ -3,51 +3,157 @@
 package data
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"project/metrics"
+	"project/tracing"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/consul/api"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Database struct {
-	client *api.Client
+	client       *api.Client
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	caches   []*prefixCache
+	maxStale time.Duration
+	waitTime time.Duration
+}
+
+// Options select the Consul agent to connect to. Empty fields keep the Consul defaults, which read the
+// CONSUL_HTTP_* environment variables.
+type Options struct {
+	Address    string
+	Scheme     string
+	Datacenter string
+	Token      string
+	// ReadTimeout and WriteTimeout bound every read and write on top of the deadline of the caller's
+	// context, 0 leaves them unbounded
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// Cache keeps the keys under hot prefixes in memory, see CacheOptions
+	Cache CacheOptions
 }
 
-func NewDatabase() (*Database, error) {
-	client, err := api.NewClient(api.DefaultConfig())
+func NewDatabase(options Options) (*Database, error) {
+	config := api.DefaultConfig()
+	if options.Address != "" {
+		config.Address = options.Address
+	}
+	if options.Scheme != "" {
+		config.Scheme = options.Scheme
+	}
+	if options.Datacenter != "" {
+		config.Datacenter = options.Datacenter
+	}
+	if options.Token != "" {
+		config.Token = options.Token
+	}
+	client, err := api.NewClient(config)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Database{client: client}, nil
+	db := &Database{
+		client:       client,
+		readTimeout:  options.ReadTimeout,
+		writeTimeout: options.WriteTimeout,
+		maxStale:     options.Cache.MaxStale,
+		waitTime:     options.Cache.WaitTime,
+	}
+	for _, prefix := range options.Cache.Prefixes {
+		db.caches = append(db.caches, &prefixCache{prefix: prefix})
+	}
+	return db, nil
 }
 
-// This `Put` method in the `Database` struct is used to store a key-value pair in the Consul key-value
-// store. Here's a breakdown of what it does:
-func (db *Database) Put(keyType string, name string, version string, value interface{}) (string, error) {
-	kv := db.client.KV()
-	// Form the key using the keyType, name, and version
-	key := fmt.Sprintf("%s/%s/%s", keyType, name, version)
-	jsonValue, err := json.Marshal(value)
-	if err != nil {
-		return "", err
+// instrument starts a span for a store operation on the key, empty for operations on no single key,
+// and bounds the returned context by the timeout. The returned func ends the span and records the
+// duration and error of the operation. An operation cut short by the context fails with an error
+// wrapping context.Canceled or context.DeadlineExceeded.
+func instrument(ctx context.Context, operation string, key string, timeout time.Duration) (context.Context, func(err *error)) {
+	attributes := []attribute.KeyValue{
+		attribute.String("db.system", "consul"),
+		attribute.String("db.operation", operation),
+		attribute.String("db.consul.consistency", string(ConsistencyFromContext(ctx))),
 	}
-	p := &api.KVPair{Key: key, Value: jsonValue}
-	_, err = kv.Put(p, nil)
+	if key != "" {
+		attributes = append(attributes, attribute.String("db.consul.key", key))
+	}
+	ctx, span := tracing.Start(ctx, "Database."+operation, attributes...)
+	cancel := func() {}
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	start := time.Now()
+	return ctx, func(err *error) {
+		if *err != nil && ctx.Err() != nil {
+			*err = fmt.Errorf("consul %s %s: %w", operation, key, ctx.Err())
+		}
+		cancel()
+		metrics.ObserveStore(operation, start, *err)
+		tracing.End(span, *err)
+		attrs := []any{"operation", operation, "key", key, "duration_ms", float64(time.Since(start).Microseconds()) / 1000}
+		if *err != nil {
+			attrs = append(attrs, "error", (*err).Error())
+		}
+		slog.DebugContext(ctx, "store operation", attrs...)
+	}
+}
+
+// queryOptions returns the options of a read in the read mode of the context.
+func queryOptions(ctx context.Context) *api.QueryOptions {
+	options := &api.QueryOptions{}
+	switch ConsistencyFromContext(ctx) {
+	case ConsistencyConsistent:
+		options.RequireConsistent = true
+	case ConsistencyStale:
+		options.AllowStale = true
+	}
+	return options.WithContext(ctx)
+}
+
+func writeOptions(ctx context.Context) *api.WriteOptions {
+	return (&api.WriteOptions{}).WithContext(ctx)
+}
+
+// This `Put` method in the `Database` struct is used to store a value in the Consul key-value store
+// under a key built with `Key`.
+func (db *Database) Put(ctx context.Context, key string, value interface{}) (err error) {
+	ctx, finish := instrument(ctx, "put", key, db.writeTimeout)
+	defer finish(&err)
+	defer db.invalidate(key)
+
+	jsonValue, err := json.Marshal(value)
 	if err != nil {
-		return "", err
+		return err
 	}
-	return key, nil
+	_, err = db.client.KV().Put(&api.KVPair{Key: key, Value: jsonValue}, writeOptions(ctx))
+	return err
 }
 
 // The `Get` method in the `Database` struct is used to retrieve a value from the Consul key-value
 // store based on the provided key. Here's a breakdown of what it does:
-func (db *Database) Get(key string, value interface{}) error {
+func (db *Database) Get(ctx context.Context, key string, value interface{}) (err error) {
+	if cache := db.cached(ctx, key); cache != nil {
+		return cache.get(key, value)
+	}
+	ctx, finish := instrument(ctx, "get", key, db.readTimeout)
+	defer finish(&err)
+
 	kv := db.client.KV()
-	pair, _, err := kv.Get(key, nil)
+	pair, meta, err := kv.Get(key, queryOptions(ctx))
 	if err != nil {
 		return err
 	}
+	recordRead(ctx, meta)
 	if pair == nil {
 		return nil
 	}
@@ -60,9 +166,13 @@ func (db *Database) Get(key string, value interface{}) error {
 
 // The `Delete` method in the `Database` struct is used to delete a key-value pair from the Consul
 // key-value store based on the provided key. Here's a breakdown of what it does:
-func (db *Database) Delete(key string) error {
+func (db *Database) Delete(ctx context.Context, key string) (err error) {
+	ctx, finish := instrument(ctx, "delete", key, db.writeTimeout)
+	defer finish(&err)
+	defer db.invalidate(key)
+
 	kv := db.client.KV()
-	_, err := kv.Delete(key, nil)
+	_, err = kv.Delete(key, writeOptions(ctx))
 	if err != nil {
 		return err
 	}
@@ -71,12 +181,19 @@ func (db *Database) Delete(key string) error {
 
 // The `List` method in the `Database` struct is used to list all key-value pairs in the Consul
 // key-value store that match the provided key prefix. Here's a breakdown of what it does:
-func (db *Database) List(keyPrefix string) (map[string]interface{}, error) {
+func (db *Database) List(ctx context.Context, keyPrefix string) (_ map[string]interface{}, err error) {
+	if cache := db.cached(ctx, keyPrefix); cache != nil {
+		return cache.list(keyPrefix)
+	}
+	ctx, finish := instrument(ctx, "list", keyPrefix, db.readTimeout)
+	defer finish(&err)
+
 	kv := db.client.KV()
-	pairs, _, err := kv.List(keyPrefix, nil)
+	pairs, meta, err := kv.List(keyPrefix, queryOptions(ctx))
 	if err != nil {
 		return nil, err
 	}
+	recordRead(ctx, meta)
 	result := make(map[string]interface{})
 	for _, pair := range pairs {
 		var value interface{}
@@ -88,3 +205,187 @@ func (db *Database) List(keyPrefix string) (map[string]interface{}, error) {
 	}
 	return result, nil
 }
+
+// Pair is a raw key-value pair as stored in Consul, with the indexes it was created and last modified
+// at. Value holds the JSON encoded value.
+type Pair struct {
+	Key         string
+	Value       []byte
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// Decode unmarshals the value of the pair into value.
+func (p Pair) Decode(value interface{}) error {
+	return json.Unmarshal(p.Value, value)
+}
+
+// The `Pairs` method in the `Database` struct is used to read every key-value pair matching the
+// provided key prefix in a single query, sorted by key, leaving the values to be decoded by the caller.
+func (db *Database) Pairs(ctx context.Context, keyPrefix string) (_ []Pair, err error) {
+	if cache := db.cached(ctx, keyPrefix); cache != nil {
+		return cache.pairsUnder(keyPrefix), nil
+	}
+	ctx, finish := instrument(ctx, "list", keyPrefix, db.readTimeout)
+	defer finish(&err)
+
+	kvPairs, meta, err := db.client.KV().List(keyPrefix, queryOptions(ctx))
+	if err != nil {
+		return nil, err
+	}
+	recordRead(ctx, meta)
+	return toPairs(kvPairs), nil
+}
+
+// toPairs converts the pairs returned by Consul, sorted by key.
+func toPairs(kvPairs api.KVPairs) []Pair {
+	pairs := make([]Pair, 0, len(kvPairs))
+	for _, kvPair := range kvPairs {
+		pairs = append(pairs, Pair{Key: kvPair.Key, Value: kvPair.Value, CreateIndex: kvPair.CreateIndex, ModifyIndex: kvPair.ModifyIndex})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key < pairs[j].Key
+	})
+	return pairs
+}
+
+// The `Keys` method in the `Database` struct is used to list the keys in the Consul key-value store
+// that match the provided key prefix, in lexicographic order, without reading their values.
+func (db *Database) Keys(ctx context.Context, keyPrefix string) (_ []string, err error) {
+	if cache := db.cached(ctx, keyPrefix); cache != nil {
+		return cache.keys(keyPrefix), nil
+	}
+	ctx, finish := instrument(ctx, "keys", keyPrefix, db.readTimeout)
+	defer finish(&err)
+
+	keys, meta, err := db.client.KV().Keys(keyPrefix, "", queryOptions(ctx))
+	if err != nil {
+		return nil, err
+	}
+	recordRead(ctx, meta)
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// The `GetWithIndex` method in the `Database` struct is used to retrieve a value together with the
+// index it was last modified at, which can be passed to `PutCAS`. The index is 0 if the key doesn't
+// exist. It always reads from Consul, never from the cache.
+func (db *Database) GetWithIndex(ctx context.Context, key string, value interface{}) (_ uint64, err error) {
+	ctx, finish := instrument(ctx, "get", key, db.readTimeout)
+	defer finish(&err)
+
+	pair, meta, err := db.client.KV().Get(key, queryOptions(ctx))
+	if err != nil {
+		return 0, err
+	}
+	recordRead(ctx, meta)
+	if pair == nil {
+		return 0, nil
+	}
+	if err := json.Unmarshal(pair.Value, value); err != nil {
+		return 0, err
+	}
+	return pair.ModifyIndex, nil
+}
+
+// The `PutCAS` method in the `Database` struct is used to store a value only if the key was not
+// modified since the given index was read. It reports false if the key was modified in the meantime.
+func (db *Database) PutCAS(ctx context.Context, key string, value interface{}, index uint64) (_ bool, err error) {
+	ctx, finish := instrument(ctx, "cas", key, db.writeTimeout)
+	defer finish(&err)
+	defer db.invalidate(key)
+
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	ok, _, err := db.client.KV().CAS(&api.KVPair{Key: key, Value: jsonValue, ModifyIndex: index}, writeOptions(ctx))
+	return ok, err
+}
+
+// The `Leader` method in the `Database` struct is used to retrieve the address of the Raft leader of
+// the Consul cluster, which also tells whether the cluster is reachable and has elected a leader.
+func (db *Database) Leader(ctx context.Context) (_ string, err error) {
+	ctx, finish := instrument(ctx, "status", "", db.readTimeout)
+	defer finish(&err)
+
+	return db.client.Status().LeaderWithQueryOptions(queryOptions(ctx))
+}
+
+// The `Peers` method in the `Database` struct is used to retrieve the addresses of the Raft peers of
+// the Consul cluster.
+func (db *Database) Peers(ctx context.Context) (_ []string, err error) {
+	ctx, finish := instrument(ctx, "status", "", db.readTimeout)
+	defer finish(&err)
+
+	return db.client.Status().PeersWithQueryOptions(queryOptions(ctx))
+}
+
+// MaxTxnOps is the largest number of operations Consul accepts in a single transaction.
+const MaxTxnOps = 64
+
+type TxnVerb string
+
+const (
+	TxnSet        TxnVerb = "set"
+	TxnDelete     TxnVerb = "delete"
+	TxnDeleteTree TxnVerb = "delete-tree"
+	// TxnCAS sets the value only if the key was not modified since Index, otherwise the whole
+	// transaction is rolled back
+	TxnCAS TxnVerb = "cas"
+	// TxnCheckIndex rolls the transaction back if the key was modified since Index
+	TxnCheckIndex TxnVerb = "check-index"
+	// TxnCheckNotExists rolls the transaction back if the key exists
+	TxnCheckNotExists TxnVerb = "check-not-exists"
+)
+
+// TxnOp is a single write in a transaction. Value is marshalled to JSON for TxnSet and TxnCAS
+// operations.
+type TxnOp struct {
+	Verb  TxnVerb
+	Key   string
+	Value interface{}
+	Index uint64
+}
+
+// The `Txn` method in the `Database` struct is used to apply a list of writes to the Consul key-value
+// store atomically, either all of the operations are applied or none of them are.
+func (db *Database) Txn(ctx context.Context, ops []TxnOp) (err error) {
+	ctx, finish := instrument(ctx, "txn", "", db.writeTimeout)
+	defer finish(&err)
+	defer func() {
+		for _, op := range ops {
+			db.invalidate(op.Key)
+		}
+	}()
+
+	if len(ops) > MaxTxnOps {
+		return fmt.Errorf("transaction has %d operations, more than the %d allowed", len(ops), MaxTxnOps)
+	}
+
+	txnOps := make(api.TxnOps, 0, len(ops))
+	for _, op := range ops {
+		kvOp := &api.KVTxnOp{Verb: api.KVOp(op.Verb), Key: op.Key, Index: op.Index}
+		if op.Verb == TxnSet || op.Verb == TxnCAS {
+			jsonValue, err := json.Marshal(op.Value)
+			if err != nil {
+				return err
+			}
+			kvOp.Value = jsonValue
+		}
+		txnOps = append(txnOps, &api.TxnOp{KV: kvOp})
+	}
+
+	ok, resp, _, err := db.client.Txn().Txn(txnOps, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		errs := make([]string, 0, len(resp.Errors))
+		for _, txnErr := range resp.Errors {
+			errs = append(errs, txnErr.What)
+		}
+		return fmt.Errorf("transaction rolled back: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
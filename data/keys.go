@@ -0,0 +1,134 @@
+// The code below escapes names, versions and labels into the segments of the keys of the Consul
+// key-value store, and splits such keys back into their segments.
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Keys in the store are paths of segments separated by "/". Names, versions and labels are escaped
+// into segments, so no value can add a segment or change the layout of the keyspace. Letters, digits,
+// "-", "_" and "." are kept as they are and every other byte is written as %XX with uppercase hex
+// digits. The encoding is canonical, every string has a single escaped form, and the keys of names
+// using only the kept characters read the same as they did before keys were escaped.
+
+// EscapeSegment escapes s into a single key segment.
+func EscapeSegment(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if keptInSegment(c) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// UnescapeSegment reverses EscapeSegment. Segments which EscapeSegment can't produce, e.g. with
+// escaped letters or lowercase hex digits, are rejected, so a decoded key always encodes back to
+// itself.
+func UnescapeSegment(segment string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		if keptInSegment(c) {
+			b.WriteByte(c)
+			continue
+		}
+		if c != '%' || i+2 >= len(segment) {
+			return "", fmt.Errorf("invalid key segment %q", segment)
+		}
+		high, okHigh := unhex(segment[i+1])
+		low, okLow := unhex(segment[i+2])
+		decoded := high<<4 | low
+		if !okHigh || !okLow || keptInSegment(decoded) {
+			return "", fmt.Errorf("invalid key segment %q", segment)
+		}
+		b.WriteByte(decoded)
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// Key joins the escaped segments into a key.
+func Key(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = EscapeSegment(segment)
+	}
+	return strings.Join(escaped, "/")
+}
+
+// SplitKey splits the key into its unescaped segments.
+func SplitKey(key string) ([]string, error) {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		unescaped, err := UnescapeSegment(segment)
+		if err != nil {
+			return nil, err
+		}
+		segments[i] = unescaped
+	}
+	return segments, nil
+}
+
+// LabelsSegment encodes labels into a single key segment, key:value; for every label in the order of
+// the keys, e.g. env:prod;team:db;. Colons and semicolons in the keys and values are escaped.
+func LabelsSegment(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(EscapeSegment(key))
+		b.WriteByte(':')
+		b.WriteString(EscapeSegment(labels[key]))
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+// ParseLabelsSegment reverses LabelsSegment.
+func ParseLabelsSegment(segment string) (map[string]string, error) {
+	labels := make(map[string]string)
+	pairs, ok := strings.CutSuffix(segment, ";")
+	if !ok {
+		return nil, fmt.Errorf("invalid labels segment %q", segment)
+	}
+	for _, pair := range strings.Split(pairs, ";") {
+		escapedKey, escapedValue, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid labels segment %q", segment)
+		}
+		key, err := UnescapeSegment(escapedKey)
+		if err != nil {
+			return nil, err
+		}
+		value, err := UnescapeSegment(escapedValue)
+		if err != nil {
+			return nil, err
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+func keptInSegment(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.'
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
@@ -0,0 +1,47 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeSegment(t *testing.T) {
+	for _, s := range []string{"", "db", "payments-v1.2_rc", "a/b", "a%2Fb", "env:prod;", "ünïcode", "..", " spaced "} {
+		segment := EscapeSegment(s)
+		assert.NotContains(t, segment, "/")
+		unescaped, err := UnescapeSegment(segment)
+		assert.NoError(t, err)
+		assert.Equal(t, s, unescaped)
+	}
+	assert.Equal(t, "db-1.0", EscapeSegment("db-1.0"))
+	assert.Equal(t, "a%2Fb%3A%25", EscapeSegment("a/b:%"))
+
+	// Only the form EscapeSegment produces is accepted
+	for _, segment := range []string{"a/b", "a:b", "%2f", "%41", "%2", "%G0"} {
+		_, err := UnescapeSegment(segment)
+		assert.Error(t, err, segment)
+	}
+}
+
+func TestKey(t *testing.T) {
+	key := Key("config-groups", "a/b", "1.0")
+	assert.Equal(t, "config-groups/a%2Fb/1.0", key)
+	segments, err := SplitKey(key)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"config-groups", "a/b", "1.0"}, segments)
+}
+
+func TestLabelsSegment(t *testing.T) {
+	labels := map[string]string{"team": "db", "env": "prod:eu/1"}
+	segment := LabelsSegment(labels)
+	assert.Equal(t, "env:prod%3Aeu%2F1;team:db;", segment)
+	parsed, err := ParseLabelsSegment(segment)
+	assert.NoError(t, err)
+	assert.Equal(t, labels, parsed)
+
+	for _, segment := range []string{"env:prod", "env;", "env:pr:od;"} {
+		_, err := ParseLabelsSegment(segment)
+		assert.Error(t, err, segment)
+	}
+}
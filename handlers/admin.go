@@ -0,0 +1,245 @@
+// The code defines an AdminHandler struct with methods for exporting the whole store to an archive and
+// importing it back using a TransferService, for re-encrypting secret params after a key rotation
+// using a ReencryptionService, for migrating the keyspace using a MigrationService, for checking and
+// repairing it using a FsckService, and for following the activity windows of configs using a
+// Scheduler.
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"project/auth"
+	"project/model"
+	"project/services"
+	"time"
+)
+
+type AdminHandler struct {
+	transferService     services.TransferService
+	reencryptionService *services.ReencryptionService
+	migrationService    *services.MigrationService
+	fsckService         services.FsckService
+	scheduler           *services.Scheduler
+}
+
+func NewAdminHandler(transferService services.TransferService, reencryptionService *services.ReencryptionService, migrationService *services.MigrationService, fsckService services.FsckService, scheduler *services.Scheduler) *AdminHandler {
+	return &AdminHandler{
+		transferService:     transferService,
+		reencryptionService: reencryptionService,
+		migrationService:    migrationService,
+		fsckService:         fsckService,
+		scheduler:           scheduler,
+	}
+}
+
+// Exports all configurations and configuration groups as a tar.gz archive
+func (h *AdminHandler) Export(w http.ResponseWriter, r *http.Request) {
+	if !auth.FromContext(r.Context()).Can(auth.PermissionTransferStore) {
+		http.Error(w, "exporting the store requires the "+auth.PermissionTransferStore+" permission", http.StatusForbidden)
+		return
+	}
+
+	var archive bytes.Buffer
+	if err := h.transferService.Export(r.Context(), &archive); err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	filename := fmt.Sprintf("config-export-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write(archive.Bytes())
+}
+
+// Imports an archive produced by Export
+func (h *AdminHandler) Import(w http.ResponseWriter, r *http.Request) {
+	if !auth.FromContext(r.Context()).Can(auth.PermissionTransferStore) {
+		http.Error(w, "importing into the store requires the "+auth.PermissionTransferStore+" permission", http.StatusForbidden)
+		return
+	}
+
+	strategy := model.ImportStrategy(r.URL.Query().Get("strategy"))
+	if strategy == "" {
+		strategy = model.ImportFail
+	}
+
+	report, err := h.transferService.Import(r.Context(), r.Body, strategy)
+	if err != nil {
+		status := writeErrorStatus(err)
+		if errors.Is(err, services.ErrInvalidArchive) {
+			status = decodeErrorStatus(err)
+		}
+		if errors.Is(err, services.ErrImportConflict) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	resp, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// Starts re-encrypting every secret param with the primary key in the background, resuming the last
+// job if it didn't complete
+func (h *AdminHandler) StartReencryption(w http.ResponseWriter, r *http.Request) {
+	if !auth.FromContext(r.Context()).Can(auth.PermissionRotateKeys) {
+		http.Error(w, "re-encrypting secret params requires the "+auth.PermissionRotateKeys+" permission", http.StatusForbidden)
+		return
+	}
+
+	job, err := h.reencryptionService.Start(r.Context())
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrReencryptionRunning) {
+			status = http.StatusConflict
+		}
+		if errors.Is(err, services.ErrNoKeyring) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	resp, err := json.Marshal(job)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/admin/reencryption")
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(resp)
+}
+
+// Returns the progress of the running re-encryption job, or the result of the last one
+func (h *AdminHandler) GetReencryption(w http.ResponseWriter, r *http.Request) {
+	job, err := h.reencryptionService.Status(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+	if job == nil {
+		http.Error(w, "no re-encryption job was started", http.StatusNotFound)
+		return
+	}
+
+	resp, err := json.Marshal(job)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// Returns the schema version of the store, the migrations it still needs and the report of the last run
+func (h *AdminHandler) GetMigrations(w http.ResponseWriter, r *http.Request) {
+	status, err := h.migrationService.Status(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	resp, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// Runs the pending migrations of the keyspace and returns the report. With ?dryRun=true the changes are
+// only planned
+func (h *AdminHandler) RunMigrations(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := boolQuery(r, "dryRun", false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !dryRun && !auth.FromContext(r.Context()).Can(auth.PermissionMigrateStore) {
+		http.Error(w, "migrating the store requires the "+auth.PermissionMigrateStore+" permission", http.StatusForbidden)
+		return
+	}
+
+	report, err := h.migrationService.Run(r.Context(), dryRun)
+	if err != nil {
+		status := writeErrorStatus(err)
+		if errors.Is(err, services.ErrMigrationRunning) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	resp, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// Checks the keyspace for inconsistencies and reports them without changing anything
+func (h *AdminHandler) Fsck(w http.ResponseWriter, r *http.Request) {
+	h.fsck(w, r, false)
+}
+
+// Checks the keyspace and applies the repairs of the inconsistencies which can be repaired
+func (h *AdminHandler) RepairFsck(w http.ResponseWriter, r *http.Request) {
+	if !auth.FromContext(r.Context()).Can(auth.PermissionMigrateStore) {
+		http.Error(w, "repairing the store requires the "+auth.PermissionMigrateStore+" permission", http.StatusForbidden)
+		return
+	}
+	h.fsck(w, r, true)
+}
+
+func (h *AdminHandler) fsck(w http.ResponseWriter, r *http.Request, repair bool) {
+	report, err := h.fsckService.Check(r.Context(), repair)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	resp, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// Returns the recent activations and expirations of configs and the upcoming ones
+func (h *AdminHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	status, err := h.scheduler.Status(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	resp, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
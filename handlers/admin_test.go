@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"project/auth"
+	"project/services"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminHandler_TransferRequiresPermission(t *testing.T) {
+	h := NewAdminHandler(services.TransferService{}, nil, nil, services.FsckService{}, nil)
+	for _, identity := range []auth.Identity{auth.Anonymous, {Subject: "alice", Permissions: []string{auth.PermissionMigrateStore}}} {
+		ctx := auth.WithIdentity(context.Background(), identity)
+
+		rec := httptest.NewRecorder()
+		h.Export(rec, httptest.NewRequest(http.MethodGet, "/admin/export", nil).WithContext(ctx))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), auth.PermissionTransferStore)
+
+		rec = httptest.NewRecorder()
+		h.Import(rec, httptest.NewRequest(http.MethodPost, "/admin/import", strings.NewReader("")).WithContext(ctx))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), auth.PermissionTransferStore)
+	}
+}
@@ -0,0 +1,61 @@
+// The code defines an ApplyHandler struct with a method for declaratively applying a set of
+// configuration and configuration group manifests using an ApplyService.
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"project/model"
+	"project/services"
+)
+
+type ApplyHandler struct {
+	service services.ApplyService
+}
+
+func NewApplyHandler(service services.ApplyService) *ApplyHandler {
+	return &ApplyHandler{
+		service: service,
+	}
+}
+
+// Applies a list of manifests, returning the computed plan with secret params masked
+func (h *ApplyHandler) Apply(w http.ResponseWriter, r *http.Request) {
+	var manifests []model.Manifest
+	if err := decodeJSON(r, &manifests); err != nil {
+		http.Error(w, err.Error(), decodeErrorStatus(err))
+		return
+	}
+	for _, manifest := range manifests {
+		if err := manifest.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	dryRun, err := boolQuery(r, "dryRun", false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	prune, err := boolQuery(r, "prune", false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := h.service.Apply(r.Context(), manifests, dryRun, prune)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	resp, err := json.Marshal(plan.Masked())
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
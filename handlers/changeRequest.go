@@ -0,0 +1,127 @@
+// The code defines a ChangeRequestHandler struct with methods for proposing changes to configuration
+// groups and reviewing them using a ChangeRequestService. Secret params are always masked in responses.
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"project/model"
+	"project/services"
+
+	"github.com/gorilla/mux"
+)
+
+type ChangeRequestHandler struct {
+	service services.ChangeRequestService
+}
+
+func NewChangeRequestHandler(service services.ChangeRequestService) *ChangeRequestHandler {
+	return &ChangeRequestHandler{
+		service: service,
+	}
+}
+
+// changeRequestInput is the body of a new change request.
+type changeRequestInput struct {
+	Group       model.ConfigGroup `json:"group"`
+	Description string            `json:"description"`
+}
+
+// rejectionInput is the optional body of a rejection.
+type rejectionInput struct {
+	Reason string `json:"reason"`
+}
+
+// Proposes a new state of a configuration group
+func (h *ChangeRequestHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var input changeRequestInput
+	if err := decodeJSON(r, &input); err != nil {
+		http.Error(w, err.Error(), decodeErrorStatus(err))
+		return
+	}
+
+	request, err := h.service.Create(r.Context(), input.Group, input.Description)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+	writeChangeRequest(w, http.StatusCreated, request)
+}
+
+// Lists the change requests, only those with the given status if status is set
+func (h *ChangeRequestHandler) List(w http.ResponseWriter, r *http.Request) {
+	status := model.ChangeRequestStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", model.ChangeRequestPending, model.ChangeRequestApplied, model.ChangeRequestRejected:
+	default:
+		http.Error(w, "Invalid status. Expected pending, applied or rejected", http.StatusBadRequest)
+		return
+	}
+
+	requests, err := h.service.List(r.Context(), status)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+	for i := range requests {
+		requests[i] = requests[i].Masked()
+	}
+
+	resp, err := json.Marshal(requests)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// Retrieves a change request with its diff against the current group
+func (h *ChangeRequestHandler) Get(w http.ResponseWriter, r *http.Request) {
+	request, err := h.service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), readErrorStatus(err))
+		return
+	}
+	writeChangeRequest(w, http.StatusOK, request)
+}
+
+// Approves a change request, applying it once it has the required approvals
+func (h *ChangeRequestHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	request, err := h.service.Approve(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+	writeChangeRequest(w, http.StatusOK, request)
+}
+
+// Rejects a change request, with an optional reason
+func (h *ChangeRequestHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	var input rejectionInput
+	if err := decodeJSON(r, &input); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), decodeErrorStatus(err))
+		return
+	}
+
+	request, err := h.service.Reject(r.Context(), mux.Vars(r)["id"], input.Reason)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+	writeChangeRequest(w, http.StatusOK, request)
+}
+
+func writeChangeRequest(w http.ResponseWriter, status int, request model.ChangeRequest) {
+	resp, err := json.Marshal(request.Masked())
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
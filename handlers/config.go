@@ -24,13 +24,13 @@ func NewConfigHandler(service services.ConfigService) *ConfigHandler {
 // Adds a new configuration
 func (c ConfigHandler) Add(w http.ResponseWriter, r *http.Request) {
 	var config model.Config
-	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := decodeJSON(r, &config); err != nil {
+		http.Error(w, err.Error(), decodeErrorStatus(err))
 		return
 	}
 
-	if err := c.service.Add(config); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := c.service.Add(r.Context(), config); err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
 		return
 	}
 
@@ -38,20 +38,56 @@ func (c ConfigHandler) Add(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Config successfully added"))
 }
 
-// Retrieves a configuration
+// Retrieves a configuration, with the params inherited from its parents and references resolved
+// unless effective=false. With strict=true unresolved references are an error. Secret params are
+// masked unless reveal=true.
 func (c ConfigHandler) Get(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
 	version := mux.Vars(r)["version"]
 
-	config, err := c.service.Get(name, version)
+	effective, err := boolQuery(r, "effective", true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	strict, err := boolQuery(r, "strict", false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reveal, status, err := revealQuery(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), status)
 		return
 	}
 
+	var config interface{}
+	if effective {
+		resolved, err := c.service.Resolve(r.Context(), name, version, strict)
+		if err != nil {
+			http.Error(w, err.Error(), readErrorStatus(err))
+			return
+		}
+		if !reveal {
+			resolved.Config = resolved.Masked()
+		}
+		config = resolved
+	} else {
+		raw, err := c.service.GetRaw(r.Context(), name, version)
+		if err != nil {
+			http.Error(w, err.Error(), readErrorStatus(err))
+			return
+		}
+		if !reveal {
+			raw = raw.Masked()
+		}
+		config = raw
+	}
+
 	resp, err := json.Marshal(config)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), writeErrorStatus(err))
 		return
 	}
 
@@ -64,8 +100,8 @@ func (c ConfigHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
 	version := mux.Vars(r)["version"]
 
-	if err := c.service.Delete(name, version); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	if err := c.service.Delete(r.Context(), name, version); err != nil {
+		http.Error(w, err.Error(), deleteErrorStatus(err))
 		return
 	}
 
@@ -4,6 +4,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"project/model"
 	"project/services"
@@ -25,13 +26,13 @@ func NewConfigGroupHandler(repo services.ConfigGroupService) *ConfigGroupHandler
 // Adds a new configuration group
 func (h *ConfigGroupHandler) AddGroup(w http.ResponseWriter, r *http.Request) {
 	var group model.ConfigGroup
-	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := decodeJSON(r, &group); err != nil {
+		http.Error(w, err.Error(), decodeErrorStatus(err))
 		return
 	}
 
-	if err := h.repo.Add(group); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.repo.Add(r.Context(), group); err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
 		return
 	}
 
@@ -39,20 +40,46 @@ func (h *ConfigGroupHandler) AddGroup(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Config group successfully added"))
 }
 
-// Retrieves a configuration group
+// Retrieves a configuration group, with references in the params resolved unless effective=false.
+// With strict=true unresolved references are an error. Secret params are masked unless reveal=true.
 func (h *ConfigGroupHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
 	version := mux.Vars(r)["version"]
 
-	group, err := h.repo.Get(name, version)
+	effective, err := boolQuery(r, "effective", true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	strict, err := boolQuery(r, "strict", false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reveal, status, err := revealQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	var group model.ConfigGroup
+	if effective {
+		group, err = h.repo.Resolve(r.Context(), name, version, strict)
+	} else {
+		group, err = h.repo.Get(r.Context(), name, version)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), readErrorStatus(err))
 		return
 	}
+	if !reveal {
+		group = group.Masked()
+	}
 
 	resp, err := json.Marshal(group)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), writeErrorStatus(err))
 		return
 	}
 
@@ -65,8 +92,8 @@ func (h *ConfigGroupHandler) RemoveGroup(w http.ResponseWriter, r *http.Request)
 	name := mux.Vars(r)["name"]
 	version := mux.Vars(r)["version"]
 
-	if err := h.repo.Delete(name, version); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	if err := h.repo.Delete(r.Context(), name, version); err != nil {
+		http.Error(w, err.Error(), deleteErrorStatus(err))
 		return
 	}
 
@@ -82,8 +109,8 @@ func (h *ConfigGroupHandler) AddConfigToGroup(w http.ResponseWriter, r *http.Req
 	configName := mux.Vars(r)["configName"]
 	configVersion := mux.Vars(r)["configVersion"]
 
-	if err := h.repo.AddConfigToGroup(groupName, version, configName, configVersion); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.repo.AddConfigToGroup(r.Context(), groupName, version, configName, configVersion); err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
 		return
 	}
 
@@ -99,8 +126,8 @@ func (h *ConfigGroupHandler) RemoveConfigFromGroup(w http.ResponseWriter, r *htt
 	configName := mux.Vars(r)["configName"]
 	configVersion := mux.Vars(r)["configVersion"]
 
-	if err := h.repo.RemoveConfigFromGroup(groupName, groupVersion, configName, configVersion); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	if err := h.repo.RemoveConfigFromGroup(r.Context(), groupName, groupVersion, configName, configVersion); err != nil {
+		http.Error(w, err.Error(), deleteErrorStatus(err))
 		return
 	}
 
@@ -114,13 +141,13 @@ func (h *ConfigGroupHandler) AddConfigWithLabelToGroup(w http.ResponseWriter, r
 	version := mux.Vars(r)["version"]
 
 	var config model.ConfigWithLabels
-	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := decodeJSON(r, &config); err != nil {
+		http.Error(w, err.Error(), decodeErrorStatus(err))
 		return
 	}
 
-	if err := h.repo.AddConfigWithLabelToGroup(groupName, version, config); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.repo.AddConfigWithLabelToGroup(r.Context(), groupName, version, config); err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
 		return
 	}
 
@@ -154,8 +181,8 @@ func (h *ConfigGroupHandler) RemoveConfigsWithLabelsFromGroup(w http.ResponseWri
 		labels = append(labels, model.Label{Key: parts[0], Value: parts[1]})
 	}
 
-	if err := h.repo.RemoveConfigsWithLabelsFromGroup(groupName, version, labels, configName, configVersion); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.repo.RemoveConfigsWithLabelsFromGroup(r.Context(), groupName, version, labels, configName, configVersion); err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
 		return
 	}
 
@@ -189,9 +216,24 @@ func (h *ConfigGroupHandler) SearchConfigsWithLabelsInGroup(w http.ResponseWrite
 		searchLabels = append(searchLabels, model.Label{Key: parts[0], Value: parts[1]})
 	}
 
-	configs, err := h.repo.SearchConfigsWithLabelsInGroup(groupName, version, searchLabels, configName, configVersion)
+	strict, err := boolQuery(r, "strict", false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reveal, status, err := revealQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	configs, err := h.repo.SearchConfigsWithLabelsInGroup(r.Context(), groupName, version, searchLabels, configName, configVersion, strict)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := writeErrorStatus(err)
+		if errors.Is(err, services.ErrUnresolvedReference) {
+			status = http.StatusUnprocessableEntity
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
@@ -199,10 +241,13 @@ func (h *ConfigGroupHandler) SearchConfigsWithLabelsInGroup(w http.ResponseWrite
 		http.Error(w, "No configs with all labels found", http.StatusNotFound)
 		return
 	}
+	if !reveal {
+		configs = model.MaskConfigs(configs)
+	}
 
 	resp, err := json.Marshal(configs)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), writeErrorStatus(err))
 		return
 	}
 
@@ -0,0 +1,78 @@
+// The helpers below decode JSON request bodies strictly and map decoding errors to HTTP status codes.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"project/model"
+)
+
+// decodeJSON decodes the body into v. Fields v doesn't have and data after the JSON value are errors,
+// so typos in field names don't silently drop params.
+func decodeJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("request body must hold a single JSON value")
+	}
+	return nil
+}
+
+// decodeErrorStatus maps an error reading the body to 413 if the body was too large, 400 otherwise.
+func decodeErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// writeErrorStatus maps an error of a write to 400 if the input was invalid, 403 if the caller may not
+// make the write, 404 if the change request or trash entry it targets doesn't exist, 409 if the change
+// request was already closed, the restored object exists or an applied object was written concurrently,
+// 423 if it would remove a locked object, 504 if the request ran out of time, 500 otherwise.
+func writeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, model.ErrProtected), errors.Is(err, model.ErrNotReviewer):
+		return http.StatusForbidden
+	case errors.Is(err, model.ErrChangeRequestNotFound), errors.Is(err, model.ErrTrashEntryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrChangeRequestClosed), errors.Is(err, model.ErrRestoreConflict), errors.Is(err, model.ErrApplyConflict):
+		return http.StatusConflict
+	case errors.Is(err, model.ErrLocked):
+		return http.StatusLocked
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// deleteErrorStatus maps an error of a delete to 403 if the target is protected or its lock is owned by
+// another caller, 423 if it is locked, 504 if the request ran out of time, 404 otherwise.
+func deleteErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrProtected), errors.Is(err, model.ErrNotLockOwner):
+		return http.StatusForbidden
+	case errors.Is(err, model.ErrLocked):
+		return http.StatusLocked
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusNotFound
+}
+
+// lockErrorStatus maps an error locking an object to 400 if no reason was given, like deleteErrorStatus
+// otherwise, so an anonymous caller is 403, a missing object 404 and one which is already locked 423.
+func lockErrorStatus(err error) int {
+	if errors.Is(err, model.ErrInvalid) {
+		return http.StatusBadRequest
+	}
+	return deleteErrorStatus(err)
+}
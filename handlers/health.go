@@ -0,0 +1,57 @@
+// The code defines a HealthHandler struct with the liveness, readiness and status endpoints probed by
+// orchestrators and load balancers, using a HealthService.
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"project/model"
+	"project/services"
+)
+
+type HealthHandler struct {
+	healthService *services.HealthService
+}
+
+func NewHealthHandler(healthService *services.HealthService) *HealthHandler {
+	return &HealthHandler{
+		healthService: healthService,
+	}
+}
+
+// Reports that the process is alive and serving requests, without probing the store
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Reports whether the server is ready to serve requests, with 503 Service Unavailable when the store
+// can't be reached, a readiness check fails or the server is shutting down
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := h.healthService.Ready(r.Context(), false)
+	writeJSON(w, readyStatus(report), report)
+}
+
+// Reports the readiness checks together with the latency, leader and peers of the store
+func (h *HealthHandler) Status(w http.ResponseWriter, r *http.Request) {
+	report := h.healthService.Ready(r.Context(), true)
+	writeJSON(w, readyStatus(report), report)
+}
+
+func readyStatus(report model.HealthReport) int {
+	if report.Ready {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
@@ -0,0 +1,114 @@
+// The code defines a LockHandler struct with methods for locking configurations and configuration
+// groups against deletion, unlocking them and listing the locks using a LockService.
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"project/model"
+	"project/services"
+
+	"github.com/gorilla/mux"
+)
+
+type LockHandler struct {
+	service services.LockService
+}
+
+func NewLockHandler(service services.LockService) *LockHandler {
+	return &LockHandler{
+		service: service,
+	}
+}
+
+// lockInput is the body of a new lock.
+type lockInput struct {
+	Reason string `json:"reason"`
+}
+
+// Locks a configuration
+func (h *LockHandler) LockConfig(w http.ResponseWriter, r *http.Request) {
+	h.lock(w, r, model.KindConfig)
+}
+
+// Unlocks a configuration
+func (h *LockHandler) UnlockConfig(w http.ResponseWriter, r *http.Request) {
+	h.unlock(w, r, model.KindConfig)
+}
+
+// Retrieves the lock of a configuration
+func (h *LockHandler) GetConfigLock(w http.ResponseWriter, r *http.Request) {
+	h.get(w, r, model.KindConfig)
+}
+
+// Locks a configuration group
+func (h *LockHandler) LockGroup(w http.ResponseWriter, r *http.Request) {
+	h.lock(w, r, model.KindConfigGroup)
+}
+
+// Unlocks a configuration group
+func (h *LockHandler) UnlockGroup(w http.ResponseWriter, r *http.Request) {
+	h.unlock(w, r, model.KindConfigGroup)
+}
+
+// Retrieves the lock of a configuration group
+func (h *LockHandler) GetGroupLock(w http.ResponseWriter, r *http.Request) {
+	h.get(w, r, model.KindConfigGroup)
+}
+
+// Lists the locks of all configurations and configuration groups
+func (h *LockHandler) List(w http.ResponseWriter, r *http.Request) {
+	locks, err := h.service.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+	writeLock(w, http.StatusOK, locks)
+}
+
+func (h *LockHandler) lock(w http.ResponseWriter, r *http.Request, kind string) {
+	var input lockInput
+	if err := decodeJSON(r, &input); err != nil {
+		http.Error(w, err.Error(), decodeErrorStatus(err))
+		return
+	}
+
+	lock, err := h.service.Lock(r.Context(), kind, mux.Vars(r)["name"], mux.Vars(r)["version"], input.Reason)
+	if err != nil {
+		http.Error(w, err.Error(), lockErrorStatus(err))
+		return
+	}
+	writeLock(w, http.StatusCreated, lock)
+}
+
+func (h *LockHandler) unlock(w http.ResponseWriter, r *http.Request, kind string) {
+	if err := h.service.Unlock(r.Context(), kind, mux.Vars(r)["name"], mux.Vars(r)["version"]); err != nil {
+		http.Error(w, err.Error(), deleteErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(kind + " successfully unlocked"))
+}
+
+func (h *LockHandler) get(w http.ResponseWriter, r *http.Request, kind string) {
+	lock, err := h.service.Get(r.Context(), kind, mux.Vars(r)["name"], mux.Vars(r)["version"])
+	if err != nil {
+		http.Error(w, err.Error(), readErrorStatus(err))
+		return
+	}
+	writeLock(w, http.StatusOK, lock)
+}
+
+// writeLock writes a lock or a list of locks.
+func writeLock(w http.ResponseWriter, status int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
@@ -0,0 +1,49 @@
+// The helpers below read the query parameters shared by several handlers and map service errors of
+// reads to HTTP status codes.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"project/auth"
+	"project/services"
+	"strconv"
+)
+
+// boolQuery reads an optional boolean query parameter, returning defaultValue when it is not set.
+func boolQuery(r *http.Request, name string, defaultValue bool) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// revealQuery reads the reveal query parameter, which asks for secret params in plain text. Only
+// callers holding the secrets:reveal permission may set it; for others an error and its status code
+// are returned.
+func revealQuery(r *http.Request) (bool, int, error) {
+	reveal, err := boolQuery(r, "reveal", false)
+	if err != nil {
+		return false, http.StatusBadRequest, err
+	}
+	if reveal && !auth.FromContext(r.Context()).Can(auth.PermissionRevealSecrets) {
+		return false, http.StatusForbidden, errors.New("revealing secret params requires the " + auth.PermissionRevealSecrets + " permission")
+	}
+	return reveal, http.StatusOK, nil
+}
+
+// readErrorStatus returns the status code for an error returned while reading and resolving a config
+// or a group.
+func readErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUnresolvedReference):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrInheritanceCycle), errors.Is(err, services.ErrInterpolationCycle):
+		return http.StatusInternalServerError
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusNotFound
+}
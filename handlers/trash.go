@@ -0,0 +1,53 @@
+// The code defines a TrashHandler struct with methods for browsing the deleted configurations and
+// configuration groups and restoring them using a TrashService.
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"project/services"
+
+	"github.com/gorilla/mux"
+)
+
+type TrashHandler struct {
+	service services.TrashService
+}
+
+func NewTrashHandler(service services.TrashService) *TrashHandler {
+	return &TrashHandler{
+		service: service,
+	}
+}
+
+// Lists the entries of the trash, oldest first
+func (h *TrashHandler) List(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.service.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+	writeTrashEntry(w, entries)
+}
+
+// Restores a deleted configuration or configuration group
+func (h *TrashHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	entry, err := h.service.Restore(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+	writeTrashEntry(w, entry)
+}
+
+// writeTrashEntry writes an entry or a list of entries.
+func writeTrashEntry(w http.ResponseWriter, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), writeErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
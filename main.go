@@ -3,32 +3,186 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
 	"project/api"
+	"project/auth"
 	"project/data"
 	"project/handlers"
+	"project/logging"
+	"project/model"
 	"project/repositories"
+	"project/secrets"
 	"project/services"
+	"project/settings"
+	"project/tracing"
+	"syscall"
 )
 
 func main() {
+	// Loading and validation of the settings from the settings file, environment and flags
+	cfg, err := settings.Load(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Error loading settings: %v", err)
+	}
+	level, _ := cfg.Log.SlogLevel()
+	slog.SetDefault(slog.New(logging.NewHandler(os.Stderr, cfg.Log.Format, level)))
+
+	// Initialisation of tracing, spans are exported until the server exits
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Error initializing tracing: %v", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("Error flushing traces: %v", err)
+		}
+	}()
+
 	// Initialisation of database
-	db, err := data.NewDatabase()
+	options := data.Options{
+		Address:      cfg.Storage.Consul.Address,
+		Scheme:       cfg.Storage.Consul.Scheme,
+		Datacenter:   cfg.Storage.Consul.Datacenter,
+		Token:        cfg.Storage.Consul.Token,
+		ReadTimeout:  cfg.Storage.Consul.ReadTimeout,
+		WriteTimeout: cfg.Storage.Consul.WriteTimeout,
+	}
+	if cfg.Storage.Cache.Enabled {
+		options.Cache = data.CacheOptions{
+			Prefixes: repositories.CachedPrefixes,
+			MaxStale: cfg.Storage.Cache.MaxStale,
+			WaitTime: cfg.Storage.Cache.WaitTime,
+		}
+	}
+	db, err := data.NewDatabase(options)
 	if err != nil {
 		log.Fatalf("Error initializing database: %v", err)
 	}
+	// Watching of the cached prefixes, reads go to Consul until the first copy was read
+	go db.WatchCache(context.Background())
 
+	// Loading of the keyring used to encrypt secret params, if configured
+	var keyring *secrets.Keyring
+	if path := cfg.Secrets.KeyringFile; path != "" {
+		keyring, err = secrets.LoadKeyring(path)
+		if err != nil {
+			log.Fatalf("Error loading keyring: %v", err)
+		}
+		// Reloading of the keyring on SIGHUP, so keys can be rotated without a restart
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+		go func() {
+			for range reload {
+				if err := keyring.Reload(); err != nil {
+					log.Printf("Error reloading keyring, keeping the previous keys: %v", err)
+					continue
+				}
+				log.Printf("Keyring reloaded, primary key is %s", keyring.Primary())
+			}
+		}()
+	}
+	// Loading of the API tokens identifying callers, if configured
+	var tokens *auth.TokenStore
+	if path := cfg.Auth.TokensFile; path != "" {
+		tokens, err = auth.LoadTokenStore(path)
+		if err != nil {
+			log.Fatalf("Error loading tokens: %v", err)
+		}
+	}
+
+	// Locks are checked by the services deleting configs and groups
+	lockRepo := repositories.NewLockDBRepository(db)
 	// Initialisation of repositories, services, and handlers for Config
-	configRepo := repositories.NewConfigDBRepository(db)
-	configService := services.NewConfigService(configRepo)
+	configRepo := repositories.NewConfigDBRepository(db, keyring)
+	configService := services.NewConfigService(configRepo, lockRepo)
 	configHandler := handlers.NewConfigHandler(configService)
 	// Initialisation of repositories, services, and handlers for ConfigGroup
-	configGroupRepo := repositories.NewConfigGroupDBRepository(db)
-	configGroupService := services.NewConfigGroupService(configGroupRepo)
+	configGroupRepo := repositories.NewConfigGroupDBRepository(db, keyring)
+	configGroupService := services.NewConfigGroupService(configGroupRepo, lockRepo, configService)
 	configGroupHandler := handlers.NewConfigGroupHandler(configGroupService)
+	// Initialisation of repositories, services, and handlers for ChangeRequest
+	changeRequestRepo := repositories.NewChangeRequestDBRepository(db, keyring)
+	changeRequestService := services.NewChangeRequestService(changeRequestRepo, configGroupRepo, lockRepo, cfg.ChangeRequests.Approvals)
+	changeRequestHandler := handlers.NewChangeRequestHandler(changeRequestService)
+	// Initialisation of services and handlers for Lock
+	lockService := services.NewLockService(lockRepo, configRepo, configGroupRepo)
+	lockHandler := handlers.NewLockHandler(lockService)
+	// Initialisation of repositories, services, and handlers for Trash
+	trashService := services.NewTrashService(repositories.NewTrashDBRepository(db), cfg.Trash.Retention)
+	trashHandler := handlers.NewTrashHandler(trashService)
+	// Initialisation of repositories, services, and handlers for Apply
+	applyRepo := repositories.NewApplyDBRepository(db, keyring)
+	applyService := services.NewApplyService(configRepo, configGroupRepo, lockRepo, applyRepo)
+	applyHandler := handlers.NewApplyHandler(applyService)
+	// Initialisation of services and handlers for Admin
+	transferService := services.NewTransferService(configRepo, configGroupRepo, lockRepo, applyRepo, keyring)
+	reencryptionRepo := repositories.NewReencryptionDBRepository(db, keyring)
+	reencryptionService := services.NewReencryptionService(reencryptionRepo, keyring)
+	migrationRepo := repositories.NewMigrationDBRepository(db)
+	migrationService := services.NewMigrationService(migrationRepo)
+	fsckService := services.NewFsckService(repositories.NewStoreCheckDBRepository(db))
+	scheduler := services.NewScheduler(repositories.NewScheduleDBRepository(db), configRepo, configGroupRepo, lockRepo)
+	adminHandler := handlers.NewAdminHandler(transferService, reencryptionService, migrationService, fsckService, scheduler)
+	// Resuming of a re-encryption job interrupted by a restart
+	if job, err := reencryptionService.Resume(context.Background()); err != nil {
+		log.Printf("Error resuming re-encryption job: %v", err)
+	} else if job != nil {
+		log.Printf("Resumed re-encryption job %s after %s", job.ID, job.Cursor)
+	}
+	// Initialisation of repositories, services, and handlers for Health
+	statusRepo := repositories.NewStatusDBRepository(db)
+	healthService := services.NewHealthService(statusRepo)
+	healthHandler := handlers.NewHealthHandler(healthService)
+	// Migrating of the keyspace in the background, the server reports not ready until it is done
+	if cfg.Storage.Migrations != settings.MigrationsOff {
+		dryRun := cfg.Storage.Migrations == settings.MigrationsDryRun
+		if !dryRun {
+			healthService.AddCheck("migrations", migrationService.Ready)
+		}
+		go func() {
+			report, err := migrationService.Run(context.Background(), dryRun)
+			logMigrations(report, err)
+		}()
+	}
+	// Activating configs and deleting expired ones in the background
+	if cfg.Scheduler.Interval > 0 {
+		go scheduler.Run(context.Background(), cfg.Scheduler.Interval)
+	}
+	// Purging the trash entries past their retention
+	if cfg.Trash.PurgeInterval > 0 {
+		go trashService.Run(context.Background(), cfg.Trash.PurgeInterval)
+	}
 	// Creating a new router
-	router := api.NewRouter(configHandler, configGroupHandler)
+	router := api.NewRouter(configHandler, configGroupHandler, changeRequestHandler, lockHandler, trashHandler, applyHandler, adminHandler, healthHandler, tokens, cfg.RateLimit, cfg.Server.Routes)
 
 	// Running the server
-	api.RunServer(router)
+	api.RunServer(router, cfg.Server, cfg.TLS, healthService.SetShuttingDown)
+}
+
+// logMigrations logs the outcome of the migrations run at startup.
+func logMigrations(report model.MigrationReport, err error) {
+	if err != nil {
+		log.Printf("Error migrating the store: %v", err)
+		return
+	}
+	for _, result := range report.Migrations {
+		if report.DryRun {
+			log.Printf("Migration %d %s would change %d keys and leave %d in place", result.Version, result.Name, len(result.Changes), len(result.Conflicts))
+		} else {
+			log.Printf("Migration %d %s changed %d keys and left %d in place", result.Version, result.Name, len(result.Changes), len(result.Conflicts))
+		}
+		for _, conflict := range result.Conflicts {
+			log.Printf("Migration %d %s left %s", result.Version, result.Name, conflict)
+		}
+	}
+	log.Printf("Store schema is at version %d", report.ToVersion)
 }
@@ -0,0 +1,132 @@
+// Package metrics defines the Prometheus collectors of the server, registered with the default
+// registry and exposed on /metrics together with the Go runtime and process metrics.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "config"
+
+var (
+	// HTTPRequests counts the requests by route template, method and status code
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	// HTTPRequestDuration observes the time spent serving requests by route template and method
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time spent serving HTTP requests by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// RateLimited counts the requests rejected with 429 Too Many Requests by rate-limit policy
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected by the rate limiter by policy.",
+	}, []string{"policy"})
+
+	// RepositoryDuration observes the time spent in repository methods
+	RepositoryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "operation_duration_seconds",
+		Help:      "Time spent in repository methods by repository and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"repository", "method"})
+
+	// StoreOperations counts the operations sent to the store
+	StoreOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "store",
+		Name:      "operations_total",
+		Help:      "Operations sent to the Consul store by operation.",
+	}, []string{"operation"})
+
+	// StoreErrors counts the store operations which failed
+	StoreErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "store",
+		Name:      "operation_errors_total",
+		Help:      "Operations sent to the Consul store which failed by operation.",
+	}, []string{"operation"})
+
+	// StoreDuration observes the round trip time of store operations
+	StoreDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "store",
+		Name:      "operation_duration_seconds",
+		Help:      "Round trip time of operations sent to the Consul store by operation.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+
+	// CacheRequests counts the reads of cached prefixes, served from memory or not
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "store_cache",
+		Name:      "requests_total",
+		Help:      "Reads of cached store prefixes by prefix and result, hit when served from memory.",
+	}, []string{"prefix", "result"})
+
+	// CacheEntries is the number of keys held in memory
+	CacheEntries = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "store_cache",
+		Name:      "entries",
+		Help:      "Keys of the store held in memory by cached prefix.",
+	}, []string{"prefix"})
+
+	// ScheduleEvents counts the activations and expirations handled by the scheduler
+	ScheduleEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "schedule",
+		Name:      "events_total",
+		Help:      "Configs activated and expired configs deleted by the scheduler by kind.",
+	}, []string{"kind"})
+)
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveStore records a store operation started at start, counting it as failed if err isn't nil.
+func ObserveStore(operation string, start time.Time, err error) {
+	StoreOperations.WithLabelValues(operation).Inc()
+	StoreDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		StoreErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// ObserveCache records a read of a cached prefix, a hit if it was served from memory.
+func ObserveCache(prefix string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheRequests.WithLabelValues(prefix, result).Inc()
+}
+
+// ObserveRepository starts timing a repository method, the returned func records its duration, e.g.
+//
+//	defer metrics.ObserveRepository("config", "Get")()
+func ObserveRepository(repository string, method string) func() {
+	start := time.Now()
+	return func() {
+		RepositoryDuration.WithLabelValues(repository, method).Observe(time.Since(start).Seconds())
+	}
+}
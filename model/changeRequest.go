@@ -0,0 +1,84 @@
+// Package model defines the change requests which propose a new state of a config group.
+//
+// A ChangeRequest holds the whole proposed group. It stays pending until enough reviewers approved it,
+// and is then applied by replacing the group with the proposed one and closing the request in a single
+// transaction. Protected groups are only changed this way.
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrChangeRequestNotFound is returned when no change request has the ID.
+var ErrChangeRequestNotFound = errors.New("change request not found")
+
+// ErrChangeRequestClosed is returned when a change request which was already applied or rejected is
+// approved or rejected.
+var ErrChangeRequestClosed = errors.New("change request is closed")
+
+// ErrNotReviewer is returned when a caller without the review permission, or the author of the change
+// request, approves or rejects it.
+var ErrNotReviewer = errors.New("caller can't review the change request")
+
+type ChangeRequestStatus string
+
+const (
+	ChangeRequestPending  ChangeRequestStatus = "pending"
+	ChangeRequestApplied  ChangeRequestStatus = "applied"
+	ChangeRequestRejected ChangeRequestStatus = "rejected"
+)
+
+type Approval struct {
+	Reviewer string    `json:"reviewer"`
+	At       time.Time `json:"at"`
+}
+
+type ChangeRequest struct {
+	ID string `json:"id"`
+	// Group is the proposed state of the group, which replaces the stored one once applied
+	Group       ConfigGroup         `json:"group"`
+	Description string              `json:"description,omitempty"`
+	Author      string              `json:"author"`
+	Status      ChangeRequestStatus `json:"status"`
+	// RequiredApprovals is the number of distinct reviewers, other than the author, who must approve
+	RequiredApprovals int        `json:"requiredApprovals"`
+	Approvals         []Approval `json:"approvals"`
+	RejectedBy        string     `json:"rejectedBy,omitempty"`
+	Reason            string     `json:"reason,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	ClosedAt          *time.Time `json:"closedAt,omitempty"`
+	// Diff turns the stored group into the proposed one. It is computed against the current group
+	// while the request is pending, and kept as applied once it was
+	Diff *GroupDiff `json:"diff,omitempty"`
+}
+
+// Masked returns a copy of the change request with the values of secret params replaced by SecretMask.
+func (c ChangeRequest) Masked() ChangeRequest {
+	c.Group = c.Group.Masked()
+	return c
+}
+
+// ApprovedBy reports whether the reviewer already approved the change request.
+func (c ChangeRequest) ApprovedBy(reviewer string) bool {
+	for _, approval := range c.Approvals {
+		if approval.Reviewer == reviewer {
+			return true
+		}
+	}
+	return false
+}
+
+type ChangeRequestRepository interface {
+	Add(ctx context.Context, request ChangeRequest) error
+	// Get returns the change request together with the index to pass to Save and Apply
+	Get(ctx context.Context, id string) (ChangeRequest, uint64, error)
+	List(ctx context.Context) ([]ChangeRequest, error)
+	// Save saves the change request unless it was modified since index was read, and reports whether
+	// it was saved
+	Save(ctx context.Context, request ChangeRequest, index uint64) (bool, error)
+	// Apply replaces the group with the proposed one and saves the change request, unless the change
+	// request was modified since index was read, and reports whether it was applied
+	Apply(ctx context.Context, request ChangeRequest, index uint64) (bool, error)
+}
@@ -1,17 +1,95 @@
 // Package model defines the Config struct and its repository interface.
 //
-// Config holds a name, version, and parameters.
+// Config holds a name, version, and parameters, and may extend a parent config given as name@version.
+// Params listed in Secrets are encrypted at rest and masked when read.
+// ActiveFrom and ActiveUntil limit the time the config is in effect, e.g. to a maintenance window.
+// ResolvedConfig is a Config with the params inherited from its parents merged in.
 // ConfigRepository outlines the required methods for a config repository.
 package model
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrConfigNotFound is returned when no config is stored with the name and version.
+var ErrConfigNotFound = errors.New("no configuration found")
+
+// ErrNotActive is returned when a config is read outside of its ActiveFrom and ActiveUntil window.
+var ErrNotActive = errors.New("config is not active")
+
 type Config struct {
-	Name    string            `json:"name"`
-	Version string            `json:"version"`
-	Params  map[string]string `json:"params"`
+	Name    string            `json:"name" yaml:"name"`
+	Version string            `json:"version" yaml:"version"`
+	Params  map[string]string `json:"params" yaml:"params"`
+	Extends string            `json:"extends,omitempty" yaml:"extends,omitempty"`
+	Secrets []string          `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+	// ActiveFrom and ActiveUntil bound the time the config is in effect, nil leaves it unbounded. An
+	// expired config is deleted by the scheduler
+	ActiveFrom  *time.Time `json:"activeFrom,omitempty" yaml:"activeFrom,omitempty"`
+	ActiveUntil *time.Time `json:"activeUntil,omitempty" yaml:"activeUntil,omitempty"`
+}
+
+// SecretMask replaces the value of secret params in responses.
+const SecretMask = "******"
+
+type ResolvedConfig struct {
+	Config
+	// Provenance maps every param to the name@version of the layer it came from
+	Provenance map[string]string `json:"provenance"`
+	// Layers lists the configs which were merged, from the root parent to the config itself
+	Layers []string `json:"layers"`
+}
+
+// IsSecret reports whether the param is listed in Secrets.
+func (c Config) IsSecret(key string) bool {
+	for _, secret := range c.Secrets {
+		if secret == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveAt reports whether the config is in effect at t, from ActiveFrom included to ActiveUntil
+// excluded.
+func (c Config) ActiveAt(t time.Time) bool {
+	return c.CheckActive(t) == nil
+}
+
+// CheckActive returns an error wrapping ErrNotActive if the config isn't in effect at t.
+func (c Config) CheckActive(t time.Time) error {
+	ref := Ref{Name: c.Name, Version: c.Version}
+	if c.ActiveFrom != nil && t.Before(*c.ActiveFrom) {
+		return fmt.Errorf("%w: %s is active from %s", ErrNotActive, ref, c.ActiveFrom.UTC().Format(time.RFC3339))
+	}
+	if c.ActiveUntil != nil && !t.Before(*c.ActiveUntil) {
+		return fmt.Errorf("%w: %s expired at %s", ErrNotActive, ref, c.ActiveUntil.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Masked returns a copy of the config with the values of secret params replaced by SecretMask.
+func (c Config) Masked() Config {
+	if len(c.Secrets) == 0 {
+		return c
+	}
+	params := make(map[string]string, len(c.Params))
+	for key, value := range c.Params {
+		if c.IsSecret(key) {
+			value = SecretMask
+		}
+		params[key] = value
+	}
+	c.Params = params
+	return c
 }
 
 type ConfigRepository interface {
-	Add(config Config) error
-	Get(name string, version string) (Config, error)
-	Delete(name string, version string) error
+	Add(ctx context.Context, config Config) error
+	Get(ctx context.Context, name string, version string) (Config, error)
+	Delete(ctx context.Context, name string, version string) error
+	List(ctx context.Context) ([]Config, error)
 }
@@ -1,34 +1,72 @@
 // Package model defines the ConfigGroup struct and its repository interface.
 //
-// ConfigGroup holds a name, version, and a list of ConfigWithLabels.
+// ConfigGroup holds a name, version, a list of ConfigWithLabels, and variables its configs can reference.
+// A protected group is only changed through an approved ChangeRequest.
 // ConfigWithLabels is a Config with an additional Labels field.
 // Label represents a key-value pair.
 // ConfigGroupRepository outlines the required methods for a config group repository.
 package model
 
+import (
+	"context"
+	"errors"
+)
+
+// ErrGroupNotFound is returned when a group has no key in the store.
+var ErrGroupNotFound = errors.New("configGroup not found")
+
+// ErrProtected is returned when a protected group is written to directly instead of through a change
+// request.
+var ErrProtected = errors.New("group is protected")
+
 type Label struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	Key   string `json:"key" yaml:"key"`
+	Value string `json:"value" yaml:"value"`
 }
 
 type ConfigWithLabels struct {
-	Config
-	Labels []Label `json:"labels"`
+	Config `yaml:",inline"`
+	Labels []Label `json:"labels" yaml:"labels"`
 }
 
 type ConfigGroup struct {
-	Name    string              `json:"name"`
-	Version string              `json:"version"`
-	Configs []*ConfigWithLabels `json:"configs"`
+	Name    string              `json:"name" yaml:"name"`
+	Version string              `json:"version" yaml:"version"`
+	Configs []*ConfigWithLabels `json:"configs" yaml:"configs"`
+	// Variables can be referenced from the params of the group configs as ${group:name}
+	Variables map[string]string `json:"variables,omitempty" yaml:"variables,omitempty"`
+	// Protected groups refuse direct writes, changes to them go through change requests
+	Protected bool `json:"protected,omitempty" yaml:"protected,omitempty"`
+}
+
+// Masked returns a copy of the group with the values of secret params replaced by SecretMask.
+func (g ConfigGroup) Masked() ConfigGroup {
+	g.Configs = MaskConfigs(g.Configs)
+	return g
+}
+
+// MaskConfigs returns copies of the configs with the values of secret params replaced by SecretMask.
+func MaskConfigs(configs []*ConfigWithLabels) []*ConfigWithLabels {
+	if configs == nil {
+		return nil
+	}
+	masked := make([]*ConfigWithLabels, 0, len(configs))
+	for _, config := range configs {
+		copied := *config
+		copied.Config = config.Config.Masked()
+		masked = append(masked, &copied)
+	}
+	return masked
 }
 
 type ConfigGroupRepository interface {
-	Add(configGroup ConfigGroup) error
-	Get(name string, version string) (ConfigGroup, error)
-	Delete(name string, version string) error
-	AddConfigToGroup(groupName string, version string, configName string, configVersion string) error
-	RemoveConfigFromGroup(groupName string, version string, configName string, configVersion string) error
-	AddConfigWithLabelToGroup(groupName string, version string, config ConfigWithLabels) error
-	SearchConfigsWithLabelsInGroup(groupName string, version string, labels []Label, configName string, configVersion string) ([]*ConfigWithLabels, error)
-	RemoveConfigsWithLabelsFromGroup(groupName string, version string, labels []Label, configName string, configVersion string) error
+	Add(ctx context.Context, configGroup ConfigGroup) error
+	Get(ctx context.Context, name string, version string) (ConfigGroup, error)
+	Delete(ctx context.Context, name string, version string) error
+	List(ctx context.Context) ([]ConfigGroup, error)
+	AddConfigToGroup(ctx context.Context, groupName string, version string, configName string, configVersion string) error
+	RemoveConfigFromGroup(ctx context.Context, groupName string, version string, configName string, configVersion string) error
+	AddConfigWithLabelToGroup(ctx context.Context, groupName string, version string, config ConfigWithLabels) error
+	SearchConfigsWithLabelsInGroup(ctx context.Context, groupName string, version string, labels []Label, configName string, configVersion string) ([]*ConfigWithLabels, error)
+	RemoveConfigsWithLabelsFromGroup(ctx context.Context, groupName string, version string, labels []Label, configName string, configVersion string) error
 }
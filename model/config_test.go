@@ -0,0 +1,25 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_CheckActive(t *testing.T) {
+	from := time.Date(2026, 1, 1, 22, 0, 0, 0, time.UTC)
+	until := from.Add(2 * time.Hour)
+	config := Config{Name: "db", Version: "1.0", ActiveFrom: &from, ActiveUntil: &until}
+
+	err := config.CheckActive(from.Add(-time.Second))
+	assert.ErrorIs(t, err, ErrNotActive)
+	assert.EqualError(t, err, "config is not active: db@1.0 is active from 2026-01-01T22:00:00Z")
+	assert.True(t, config.ActiveAt(from))
+	assert.True(t, config.ActiveAt(until.Add(-time.Nanosecond)))
+	err = config.CheckActive(until)
+	assert.EqualError(t, err, "config is not active: db@1.0 expired at 2026-01-02T00:00:00Z")
+
+	// Without a window a config is always active
+	assert.True(t, Config{Name: "db", Version: "1.0"}.ActiveAt(time.Time{}))
+}
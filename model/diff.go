@@ -0,0 +1,163 @@
+// Package model defines the GroupDiff struct describing the differences between two config groups.
+//
+// ConfigDiff describes how a single config changed between the two groups.
+// DiffGroups compares two groups config by config, matching them by name and version.
+package model
+
+import "sort"
+
+type ParamChange struct {
+	Key string `json:"key" yaml:"key"`
+	Old string `json:"old,omitempty" yaml:"old,omitempty"`
+	New string `json:"new,omitempty" yaml:"new,omitempty"`
+}
+
+type ConfigDiff struct {
+	Name         string        `json:"name" yaml:"name"`
+	Version      string        `json:"version" yaml:"version"`
+	Params       []ParamChange `json:"params,omitempty" yaml:"params,omitempty"`
+	LabelsBefore []Label       `json:"labelsBefore,omitempty" yaml:"labelsBefore,omitempty"`
+	LabelsAfter  []Label       `json:"labelsAfter,omitempty" yaml:"labelsAfter,omitempty"`
+	// SecretsChanged is set when the list of secret params changed
+	SecretsChanged bool `json:"secretsChanged,omitempty" yaml:"secretsChanged,omitempty"`
+}
+
+type GroupDiff struct {
+	Added     []*ConfigWithLabels `json:"added,omitempty" yaml:"added,omitempty"`
+	Removed   []*ConfigWithLabels `json:"removed,omitempty" yaml:"removed,omitempty"`
+	Changed   []ConfigDiff        `json:"changed,omitempty" yaml:"changed,omitempty"`
+	Variables []ParamChange       `json:"variables,omitempty" yaml:"variables,omitempty"`
+	// Protected is the new protection of the group, set only when it changed
+	Protected *bool `json:"protected,omitempty" yaml:"protected,omitempty"`
+}
+
+// Empty reports whether the two compared groups hold the same configs and variables and are alike
+// protected.
+func (d GroupDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Variables) == 0 && d.Protected == nil
+}
+
+// DiffGroups returns the changes needed to turn the configs, variables and protection of group a into
+// those of group b. The values of secret params are masked in the result.
+func DiffGroups(a ConfigGroup, b ConfigGroup) GroupDiff {
+	before := indexConfigs(a.Configs)
+	after := indexConfigs(b.Configs)
+
+	diff := GroupDiff{Variables: DiffParams(a.Variables, b.Variables)}
+	if a.Protected != b.Protected {
+		diff.Protected = &b.Protected
+	}
+	for _, key := range sortedKeys(after) {
+		newConfig := after[key]
+		oldConfig, ok := before[key]
+		if !ok {
+			diff.Added = append(diff.Added, MaskConfigs([]*ConfigWithLabels{newConfig})...)
+			continue
+		}
+		if change, changed := diffConfigs(oldConfig, newConfig); changed {
+			diff.Changed = append(diff.Changed, change)
+		}
+	}
+	for _, key := range sortedKeys(before) {
+		if _, ok := after[key]; !ok {
+			diff.Removed = append(diff.Removed, MaskConfigs([]*ConfigWithLabels{before[key]})...)
+		}
+	}
+	return diff
+}
+
+func diffConfigs(a *ConfigWithLabels, b *ConfigWithLabels) (ConfigDiff, bool) {
+	change := ConfigDiff{Name: b.Name, Version: b.Version, Params: DiffParams(a.Params, b.Params)}
+	for i, param := range change.Params {
+		if a.IsSecret(param.Key) || b.IsSecret(param.Key) {
+			change.Params[i] = MaskParamChange(param)
+		}
+	}
+	change.SecretsChanged = !a.SameSecrets(b.Config)
+
+	if !sameLabels(a.Labels, b.Labels) {
+		change.LabelsBefore = a.Labels
+		change.LabelsAfter = b.Labels
+	}
+
+	return change, len(change.Params) > 0 || change.LabelsAfter != nil || change.LabelsBefore != nil || change.SecretsChanged
+}
+
+// MaskParamChange returns the change with the old and new values replaced by SecretMask.
+func MaskParamChange(change ParamChange) ParamChange {
+	if change.Old != "" {
+		change.Old = SecretMask
+	}
+	if change.New != "" {
+		change.New = SecretMask
+	}
+	return change
+}
+
+// SameSecrets reports whether both configs mark the same params as secret.
+func (c Config) SameSecrets(other Config) bool {
+	if len(c.Secrets) != len(other.Secrets) {
+		return false
+	}
+	for _, secret := range other.Secrets {
+		if !c.IsSecret(secret) {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffParams returns the params which were added, removed or changed between a and b, sorted by key.
+func DiffParams(a map[string]string, b map[string]string) []ParamChange {
+	keys := make(map[string]struct{})
+	for key := range a {
+		keys[key] = struct{}{}
+	}
+	for key := range b {
+		keys[key] = struct{}{}
+	}
+
+	var changes []ParamChange
+	for _, key := range sortedKeys(keys) {
+		oldValue, inOld := a[key]
+		newValue, inNew := b[key]
+		if inOld != inNew || oldValue != newValue {
+			changes = append(changes, ParamChange{Key: key, Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
+
+func sameLabels(a []Label, b []Label) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	labels := make(map[string]string, len(a))
+	for _, label := range a {
+		labels[label.Key] = label.Value
+	}
+	for _, label := range b {
+		value, ok := labels[label.Key]
+		if !ok || value != label.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func indexConfigs(configs []*ConfigWithLabels) map[string]*ConfigWithLabels {
+	index := make(map[string]*ConfigWithLabels, len(configs))
+	for _, config := range configs {
+		index[Ref{Name: config.Name, Version: config.Version}.String()] = config
+	}
+	return index
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
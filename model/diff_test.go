@@ -0,0 +1,59 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffGroups(t *testing.T) {
+	before := ConfigGroup{
+		Name:    "group",
+		Version: "1.0",
+		Configs: []*ConfigWithLabels{
+			{Config: Config{Name: "db", Version: "1.0", Params: map[string]string{"host": "a", "port": "5432"}}},
+			{Config: Config{Name: "cache", Version: "1.0"}},
+			{Config: Config{Name: "queue", Version: "1.0"}, Labels: []Label{{Key: "env", Value: "dev"}}},
+		},
+	}
+	after := ConfigGroup{
+		Name:    "group",
+		Version: "2.0",
+		Configs: []*ConfigWithLabels{
+			{Config: Config{Name: "db", Version: "1.0", Params: map[string]string{"host": "b", "port": "5432", "user": "app"}}},
+			{Config: Config{Name: "queue", Version: "1.0"}, Labels: []Label{{Key: "env", Value: "prod"}}},
+			{Config: Config{Name: "search", Version: "1.0"}},
+		},
+	}
+
+	diff := DiffGroups(before, after)
+
+	assert.Len(t, diff.Added, 1)
+	assert.Equal(t, "search", diff.Added[0].Name)
+	assert.Len(t, diff.Removed, 1)
+	assert.Equal(t, "cache", diff.Removed[0].Name)
+	assert.Equal(t, []ConfigDiff{
+		{
+			Name:    "db",
+			Version: "1.0",
+			Params: []ParamChange{
+				{Key: "host", Old: "a", New: "b"},
+				{Key: "user", New: "app"},
+			},
+		},
+		{
+			Name:         "queue",
+			Version:      "1.0",
+			LabelsBefore: []Label{{Key: "env", Value: "dev"}},
+			LabelsAfter:  []Label{{Key: "env", Value: "prod"}},
+		},
+	}, diff.Changed)
+
+	assert.True(t, DiffGroups(before, before).Empty())
+
+	protected := before
+	protected.Protected = true
+	diff = DiffGroups(before, protected)
+	assert.False(t, diff.Empty())
+	assert.Equal(t, true, *diff.Protected)
+}
@@ -0,0 +1,65 @@
+// Package model defines the inconsistencies the store checker finds in the keyspace.
+//
+// Writes spanning several keys, e.g. adding a group with its configs, aren't atomic, so an interrupted
+// write or an older version can leave keys behind which the repositories don't expect. Each Issue
+// carries the key changes repairing it, or none when it needs an operator to decide.
+package model
+
+import (
+	"context"
+	"time"
+)
+
+type IssueKind string
+
+const (
+	// IssueOrphan is a key outside of any group, or group configs without a group record
+	IssueOrphan IssueKind = "orphan"
+	// IssuePlaceholder is a group key holding the empty placeholder older versions wrote
+	IssuePlaceholder IssueKind = "placeholder"
+	// IssueDuplicate is a config stored under more than one key of the same group or store
+	IssueDuplicate IssueKind = "duplicate"
+	// IssueUndecodable is a value which isn't the config or group record its key says it is
+	IssueUndecodable IssueKind = "undecodable"
+	// IssueMismatch is a value whose name or version differs from the one in its key
+	IssueMismatch IssueKind = "mismatch"
+	// IssueNonCanonical is a key which isn't the one the current version writes the value under
+	IssueNonCanonical IssueKind = "non-canonical"
+	// IssueDanglingReference is an extends or ${config:...} reference to a config which doesn't exist
+	IssueDanglingReference IssueKind = "dangling-reference"
+)
+
+type Issue struct {
+	Kind   IssueKind `json:"kind"`
+	Key    string    `json:"key"`
+	Detail string    `json:"detail"`
+	// Repair lists the changes repairing the issue, empty if it can't be repaired automatically
+	Repair   []KeyChange `json:"repair,omitempty"`
+	Repaired bool        `json:"repaired"`
+}
+
+// StoreScan is the result of reading the whole keyspace.
+type StoreScan struct {
+	Scanned int
+	Issues  []Issue
+	// Configs and GroupConfigs are the decoded standalone and group configs, indexed by key
+	Configs      map[string]Config
+	GroupConfigs map[string]Config
+}
+
+type FsckReport struct {
+	Scanned int     `json:"scanned"`
+	Issues  []Issue `json:"issues"`
+	// Repair tells whether the repairs were applied, Repaired how many issues they resolved
+	Repair     bool      `json:"repair"`
+	Repaired   int       `json:"repaired"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+type StoreCheckRepository interface {
+	// Scan reads every key under configs/ and config-groups/ and reports the issues of the keyspace
+	Scan(ctx context.Context) (StoreScan, error)
+	// Apply makes the repairs, each of them atomically
+	Apply(ctx context.Context, changes []KeyChange) error
+}
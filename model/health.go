@@ -0,0 +1,41 @@
+// Package model defines the reports of the health endpoints.
+//
+// StoreStatus describes the storage backend as seen by the server. CheckResult is the outcome of a
+// single readiness check and HealthReport gathers them with the state of the process.
+package model
+
+import (
+	"context"
+	"time"
+)
+
+type StoreStatus struct {
+	Backend string   `json:"backend"`
+	Leader  string   `json:"leader,omitempty"`
+	Peers   []string `json:"peers,omitempty"`
+	// LatencyMs is the round trip time of the leader lookup in milliseconds
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+type CheckResult struct {
+	Name      string  `json:"name"`
+	OK        bool    `json:"ok"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type HealthReport struct {
+	// Status is ok when the server is ready, unavailable otherwise
+	Status       string        `json:"status"`
+	Ready        bool          `json:"ready"`
+	ShuttingDown bool          `json:"shuttingDown"`
+	StartedAt    time.Time     `json:"startedAt"`
+	Uptime       string        `json:"uptime"`
+	Checks       []CheckResult `json:"checks"`
+	Store        *StoreStatus  `json:"store,omitempty"`
+}
+
+type StatusRepository interface {
+	// Status looks up the leader of the store, failing if the store can't be reached
+	Status(ctx context.Context) (StoreStatus, error)
+}
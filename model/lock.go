@@ -0,0 +1,41 @@
+// Package model defines the locks which keep configs and config groups from being deleted.
+//
+// A Lock names its owner and the reason it was taken. While a config is locked it can't be deleted,
+// while a group is locked neither the group nor any of its configs can be removed. Locks are stored
+// apart from the objects they lock, so writing an object never drops its lock.
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrLocked is returned when a locked config or group would be deleted or lose configs, and when an
+// object which is already locked is locked again.
+var ErrLocked = errors.New("locked")
+
+// ErrNotLocked is returned when an object which isn't locked is unlocked.
+var ErrNotLocked = errors.New("not locked")
+
+// ErrNotLockOwner is returned when a caller who doesn't own a lock and can't break locks unlocks it.
+var ErrNotLockOwner = errors.New("caller doesn't own the lock")
+
+type Lock struct {
+	// Kind is Config or ConfigGroup
+	Kind     string    `json:"kind"`
+	Name     string    `json:"name"`
+	Version  string    `json:"version"`
+	Owner    string    `json:"owner"`
+	Reason   string    `json:"reason"`
+	LockedAt time.Time `json:"lockedAt"`
+}
+
+type LockRepository interface {
+	// Get returns the lock of the object, nil if it isn't locked
+	Get(ctx context.Context, kind string, name string, version string) (*Lock, error)
+	List(ctx context.Context) ([]Lock, error)
+	// Lock stores the lock unless the object is already locked, and reports whether it was stored
+	Lock(ctx context.Context, lock Lock) (bool, error)
+	Unlock(ctx context.Context, kind string, name string, version string) error
+}
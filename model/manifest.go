@@ -0,0 +1,165 @@
+// Package model defines the Manifest struct used for declarative apply and its repository interface.
+//
+// A Manifest describes the desired state of a single Config or ConfigGroup as a kind and a spec.
+// ApplyPlan lists the creates, updates and deletes needed to reach the desired state.
+// ApplyRepository outlines the methods which read the versions of the stored objects and write a plan
+// to the store in a single transaction.
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	KindConfig      = "Config"
+	KindConfigGroup = "ConfigGroup"
+)
+
+// ErrApplyConflict is returned when an object of a plan is written by someone else while the plan is
+// applied.
+var ErrApplyConflict = errors.New("apply conflict")
+
+type Manifest struct {
+	Kind   string
+	Config *Config
+	Group  *ConfigGroup
+}
+
+type PlanAction string
+
+const (
+	PlanCreate PlanAction = "create"
+	PlanUpdate PlanAction = "update"
+	PlanDelete PlanAction = "delete"
+)
+
+type PlanStep struct {
+	Action  PlanAction    `json:"action" yaml:"action"`
+	Kind    string        `json:"kind" yaml:"kind"`
+	Name    string        `json:"name" yaml:"name"`
+	Version string        `json:"version" yaml:"version"`
+	Config  *Config       `json:"config,omitempty" yaml:"config,omitempty"`
+	Group   *ConfigGroup  `json:"group,omitempty" yaml:"group,omitempty"`
+	Params  []ParamChange `json:"params,omitempty" yaml:"params,omitempty"`
+	Diff    *GroupDiff    `json:"diff,omitempty" yaml:"diff,omitempty"`
+	// TrashReplaced moves the object an update replaces to the trash, like a delete does
+	TrashReplaced bool `json:"-" yaml:"-"`
+	// Index is the version of the object the step updates or deletes, read when the plan was computed
+	Index uint64 `json:"-" yaml:"-"`
+	// RequireUnlocked fails the step if the object is locked when it is written, set for the steps
+	// removing something a lock keeps
+	RequireUnlocked bool `json:"-" yaml:"-"`
+}
+
+// ObjectID identifies a config or group of the given kind, e.g. Config/db@1.0.
+func ObjectID(kind string, name string, version string) string {
+	return kind + "/" + Ref{Name: name, Version: version}.String()
+}
+
+type ApplyPlan struct {
+	Steps   []PlanStep `json:"steps" yaml:"steps"`
+	DryRun  bool       `json:"dryRun" yaml:"dryRun"`
+	Prune   bool       `json:"prune" yaml:"prune"`
+	Applied bool       `json:"applied" yaml:"applied"`
+}
+
+// Masked returns a copy of the plan with the values of secret params replaced by SecretMask.
+func (p ApplyPlan) Masked() ApplyPlan {
+	steps := make([]PlanStep, 0, len(p.Steps))
+	for _, step := range p.Steps {
+		if step.Config != nil {
+			masked := step.Config.Masked()
+			step.Config = &masked
+		}
+		if step.Group != nil {
+			masked := step.Group.Masked()
+			step.Group = &masked
+		}
+		steps = append(steps, step)
+	}
+	p.Steps = steps
+	return p
+}
+
+type ApplyRepository interface {
+	// Indexes returns the version of every stored config and group by ObjectID, for PlanStep.Index
+	Indexes(ctx context.Context) (map[string]uint64, error)
+	Apply(ctx context.Context, plan ApplyPlan) error
+}
+
+type manifestDocument[T any] struct {
+	Kind string `json:"kind" yaml:"kind"`
+	Spec T      `json:"spec" yaml:"spec"`
+}
+
+// Ref returns the name and version of the object described by the manifest.
+func (m Manifest) Ref() Ref {
+	switch {
+	case m.Config != nil:
+		return Ref{Name: m.Config.Name, Version: m.Config.Version}
+	case m.Group != nil:
+		return Ref{Name: m.Group.Name, Version: m.Group.Version}
+	}
+	return Ref{}
+}
+
+// Validate checks that the manifest describes a single object with a valid name and version.
+func (m Manifest) Validate() error {
+	switch {
+	case m.Kind == KindConfig && m.Config != nil:
+		return m.Config.Validate()
+	case m.Kind == KindConfigGroup && m.Group != nil:
+		return m.Group.Validate()
+	}
+	return fmt.Errorf("unknown manifest kind %q. Expected %s or %s", m.Kind, KindConfig, KindConfigGroup)
+}
+
+func (m Manifest) MarshalJSON() ([]byte, error) {
+	if m.Group != nil {
+		return json.Marshal(manifestDocument[*ConfigGroup]{Kind: m.Kind, Spec: m.Group})
+	}
+	return json.Marshal(manifestDocument[*Config]{Kind: m.Kind, Spec: m.Config})
+}
+
+func (m *Manifest) UnmarshalJSON(data []byte) error {
+	var raw manifestDocument[json.RawMessage]
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	return m.decodeSpec(raw.Kind, func(v interface{}) error {
+		return json.Unmarshal(raw.Spec, v)
+	})
+}
+
+func (m Manifest) MarshalYAML() (interface{}, error) {
+	if m.Group != nil {
+		return manifestDocument[*ConfigGroup]{Kind: m.Kind, Spec: m.Group}, nil
+	}
+	return manifestDocument[*Config]{Kind: m.Kind, Spec: m.Config}, nil
+}
+
+func (m *Manifest) UnmarshalYAML(value *yaml.Node) error {
+	var raw manifestDocument[yaml.Node]
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	return m.decodeSpec(raw.Kind, raw.Spec.Decode)
+}
+
+func (m *Manifest) decodeSpec(kind string, decode func(v interface{}) error) error {
+	m.Kind = kind
+	switch kind {
+	case KindConfig:
+		m.Config = &Config{}
+		return decode(m.Config)
+	case KindConfigGroup:
+		m.Group = &ConfigGroup{}
+		return decode(m.Group)
+	}
+	return fmt.Errorf("unknown manifest kind %q. Expected %s or %s", kind, KindConfig, KindConfigGroup)
+}
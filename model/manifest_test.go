@@ -0,0 +1,31 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestManifest_Decode(t *testing.T) {
+	var fromYAML Manifest
+	err := yaml.Unmarshal([]byte("kind: Config\nspec:\n  name: db\n  version: 1.0\n  params:\n    port: 5432\n"), &fromYAML)
+	assert.NoError(t, err)
+	assert.Equal(t, Manifest{Kind: KindConfig, Config: &Config{Name: "db", Version: "1.0", Params: map[string]string{"port": "5432"}}}, fromYAML)
+	assert.NoError(t, fromYAML.Validate())
+
+	var fromJSON Manifest
+	err = json.Unmarshal([]byte(`{"kind":"ConfigGroup","spec":{"name":"payments","version":"1.0","configs":[]}}`), &fromJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, Ref{Name: "payments", Version: "1.0"}, fromJSON.Ref())
+	assert.NoError(t, fromJSON.Validate())
+
+	encoded, err := json.Marshal(fromJSON)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"kind":"ConfigGroup","spec":{"name":"payments","version":"1.0","configs":[]}}`, string(encoded))
+
+	err = json.Unmarshal([]byte(`{"kind":"Secret","spec":{}}`), &fromJSON)
+	assert.Error(t, err)
+	assert.Error(t, Manifest{Kind: KindConfig, Config: &Config{Name: "db"}}.Validate())
+}
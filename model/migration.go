@@ -0,0 +1,86 @@
+// Package model defines the migrations which bring the keyspace of the store to the current layout.
+//
+// The store holds a schema marker with the version of the last migration applied to it. Migrations are
+// numbered from 1 and applied in order, each of them first planned as a list of key changes, so a dry
+// run reports exactly what a real run would write.
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// SchemaMarker records the layout version of the keyspace. A store without a marker is at version 0.
+type SchemaMarker struct {
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Migration describes a single step of the keyspace layout.
+type Migration struct {
+	Version     int    `json:"version"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type KeyChangeAction string
+
+const (
+	// KeyMove moves the value under Key to Target
+	KeyMove KeyChangeAction = "move"
+	// KeyDelete deletes Key
+	KeyDelete KeyChangeAction = "delete"
+	// KeySet writes Value under Key
+	KeySet KeyChangeAction = "set"
+)
+
+// KeyChange is a single write planned by a migration.
+type KeyChange struct {
+	Action KeyChangeAction `json:"action"`
+	Key    string          `json:"key"`
+	Target string          `json:"target,omitempty"`
+	Value  interface{}     `json:"value,omitempty"`
+	// Reason tells why the key is changed, e.g. "labels missing from key"
+	Reason string `json:"reason"`
+}
+
+// MigrationResult is the outcome of a single migration in a run.
+type MigrationResult struct {
+	Migration
+	Changes []KeyChange `json:"changes"`
+	// Conflicts are the keys the migration left in place because it couldn't tell how to change them,
+	// e.g. a legacy key whose canonical key already holds a different value
+	Conflicts []string `json:"conflicts,omitempty"`
+	Applied   bool     `json:"applied"`
+}
+
+type MigrationReport struct {
+	DryRun      bool              `json:"dryRun"`
+	FromVersion int               `json:"fromVersion"`
+	ToVersion   int               `json:"toVersion"`
+	Migrations  []MigrationResult `json:"migrations"`
+	Error       string            `json:"error,omitempty"`
+	StartedAt   time.Time         `json:"startedAt"`
+	FinishedAt  time.Time         `json:"finishedAt"`
+}
+
+// MigrationStatus tells which migrations the store still needs.
+type MigrationStatus struct {
+	Version int         `json:"version"`
+	Latest  int         `json:"latest"`
+	Pending []Migration `json:"pending"`
+	// LastRun is the report of the last run since the server started
+	LastRun *MigrationReport `json:"lastRun,omitempty"`
+}
+
+type MigrationRepository interface {
+	GetSchema(ctx context.Context) (SchemaMarker, error)
+	SaveSchema(ctx context.Context, marker SchemaMarker) error
+	// Migrations lists every known migration in version order
+	Migrations() []Migration
+	// Plan returns the changes the migration would make to the store as it is now and the keys it
+	// would leave in place, without writing anything
+	Plan(ctx context.Context, version int) ([]KeyChange, []string, error)
+	// Apply makes the planned changes, each of them atomically
+	Apply(ctx context.Context, changes []KeyChange) error
+}
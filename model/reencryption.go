@@ -0,0 +1,57 @@
+// Package model defines the state of the background job which re-encrypts secret params after the
+// primary encryption key was rotated.
+//
+// ReencryptionJob is persisted in the store after every batch of keys, so a job interrupted by a
+// restart or an error is resumed from the last processed key instead of starting over.
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrUnreadableSecret is returned by ReencryptionRepository.Reencrypt when a value can't be decrypted,
+// e.g. because the key it was encrypted with is no longer in the keyring. The job skips such values
+// and reports them, while other errors stop the job.
+var ErrUnreadableSecret = errors.New("secret can't be re-encrypted")
+
+type ReencryptionStatus string
+
+const (
+	ReencryptionRunning   ReencryptionStatus = "running"
+	ReencryptionCompleted ReencryptionStatus = "completed"
+	ReencryptionFailed    ReencryptionStatus = "failed"
+)
+
+type ReencryptionJob struct {
+	ID     string             `json:"id"`
+	Status ReencryptionStatus `json:"status"`
+	// KeyID is the primary key values are re-encrypted with
+	KeyID string `json:"keyId"`
+	// Cursor is the last processed key, the job resumes after it
+	Cursor string `json:"cursor,omitempty"`
+	// Total is the number of keys the job walks, Scanned how many of them were processed so far
+	Total       int `json:"total"`
+	Scanned     int `json:"scanned"`
+	Reencrypted int `json:"reencrypted"`
+	// Progress is the share of processed keys, between 0 and 1
+	Progress float64 `json:"progress"`
+	// FailedKeys are the keys whose values couldn't be re-encrypted, e.g. because their key is missing
+	FailedKeys []string   `json:"failedKeys,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+type ReencryptionRepository interface {
+	// GetJob returns the last job, or nil if no job was ever started
+	GetJob(ctx context.Context) (*ReencryptionJob, error)
+	SaveJob(ctx context.Context, job ReencryptionJob) error
+	// Keys lists in order every key which may hold encrypted params
+	Keys(ctx context.Context) ([]string, error)
+	// Reencrypt rewraps the encrypted params stored under the key with the primary key and reports
+	// whether anything had to be rewritten
+	Reencrypt(ctx context.Context, key string) (bool, error)
+}
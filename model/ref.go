@@ -0,0 +1,27 @@
+// Package model defines the Ref struct used to point at a versioned object.
+//
+// A Ref is written as name@version, e.g. db@1.0.
+package model
+
+import (
+	"errors"
+	"strings"
+)
+
+type Ref struct {
+	Name    string
+	Version string
+}
+
+// ParseRef parses a reference in the name@version format.
+func ParseRef(s string) (Ref, error) {
+	name, version, ok := strings.Cut(s, "@")
+	if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(version) == "" {
+		return Ref{}, errors.New("invalid reference " + s + ". Expected format is name@version")
+	}
+	return Ref{Name: name, Version: version}, nil
+}
+
+func (r Ref) String() string {
+	return r.Name + "@" + r.Version
+}
@@ -0,0 +1,52 @@
+// Package model defines the state of the scheduler which activates and expires configs.
+//
+// The scheduler runs on every server. Each run claims the time since the previous run by advancing
+// LastRun with a compare-and-set, so an activation is reported by only one of them.
+package model
+
+import (
+	"context"
+	"time"
+)
+
+type ScheduleEventKind string
+
+const (
+	// ScheduleActivated is emitted once the activeFrom time of a config passed
+	ScheduleActivated ScheduleEventKind = "activated"
+	// ScheduleExpired is emitted once a config past its activeUntil time was deleted
+	ScheduleExpired ScheduleEventKind = "expired"
+)
+
+type ScheduleEvent struct {
+	Kind ScheduleEventKind `json:"kind"`
+	// Config is the name@version of the config, Group the one of the group holding it, if any
+	Config string `json:"config"`
+	Group  string `json:"group,omitempty"`
+	// At is the activeFrom or activeUntil time of the config, HandledAt when the scheduler acted on it
+	At        time.Time  `json:"at"`
+	HandledAt *time.Time `json:"handledAt,omitempty"`
+}
+
+// ScheduleState is persisted in the store and shared by the servers.
+type ScheduleState struct {
+	LastRun time.Time `json:"lastRun"`
+	// Events are the most recent events, oldest first
+	Events []ScheduleEvent `json:"events"`
+}
+
+type ScheduleStatus struct {
+	LastRun time.Time       `json:"lastRun"`
+	Events  []ScheduleEvent `json:"events"`
+	// Upcoming are the activations and expirations still to come, soonest first
+	Upcoming []ScheduleEvent `json:"upcoming"`
+}
+
+type ScheduleRepository interface {
+	// GetState returns the state together with the index to pass to SaveState, a zero state if none
+	// was saved yet
+	GetState(ctx context.Context) (ScheduleState, uint64, error)
+	// SaveState saves the state unless it was modified since index was read, and reports whether it
+	// was saved
+	SaveState(ctx context.Context, state ScheduleState, index uint64) (bool, error)
+}
@@ -0,0 +1,36 @@
+// Package model defines the structs used to export the whole store to an archive and import it back.
+//
+// ExportManifest describes the content of an export archive and the version of its format.
+// ImportStrategy tells what to do with objects which already exist in the store.
+// ImportReport lists what happened to every imported object.
+package model
+
+import "time"
+
+// ExportFormatVersion is the version of the export archive layout written by this build.
+const ExportFormatVersion = 1
+
+type ExportManifest struct {
+	FormatVersion int       `json:"formatVersion"`
+	ExportedAt    time.Time `json:"exportedAt"`
+	Configs       int       `json:"configs"`
+	Groups        int       `json:"groups"`
+}
+
+type ImportStrategy string
+
+const (
+	ImportSkip      ImportStrategy = "skip"
+	ImportOverwrite ImportStrategy = "overwrite"
+	ImportFail      ImportStrategy = "fail"
+)
+
+type ImportReport struct {
+	Strategy    ImportStrategy `json:"strategy"`
+	Created     []string       `json:"created"`
+	Overwritten []string       `json:"overwritten"`
+	Skipped     []string       `json:"skipped"`
+	// Refused are the existing objects the import may not overwrite: protected groups and locked
+	// configs and groups
+	Refused []string `json:"refused"`
+}
@@ -0,0 +1,39 @@
+// Package model defines the trash keeping deleted configs and config groups until they are restored
+// or purged.
+//
+// Deleting a config or a group moves its keys to the trash as they were stored, so restoring it brings
+// back the exact object, secret params included. Entries older than the retention period are purged.
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTrashEntryNotFound is returned when a trash entry doesn't exist, e.g. because it was purged.
+var ErrTrashEntryNotFound = errors.New("trash entry not found")
+
+// ErrRestoreConflict is returned when an object is restored while another one with the same name and
+// version exists.
+var ErrRestoreConflict = errors.New("object exists")
+
+type TrashEntry struct {
+	// ID starts with the time of the deletion, so IDs sort by age
+	ID string `json:"id"`
+	// Kind is Config or ConfigGroup
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Version   string    `json:"version"`
+	DeletedAt time.Time `json:"deletedAt"`
+	// ExpiresAt is when the entry is purged, it follows from the retention period and isn't stored
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type TrashRepository interface {
+	// List returns the entries oldest first
+	List(ctx context.Context) ([]TrashEntry, error)
+	// Restore writes the object of the entry back and removes the entry
+	Restore(ctx context.Context, id string) (TrashEntry, error)
+	Purge(ctx context.Context, id string) error
+}
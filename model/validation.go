@@ -0,0 +1,143 @@
+// The validation rules below keep names, versions and labels to forms which are safe to use in
+// references, URLs and the label filters of the API.
+package model
+
+import (
+	"errors"
+	"fmt"
+	"project/secrets"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalid is wrapped by every validation error.
+var ErrInvalid = errors.New("invalid input")
+
+const (
+	maxNameLength       = 128
+	maxLabelKeyLength   = 63
+	maxLabelValueLength = 256
+)
+
+var (
+	// Names and versions start with a letter or digit and hold letters, digits, '.', '_' and '-'.
+	// Versions may also carry '+' build metadata, e.g. 1.2.0+build.5
+	namePattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	versionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+	// Label keys also end with a letter or digit
+	labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]*[A-Za-z0-9])?$`)
+)
+
+type validationError struct {
+	message string
+}
+
+func (e *validationError) Error() string {
+	return e.message
+}
+
+func (e *validationError) Unwrap() error {
+	return ErrInvalid
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &validationError{message: fmt.Sprintf(format, args...)}
+}
+
+// ValidateName checks the name of an object, the object is e.g. "config" or "configGroup".
+func ValidateName(object string, name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return invalid("%s name cannot be empty", object)
+	case len(name) > maxNameLength:
+		return invalid("%s name %q is longer than %d characters", object, name, maxNameLength)
+	case !namePattern.MatchString(name):
+		return invalid("%s name %q must start with a letter or digit and hold only letters, digits, '.', '_' and '-'", object, name)
+	}
+	return nil
+}
+
+// ValidateVersion checks the version of an object, the object is e.g. "config" or "configGroup".
+func ValidateVersion(object string, version string) error {
+	switch {
+	case strings.TrimSpace(version) == "":
+		return invalid("%s version cannot be empty", object)
+	case len(version) > maxNameLength:
+		return invalid("%s version %q is longer than %d characters", object, version, maxNameLength)
+	case !versionPattern.MatchString(version):
+		return invalid("%s version %q must start with a letter or digit and hold only letters, digits, '.', '_', '+' and '-'", object, version)
+	}
+	return nil
+}
+
+// ValidateLabels checks the keys and values of the labels. Values can't hold ';', which separates
+// labels in the label filters of the API, or control characters, and a key can't repeat.
+func ValidateLabels(labels []Label) error {
+	seen := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		switch {
+		case label.Key == "" || label.Value == "":
+			return invalid("invalid label format. Expected format is key:value")
+		case len(label.Key) > maxLabelKeyLength:
+			return invalid("label key %q is longer than %d characters", label.Key, maxLabelKeyLength)
+		case !labelKeyPattern.MatchString(label.Key):
+			return invalid("label key %q must start and end with a letter or digit and hold only letters, digits, '.', '_' and '-'", label.Key)
+		case len(label.Value) > maxLabelValueLength:
+			return invalid("value of label %s is longer than %d characters", label.Key, maxLabelValueLength)
+		case strings.ContainsRune(label.Value, ';') || strings.IndexFunc(label.Value, unicode.IsControl) >= 0:
+			return invalid("value of label %s can't hold ';' or control characters", label.Key)
+		case seen[label.Key]:
+			return invalid("label %s is set more than once", label.Key)
+		}
+		seen[label.Key] = true
+	}
+	return nil
+}
+
+// Validate checks the name and version of the config, that it doesn't expire before it becomes active,
+// and that no secret param looks encrypted already, since those are stored as they are.
+func (c Config) Validate() error {
+	if err := ValidateName("config", c.Name); err != nil {
+		return err
+	}
+	if err := ValidateVersion("config", c.Version); err != nil {
+		return err
+	}
+	if c.ActiveFrom != nil && c.ActiveUntil != nil && !c.ActiveUntil.After(*c.ActiveFrom) {
+		return invalid("config %s@%s must have activeUntil after activeFrom", c.Name, c.Version)
+	}
+	for _, secret := range c.Secrets {
+		if secrets.IsEncrypted(c.Params[secret]) {
+			return invalid("secret param %s of config %s@%s can't start with the prefix of encrypted values", secret, c.Name, c.Version)
+		}
+	}
+	return nil
+}
+
+// Validate checks the name, version and labels of the config.
+func (c ConfigWithLabels) Validate() error {
+	if err := c.Config.Validate(); err != nil {
+		return err
+	}
+	return ValidateLabels(c.Labels)
+}
+
+// Validate checks the name and version of the group and every config in it.
+func (g ConfigGroup) Validate() error {
+	if err := ValidateName("configGroup", g.Name); err != nil {
+		return err
+	}
+	if err := ValidateVersion("configGroup", g.Version); err != nil {
+		return err
+	}
+	for _, config := range g.Configs {
+		if config == nil {
+			return invalid("configGroup %s@%s holds an empty config", g.Name, g.Version)
+		}
+		if err := config.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Config{Name: "db.primary_1", Version: "1.2.0+build.5"}.Validate())
+	for _, config := range []Config{
+		{Name: "", Version: "1.0"},
+		{Name: "db", Version: " "},
+		{Name: "a/b", Version: "1.0"},
+		{Name: ".hidden", Version: "1.0"},
+		{Name: "db", Version: "1.0/2"},
+		{Name: strings.Repeat("a", maxNameLength+1), Version: "1.0"},
+	} {
+		err := config.Validate()
+		assert.ErrorIs(t, err, ErrInvalid, config.Name+"@"+config.Version)
+	}
+
+	assert.NoError(t, ValidateLabels([]Label{{Key: "env", Value: "prod:eu/1"}, {Key: "app.kubernetes.io-name", Value: "db"}}))
+	for _, labels := range [][]Label{
+		{{Key: "env", Value: ""}},
+		{{Key: "env:x", Value: "prod"}},
+		{{Key: "env-", Value: "prod"}},
+		{{Key: "env", Value: "prod;dev"}},
+		{{Key: "env", Value: "prod\n"}},
+		{{Key: "env", Value: "prod"}, {Key: "env", Value: "dev"}},
+	} {
+		assert.ErrorIs(t, ValidateLabels(labels), ErrInvalid)
+	}
+
+	from := time.Date(2026, 1, 1, 22, 0, 0, 0, time.UTC)
+	until := from.Add(2 * time.Hour)
+	assert.NoError(t, Config{Name: "db", Version: "1.0", ActiveFrom: &from, ActiveUntil: &until}.Validate())
+	assert.ErrorIs(t, Config{Name: "db", Version: "1.0", ActiveFrom: &until, ActiveUntil: &from}.Validate(), ErrInvalid)
+	assert.ErrorIs(t, Config{Name: "db", Version: "1.0", ActiveFrom: &from, ActiveUntil: &from}.Validate(), ErrInvalid)
+
+	group := ConfigGroup{Name: "payments", Version: "1.0", Configs: []*ConfigWithLabels{
+		{Config: Config{Name: "db", Version: "1.0"}, Labels: []Label{{Key: "env", Value: "prod;"}}},
+	}}
+	assert.ErrorIs(t, group.Validate(), ErrInvalid)
+
+	// Secret params looking encrypted would be stored as plain text
+	assert.ErrorIs(t, Config{Name: "db", Version: "1.0", Params: map[string]string{"password": "enc:v1:k1:x:y"}, Secrets: []string{"password"}}.Validate(), ErrInvalid)
+	assert.NoError(t, Config{Name: "db", Version: "1.0", Params: map[string]string{"note": "enc:v1:k1:x:y"}}.Validate())
+}
@@ -0,0 +1,184 @@
+// The code defines an ApplyDBRepository struct which writes a declarative apply plan to the database
+// in a single transaction.
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project/data"
+	"project/model"
+	"project/secrets"
+)
+
+type ApplyDBRepository struct {
+	db      *data.Database
+	keyring *secrets.Keyring
+}
+
+func NewApplyDBRepository(db *data.Database, keyring *secrets.Keyring) model.ApplyRepository {
+	return &ApplyDBRepository{
+		db:      db,
+		keyring: keyring,
+	}
+}
+
+// Indexes returns the modify index of every stored config and group record. Groups written before
+// records were kept have none, their steps check that none was written since instead.
+func (repo *ApplyDBRepository) Indexes(ctx context.Context) (map[string]uint64, error) {
+	ctx, end := instrument(ctx, "apply", "Indexes")
+	defer end()
+
+	indexes := make(map[string]uint64)
+	configs, err := repo.db.Pairs(ctx, configsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, pair := range configs {
+		segments, err := data.SplitKey(pair.Key)
+		if err != nil || len(segments) != 3 {
+			continue
+		}
+		indexes[model.ObjectID(model.KindConfig, segments[1], segments[2])] = pair.ModifyIndex
+	}
+	groups, err := repo.db.Pairs(ctx, configGroupsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, pair := range groups {
+		ref, ok := parseGroupKey(pair.Key)
+		if ok && pair.Key == groupKey(ref.Name, ref.Version) {
+			indexes[model.ObjectID(model.KindConfigGroup, ref.Name, ref.Version)] = pair.ModifyIndex
+		}
+	}
+	return indexes, nil
+}
+
+// Apply writes every step of the plan to the database in a single transaction. Each step is led by a
+// check that its object is still at the index the plan was computed from, or still missing for a
+// create, so a concurrent write fails the apply with ErrApplyConflict instead of being overwritten.
+// Deleted objects, and the ones replaced by steps with TrashReplaced, are moved to the trash. Steps with
+// RequireUnlocked fail the apply with ErrLocked if their object is locked. Plans needing more operations
+// than a transaction holds are refused with ErrInvalid, nothing is written.
+func (repo *ApplyDBRepository) Apply(ctx context.Context, plan model.ApplyPlan) error {
+	ctx, end := instrument(ctx, "apply", "Apply")
+	defer end()
+
+	var ops []data.TxnOp
+	for _, step := range plan.Steps {
+		stepOps, err := repo.stepOps(ctx, step)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, stepOps...)
+	}
+	if len(ops) > data.MaxTxnOps {
+		return fmt.Errorf("%w: the plan needs %d operations, more than the %d a single transaction holds. Apply the manifests in smaller parts", model.ErrInvalid, len(ops), data.MaxTxnOps)
+	}
+	if err := repo.db.Txn(ctx, ops); err != nil {
+		for _, step := range plan.Steps {
+			if !step.RequireUnlocked {
+				continue
+			}
+			if err := lockedError(ctx, repo.db, step.Kind, step.Name, step.Version, err); errors.Is(err, model.ErrLocked) {
+				return err
+			}
+		}
+		for _, step := range plan.Steps {
+			if repo.changed(ctx, step) {
+				return fmt.Errorf("%w: %s %s changed since the plan was computed", model.ErrApplyConflict, step.Kind, model.Ref{Name: step.Name, Version: step.Version})
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// stepOps returns the ops of a step: its guard, the check of its lock, the copies of the deleted or replaced object to the
+// trash with their entry, and the writes.
+func (repo *ApplyDBRepository) stepOps(ctx context.Context, step model.PlanStep) ([]data.TxnOp, error) {
+	var writes []data.TxnOp
+	var err error
+	switch step.Kind {
+	case model.KindConfig:
+		writes, err = repo.configOps(step)
+	case model.KindConfigGroup:
+		writes, err = repo.groupOps(step)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ops := []data.TxnOp{guard(step)}
+	if step.RequireUnlocked {
+		ops = append(ops, unlocked(step.Kind, step.Name, step.Version))
+	}
+	if step.Action == model.PlanDelete || step.TrashReplaced {
+		pairs, err := storedPairs(ctx, repo.db, step.Kind, step.Name, step.Version)
+		if err != nil {
+			return nil, err
+		}
+		if len(pairs) > 0 {
+			copies, entry, err := trashOps(step.Kind, step.Name, step.Version, pairs)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(append(ops, copies...), entry)
+		}
+	}
+	return append(ops, writes...), nil
+}
+
+// guard returns the check leading the ops of a step: the key of the object must not exist for a
+// create, and must be at the index read with the plan for an update or a delete.
+func guard(step model.PlanStep) data.TxnOp {
+	key := objectKey(step.Kind, step.Name, step.Version)
+	if step.Action == model.PlanCreate || step.Index == 0 {
+		// Groups stored before records were kept have no record to check
+		return data.TxnOp{Verb: data.TxnCheckNotExists, Key: key}
+	}
+	return data.TxnOp{Verb: data.TxnCheckIndex, Key: key, Index: step.Index}
+}
+
+// changed reports whether the object of the step was written since the plan was computed.
+func (repo *ApplyDBRepository) changed(ctx context.Context, step model.PlanStep) bool {
+	var value json.RawMessage
+	index, err := repo.db.GetWithIndex(ctx, objectKey(step.Kind, step.Name, step.Version), &value)
+	return err == nil && index != step.Index
+}
+
+func (repo *ApplyDBRepository) configOps(step model.PlanStep) ([]data.TxnOp, error) {
+	key := configKey(step.Name, step.Version)
+	if step.Action == model.PlanDelete {
+		return []data.TxnOp{{Verb: data.TxnDelete, Key: key}}, nil
+	}
+	sealed, err := sealConfig(repo.keyring, *step.Config)
+	if err != nil {
+		return nil, err
+	}
+	return []data.TxnOp{{Verb: data.TxnSet, Key: key, Value: sealed}}, nil
+}
+
+// groupOps replaces everything stored for the group with the desired record and configs, using the
+// same layout as `ConfigGroupDBRepository.Add`.
+func (repo *ApplyDBRepository) groupOps(step model.PlanStep) ([]data.TxnOp, error) {
+	ops := []data.TxnOp{
+		{Verb: data.TxnDeleteTree, Key: groupTreePrefix(step.Name, step.Version)},
+		{Verb: data.TxnDelete, Key: groupKey(step.Name, step.Version)},
+	}
+	if step.Action == model.PlanDelete {
+		return ops, nil
+	}
+
+	record := groupRecord{Name: step.Name, Version: step.Version, Variables: step.Group.Variables, Protected: step.Group.Protected}
+	ops = append(ops, data.TxnOp{Verb: data.TxnSet, Key: groupKey(step.Name, step.Version), Value: record})
+	for _, config := range step.Group.Configs {
+		sealed, err := sealGroupConfig(repo.keyring, config)
+		if err != nil {
+			return nil, err
+		}
+		key := groupConfigKey(step.Name, step.Version, *config)
+		ops = append(ops, data.TxnOp{Verb: data.TxnSet, Key: key, Value: sealed})
+	}
+	return ops, nil
+}
@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"project/data"
+	"project/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyDBRepository_Apply(t *testing.T) {
+	ctx := context.Background()
+	db, err := data.NewDatabase(data.Options{})
+	require.NoError(t, err)
+	repo := NewApplyDBRepository(db, nil)
+	groups := NewConfigGroupDBRepository(db, nil)
+	configs := NewConfigDBRepository(db, nil)
+	clean := func() {
+		require.NoError(t, db.Txn(ctx, []data.TxnOp{
+			{Verb: data.TxnDeleteTree, Key: groupTreePrefix("apply-test", "1.0")},
+			{Verb: data.TxnDelete, Key: groupKey("apply-test", "1.0")},
+			{Verb: data.TxnDelete, Key: configKey("apply-test", "1.0")},
+		}))
+	}
+	clean()
+	t.Cleanup(clean)
+	t.Cleanup(func() { emptyTrash(t, db, "apply-test") })
+
+	// A plan with more operations than a transaction holds is refused before anything is written
+	group := model.ConfigGroup{Name: "apply-test", Version: "1.0"}
+	for i := 0; i < data.MaxTxnOps; i++ {
+		group.Configs = append(group.Configs, &model.ConfigWithLabels{
+			Config: model.Config{Name: fmt.Sprintf("svc-%03d", i), Version: "1.0", Params: map[string]string{"i": fmt.Sprint(i)}},
+			Labels: []model.Label{{Key: "app", Value: "apply-test"}},
+		})
+	}
+	config := model.Config{Name: "apply-test", Version: "1.0", Params: map[string]string{"a": "1"}}
+	plan := model.ApplyPlan{Steps: []model.PlanStep{
+		{Action: model.PlanCreate, Kind: model.KindConfig, Name: "apply-test", Version: "1.0", Config: &config},
+		{Action: model.PlanCreate, Kind: model.KindConfigGroup, Name: "apply-test", Version: "1.0", Group: &group},
+	}}
+	err = repo.Apply(ctx, plan)
+	assert.ErrorIs(t, err, model.ErrInvalid)
+	_, err = configs.Get(ctx, "apply-test", "1.0")
+	assert.Error(t, err)
+
+	group.Configs = group.Configs[:3]
+	require.NoError(t, repo.Apply(ctx, plan))
+	stored, err := groups.Get(ctx, "apply-test", "1.0")
+	require.NoError(t, err)
+	assert.Len(t, stored.Configs, 3)
+
+	// Creating an object which exists is a conflict
+	err = repo.Apply(ctx, plan)
+	assert.ErrorIs(t, err, model.ErrApplyConflict)
+
+	// An update of a group changed since its index was read is a conflict, and writes nothing
+	indexes, err := repo.Indexes(ctx)
+	require.NoError(t, err)
+	require.NoError(t, groups.AddConfigWithLabelToGroup(ctx, "apply-test", "1.0", model.ConfigWithLabels{
+		Config: model.Config{Name: "added", Version: "1.0", Params: map[string]string{"a": "1"}},
+		Labels: []model.Label{{Key: "app", Value: "apply-test"}},
+	}))
+	group.Configs = group.Configs[:2]
+	config.Params["a"] = "2"
+	for i := range plan.Steps {
+		plan.Steps[i].Action = model.PlanUpdate
+		plan.Steps[i].Index = indexes[model.ObjectID(plan.Steps[i].Kind, "apply-test", "1.0")]
+	}
+	err = repo.Apply(ctx, plan)
+	assert.ErrorIs(t, err, model.ErrApplyConflict)
+	storedConfig, err := configs.Get(ctx, "apply-test", "1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "1", storedConfig.Params["a"])
+
+	// Updates at the current indexes replace the group
+	indexes, err = repo.Indexes(ctx)
+	require.NoError(t, err)
+	for i := range plan.Steps {
+		plan.Steps[i].Index = indexes[model.ObjectID(plan.Steps[i].Kind, "apply-test", "1.0")]
+	}
+	require.NoError(t, repo.Apply(ctx, plan))
+	stored, err = groups.Get(ctx, "apply-test", "1.0")
+	require.NoError(t, err)
+	assert.Len(t, stored.Configs, 2)
+	storedConfig, err = configs.Get(ctx, "apply-test", "1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "2", storedConfig.Params["a"])
+
+	// Deletes move the objects to the trash
+	trash := NewTrashDBRepository(db)
+	indexes, err = repo.Indexes(ctx)
+	require.NoError(t, err)
+	for i := range plan.Steps {
+		plan.Steps[i].Action = model.PlanDelete
+		plan.Steps[i].Index = indexes[model.ObjectID(plan.Steps[i].Kind, "apply-test", "1.0")]
+	}
+	require.NoError(t, repo.Apply(ctx, plan))
+	_, err = groups.Get(ctx, "apply-test", "1.0")
+	assert.ErrorIs(t, err, model.ErrGroupNotFound)
+	entries := trashEntries(t, trash, "apply-test")
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		_, err := trash.Restore(ctx, entry.ID)
+		require.NoError(t, err)
+	}
+	stored, err = groups.Get(ctx, "apply-test", "1.0")
+	require.NoError(t, err)
+	assert.Len(t, stored.Configs, 2)
+}
@@ -0,0 +1,204 @@
+// The ChangeRequestDBRepository stores change requests in Consul: the record under
+// change-requests/{id}, and the configs of the proposed group below it with their secret params
+// encrypted, so the record can be updated with a compare-and-set without rewriting them.
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project/data"
+	"project/model"
+	"project/secrets"
+	"strings"
+)
+
+type ChangeRequestDBRepository struct {
+	db      *data.Database
+	keyring *secrets.Keyring
+	apply   *ApplyDBRepository
+}
+
+func NewChangeRequestDBRepository(db *data.Database, keyring *secrets.Keyring) *ChangeRequestDBRepository {
+	return &ChangeRequestDBRepository{
+		db:      db,
+		keyring: keyring,
+		apply:   &ApplyDBRepository{db: db, keyring: keyring},
+	}
+}
+
+// Add writes the record of the change request and the configs of the proposed group in one transaction.
+// Change requests are applied in a single transaction as well, groups too large for one are refused.
+func (repo *ChangeRequestDBRepository) Add(ctx context.Context, request model.ChangeRequest) error {
+	ctx, end := instrument(ctx, "changeRequest", "Add")
+	defer end()
+
+	group := request.Group
+	groupOps, err := repo.apply.groupOps(model.PlanStep{Action: model.PlanUpdate, Kind: model.KindConfigGroup, Name: group.Name, Version: group.Version, Group: &group})
+	if err != nil {
+		return err
+	}
+	// Applying it also checks the change request and the group and swaps the record
+	if len(groupOps)+3 > data.MaxTxnOps {
+		return tooLargeError(len(groupOps) + 3)
+	}
+
+	ops := []data.TxnOp{{Verb: data.TxnCheckNotExists, Key: changeRequestKey(request.ID)}}
+	for i, config := range request.Group.Configs {
+		sealed, err := sealGroupConfig(repo.keyring, config)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, data.TxnOp{Verb: data.TxnSet, Key: changeRequestConfigKey(request.ID, i), Value: sealed})
+	}
+	ops = append(ops, data.TxnOp{Verb: data.TxnCAS, Key: changeRequestKey(request.ID), Value: changeRequestRecord(request), Index: 0})
+	return repo.db.Txn(ctx, ops)
+}
+
+func (repo *ChangeRequestDBRepository) Get(ctx context.Context, id string) (model.ChangeRequest, uint64, error) {
+	ctx, end := instrument(ctx, "changeRequest", "Get")
+	defer end()
+
+	pairs, err := repo.db.Pairs(ctx, changeRequestKey(id))
+	if err != nil {
+		return model.ChangeRequest{}, 0, err
+	}
+	requests, err := readChangeRequests(repo.keyring, pairs)
+	if err != nil {
+		return model.ChangeRequest{}, 0, err
+	}
+	for _, request := range requests {
+		if request.ID == id {
+			return request.ChangeRequest, request.index, nil
+		}
+	}
+	return model.ChangeRequest{}, 0, model.ErrChangeRequestNotFound
+}
+
+// List returns the change requests oldest first, their IDs starting with the time they were created.
+func (repo *ChangeRequestDBRepository) List(ctx context.Context) ([]model.ChangeRequest, error) {
+	ctx, end := instrument(ctx, "changeRequest", "List")
+	defer end()
+
+	pairs, err := repo.db.Pairs(ctx, changeRequestsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	requests, err := readChangeRequests(repo.keyring, pairs)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.ChangeRequest, 0, len(requests))
+	for _, request := range requests {
+		result = append(result, request.ChangeRequest)
+	}
+	return result, nil
+}
+
+func (repo *ChangeRequestDBRepository) Save(ctx context.Context, request model.ChangeRequest, index uint64) (bool, error) {
+	ctx, end := instrument(ctx, "changeRequest", "Save")
+	defer end()
+
+	return repo.db.PutCAS(ctx, changeRequestKey(request.ID), changeRequestRecord(request), index)
+}
+
+// Apply replaces the group with the proposed one in the layout of `ApplyDBRepository`, moving the
+// replaced group to the trash, and swaps the record of the change request with a compare-and-set, all
+// in one transaction. A change request which doesn't fit in one is refused with ErrInvalid, a group
+// written since it was read with ErrApplyConflict. A transaction rolled back because the record of the
+// change request changed in the meantime isn't an error, the caller reads the change request again.
+func (repo *ChangeRequestDBRepository) Apply(ctx context.Context, request model.ChangeRequest, index uint64) (bool, error) {
+	ctx, end := instrument(ctx, "changeRequest", "Apply")
+	defer end()
+
+	group := request.Group
+	var record json.RawMessage
+	groupIndex, err := repo.db.GetWithIndex(ctx, groupKey(group.Name, group.Version), &record)
+	if err != nil {
+		return false, err
+	}
+	// Locked groups don't lose configs, the change request was closed with the diff it applies
+	removes := request.Diff != nil && len(request.Diff.Removed) > 0
+	step := model.PlanStep{Action: model.PlanUpdate, Kind: model.KindConfigGroup, Name: group.Name, Version: group.Version, Group: &group, TrashReplaced: true, Index: groupIndex, RequireUnlocked: removes}
+	stepOps, err := repo.apply.stepOps(ctx, step)
+	if err != nil {
+		return false, err
+	}
+	ops := append([]data.TxnOp{{Verb: data.TxnCheckIndex, Key: changeRequestKey(request.ID), Index: index}}, stepOps...)
+	ops = append(ops, data.TxnOp{Verb: data.TxnCAS, Key: changeRequestKey(request.ID), Value: changeRequestRecord(request), Index: index})
+	if len(ops) > data.MaxTxnOps {
+		return false, tooLargeError(len(ops))
+	}
+	if err := repo.db.Txn(ctx, ops); err != nil {
+		var current model.ChangeRequest
+		currentIndex, getErr := repo.db.GetWithIndex(ctx, changeRequestKey(request.ID), &current)
+		if getErr == nil && currentIndex != index {
+			return false, nil
+		}
+		if removes {
+			if err := lockedError(ctx, repo.db, model.KindConfigGroup, group.Name, group.Version, err); errors.Is(err, model.ErrLocked) {
+				return false, err
+			}
+		}
+		if repo.apply.changed(ctx, step) {
+			return false, fmt.Errorf("%w: configGroup %s changed while the change request was applied", model.ErrApplyConflict, model.Ref{Name: group.Name, Version: group.Version})
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// tooLargeError reports a change request whose group needs more operations than a transaction holds.
+func tooLargeError(ops int) error {
+	return fmt.Errorf("%w: the change request needs %d operations, more than the %d a single transaction holds. Split the group", model.ErrInvalid, ops, data.MaxTxnOps)
+}
+
+// changeRequestRecord returns the change request without the configs of the proposed group, which are
+// stored under keys of their own.
+func changeRequestRecord(request model.ChangeRequest) model.ChangeRequest {
+	request.Group.Configs = nil
+	return request
+}
+
+type indexedChangeRequest struct {
+	model.ChangeRequest
+	index uint64
+}
+
+// readChangeRequests decodes the change requests from the pairs of their keys, sorted by key, so the
+// configs of each proposed group are read back in order.
+func readChangeRequests(keyring *secrets.Keyring, pairs []data.Pair) ([]indexedChangeRequest, error) {
+	var requests []indexedChangeRequest
+	configs := make(map[string][]*model.ConfigWithLabels)
+	for _, pair := range pairs {
+		segments := strings.SplitN(pair.Key, "/", 3)
+		if len(segments) < 2 {
+			continue
+		}
+		id, err := data.UnescapeSegment(segments[1])
+		if err != nil {
+			return nil, err
+		}
+		if len(segments) == 2 {
+			var request model.ChangeRequest
+			if err := pair.Decode(&request); err != nil {
+				return nil, err
+			}
+			requests = append(requests, indexedChangeRequest{ChangeRequest: request, index: pair.ModifyIndex})
+			continue
+		}
+		var config model.ConfigWithLabels
+		if err := pair.Decode(&config); err != nil {
+			return nil, err
+		}
+		if err := openConfig(keyring, &config.Config); err != nil {
+			return nil, err
+		}
+		configs[id] = append(configs[id], &config)
+	}
+	for i := range requests {
+		requests[i].Group.Configs = configs[requests[i].ID]
+	}
+	return requests, nil
+}
@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"project/data"
+	"project/model"
+	"project/secrets"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeRequestDBRepository(t *testing.T) {
+	ctx := context.Background()
+	db, err := data.NewDatabase(data.Options{})
+	require.NoError(t, err)
+	keyring, err := secrets.NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	require.NoError(t, err)
+	repo := NewChangeRequestDBRepository(db, keyring)
+	groups := NewConfigGroupDBRepository(db, keyring)
+	clean := func() {
+		require.NoError(t, db.Txn(ctx, []data.TxnOp{
+			{Verb: data.TxnDeleteTree, Key: changeRequestKey("cr-test")},
+			{Verb: data.TxnDeleteTree, Key: groupKey("cr-group", "1.0")},
+		}))
+	}
+	// Encrypted values are left out of the store, other tests read it without a keyring
+	clean()
+	t.Cleanup(clean)
+	t.Cleanup(func() { emptyTrash(t, db, "cr-group") })
+	require.NoError(t, groups.Add(ctx, model.ConfigGroup{Name: "cr-group", Version: "1.0", Protected: true, Configs: []*model.ConfigWithLabels{
+		{Config: model.Config{Name: "db", Version: "1.0", Params: map[string]string{"host": "a"}}},
+	}}))
+
+	request := model.ChangeRequest{ID: "cr-test-1", Status: model.ChangeRequestPending, RequiredApprovals: 1, Group: model.ConfigGroup{
+		Name: "cr-group", Version: "1.0", Protected: true, Variables: map[string]string{"region": "eu"}, Configs: []*model.ConfigWithLabels{
+			{Config: model.Config{Name: "db", Version: "1.0", Params: map[string]string{"host": "b", "password": "hunter2"}, Secrets: []string{"password"}}},
+			{Config: model.Config{Name: "cache", Version: "1.0"}, Labels: []model.Label{{Key: "env", Value: "prod"}}},
+		},
+	}}
+	require.NoError(t, repo.Add(ctx, request))
+	assert.Error(t, repo.Add(ctx, request), "IDs are unique")
+
+	// Secret params of the proposed configs are encrypted at rest
+	var stored model.ConfigWithLabels
+	require.NoError(t, db.Get(ctx, changeRequestConfigKey("cr-test-1", 0), &stored))
+	assert.True(t, secrets.IsEncrypted(stored.Params["password"]))
+
+	read, index, err := repo.Get(ctx, "cr-test-1")
+	require.NoError(t, err)
+	assert.Equal(t, request.Group, read.Group)
+	_, _, err = repo.Get(ctx, "cr-test")
+	assert.ErrorIs(t, err, model.ErrChangeRequestNotFound)
+
+	// Writes based on an outdated index are refused
+	read.Approvals = []model.Approval{{Reviewer: "bob"}}
+	saved, err := repo.Save(ctx, read, index)
+	require.NoError(t, err)
+	assert.True(t, saved)
+	read.Status = model.ChangeRequestApplied
+	applied, err := repo.Apply(ctx, read, index)
+	require.NoError(t, err)
+	assert.False(t, applied)
+	group, err := groups.Get(ctx, "cr-group", "1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "a", group.Configs[0].Params["host"])
+
+	// Applying replaces the group and closes the change request at once
+	_, index, err = repo.Get(ctx, "cr-test-1")
+	require.NoError(t, err)
+	applied, err = repo.Apply(ctx, read, index)
+	require.NoError(t, err)
+	assert.True(t, applied)
+	group, err = groups.Get(ctx, "cr-group", "1.0")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, request.Group.Configs, group.Configs)
+	assert.Equal(t, request.Group.Variables, group.Variables)
+	assert.True(t, group.Protected)
+
+	requests, err := repo.List(ctx)
+	require.NoError(t, err)
+	var listed []model.ChangeRequest
+	for _, request := range requests {
+		if strings.HasPrefix(request.ID, "cr-test") {
+			listed = append(listed, request)
+		}
+	}
+	require.Len(t, listed, 1)
+	assert.Equal(t, model.ChangeRequestApplied, listed[0].Status)
+	assert.Len(t, listed[0].Group.Configs, 2)
+
+	// The replaced group is in the trash
+	entries := trashEntries(t, NewTrashDBRepository(db), "cr-group")
+	assert.Len(t, entries, 1)
+
+	// A proposed group too large to be applied in a single transaction is refused
+	large := model.ChangeRequest{ID: "cr-test-2", Status: model.ChangeRequestPending, RequiredApprovals: 1, Group: model.ConfigGroup{Name: "cr-group", Version: "1.0", Protected: true}}
+	for i := 0; i < data.MaxTxnOps; i++ {
+		large.Group.Configs = append(large.Group.Configs, &model.ConfigWithLabels{Config: model.Config{Name: fmt.Sprintf("svc-%03d", i), Version: "1.0"}})
+	}
+	assert.ErrorIs(t, repo.Add(ctx, large), model.ErrInvalid)
+
+	// So is one which only fits without moving the replaced group to the trash, nothing is written
+	large.Group.Configs = large.Group.Configs[:data.MaxTxnOps-8]
+	require.NoError(t, repo.Add(ctx, large))
+	read, index, err = repo.Get(ctx, "cr-test-2")
+	require.NoError(t, err)
+	read.Status = model.ChangeRequestApplied
+	_, err = repo.Apply(ctx, read, index)
+	assert.ErrorIs(t, err, model.ErrInvalid)
+	group, err = groups.Get(ctx, "cr-group", "1.0")
+	require.NoError(t, err)
+	assert.Len(t, group.Configs, 2)
+	read, _, err = repo.Get(ctx, "cr-test-2")
+	require.NoError(t, err)
+	assert.Equal(t, model.ChangeRequestPending, read.Status)
+}
@@ -3,65 +3,71 @@
 package repositories
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"project/data"
 	"project/model"
+	"project/secrets"
 	"sort"
 	"strings"
 )
 
 type ConfigGroupDBRepository struct {
-	db *data.Database
+	db      *data.Database
+	keyring *secrets.Keyring
 }
 
-func NewConfigGroupDBRepository(db *data.Database) *ConfigGroupDBRepository {
+// groupRecord is the value stored under the group key itself, holding the group level data.
+type groupRecord struct {
+	Name      string            `json:"name"`
+	Version   string            `json:"version"`
+	Variables map[string]string `json:"variables,omitempty"`
+	Protected bool              `json:"protected,omitempty"`
+}
+
+// NewConfigGroupDBRepository creates the repository, using the keyring to encrypt secret params of
+// the group configs. Without a keyring configs with secret params are rejected.
+func NewConfigGroupDBRepository(db *data.Database, keyring *secrets.Keyring) *ConfigGroupDBRepository {
 	return &ConfigGroupDBRepository{
-		db: db,
+		db:      db,
+		keyring: keyring,
 	}
 }
 
 // This `Add` method in the `ConfigGroupDBRepository` struct is responsible for adding a new
 // configuration group to the repository. Here's a breakdown of what the method does:
-func (repo *ConfigGroupDBRepository) Add(configGroup model.ConfigGroup) error {
+func (repo *ConfigGroupDBRepository) Add(ctx context.Context, configGroup model.ConfigGroup) error {
+	ctx, end := instrument(ctx, "configGroup", "Add")
+	defer end()
+
 	// Validation
-	if strings.TrimSpace(configGroup.Name) == "" {
-		return errors.New("configGroup name cannot be empty")
-	}
-	if strings.TrimSpace(configGroup.Version) == "" {
-		return errors.New("configGroup version cannot be empty")
+	if err := configGroup.Validate(); err != nil {
+		return err
 	}
 
 	// Check if the group already exists
-	existingKeys, err := repo.db.List("config-groups/")
+	exists, err := repo.exists(ctx, configGroup.Name, configGroup.Version)
 	if err != nil {
 		return err
 	}
-	for key := range existingKeys {
-		// Split the key into parts
-		parts := strings.Split(key, "/")
-		// Check if the key matches the name and version of the group being added
-		if len(parts) >= 3 && parts[1] == configGroup.Name && parts[2] == configGroup.Version {
-			return errors.New("configGroup with this name and version already exists")
-		}
+	if exists {
+		return errors.New("configGroup with this name and version already exists")
 	}
 
-	// Check if the group has configs
-	hasConfigs := len(configGroup.Configs) > 0
-
-	// Add the group key without value only if it has no configs
-	if !hasConfigs {
-		_, err = repo.db.Put("config-groups", configGroup.Name, configGroup.Version, nil)
-		if err != nil {
-			return err
-		}
+	// Add the group record, which also marks the group as existing while it has no configs
+	err = repo.putRecord(ctx, configGroup)
+	if err != nil {
+		return err
 	}
 
 	// Add configs to the group
 	for _, config := range configGroup.Configs {
-		keyType := "config-groups"
-		name := fmt.Sprintf("%s/%s/configs/%s", configGroup.Name, configGroup.Version, config.Name)
-		_, err = repo.db.Put(keyType, name, config.Version, config)
+		sealed, err := sealGroupConfig(repo.keyring, config)
+		if err != nil {
+			return err
+		}
+		err = repo.db.Put(ctx, groupConfigKey(configGroup.Name, configGroup.Version, *config), sealed)
 		if err != nil {
 			return err
 		}
@@ -73,41 +79,127 @@ func (repo *ConfigGroupDBRepository) Add(configGroup model.ConfigGroup) error {
 // The `Get` method in the `ConfigGroupDBRepository` struct is responsible for retrieving a specific
 // configuration group by its name and version from the repository. Here's a breakdown of what the
 // method does:
-func (repo *ConfigGroupDBRepository) Get(name string, version string) (model.ConfigGroup, error) {
-	var configGroup model.ConfigGroup
-	configGroup.Name = name
-	configGroup.Version = version
+func (repo *ConfigGroupDBRepository) Get(ctx context.Context, name string, version string) (model.ConfigGroup, error) {
+	ctx, end := instrument(ctx, "configGroup", "Get")
+	defer end()
 
-	// Check if the config group exists
-	existingKeys, err := repo.db.List("config-groups/")
+	// Read the group record and configs at once, a group without any key doesn't exist
+	pairs, err := repo.groupPairs(ctx, name, version)
 	if err != nil {
 		return model.ConfigGroup{}, err
 	}
-	found := false
-	for key := range existingKeys {
-		// Split the key into parts
-		parts := strings.Split(key, "/")
-		// Check if the key matches the name and version of the group being added
-		if len(parts) >= 3 && parts[1] == name && parts[2] == version {
-			found = true
-			break
+	if len(pairs) == 0 {
+		return model.ConfigGroup{}, model.ErrGroupNotFound
+	}
+	return readGroup(repo.keyring, name, version, pairs)
+}
+
+// groupPairs reads the keys of a group in a single query of its group key. The prefix also matches
+// the keys of groups whose name or version continue the same way, these are left out.
+func (repo *ConfigGroupDBRepository) groupPairs(ctx context.Context, name string, version string) ([]data.Pair, error) {
+	pairs, err := repo.db.Pairs(ctx, groupKey(name, version))
+	if err != nil {
+		return nil, err
+	}
+	groupPairs := pairs[:0]
+	for _, pair := range pairs {
+		if pair.Key == groupKey(name, version) || strings.HasPrefix(pair.Key, groupTreePrefix(name, version)) {
+			groupPairs = append(groupPairs, pair)
 		}
 	}
-	if !found {
-		return model.ConfigGroup{}, errors.New("configGroup not found")
+	return groupPairs, nil
+}
+
+// exists reports whether the group has any key, its record or configs written without one, reading
+// only the keys of the group.
+func (repo *ConfigGroupDBRepository) exists(ctx context.Context, name string, version string) (bool, error) {
+	keys, err := repo.db.Keys(ctx, groupKey(name, version))
+	if err != nil {
+		return false, err
+	}
+	for _, key := range keys {
+		if key == groupKey(name, version) || strings.HasPrefix(key, groupTreePrefix(name, version)) {
+			return true, nil
+		}
 	}
+	return false, nil
+}
+
+// groupWrite holds what a change of a group writes along its own ops: the check that the group record
+// is unchanged since the group was read, and the record written back. Writing the record with every
+// change keeps its modify index the version of the whole group, which apply plans check. The check also
+// makes the protection read with the record hold when the change is written.
+type groupWrite struct {
+	check  data.TxnOp
+	record groupRecord
+}
 
-	configs, err := repo.db.List(fmt.Sprintf("config-groups/%s/%s/configs", name, version))
+// ops returns the ops of a change of the group, led by the check and followed by the record.
+func (w groupWrite) ops(ops ...data.TxnOp) []data.TxnOp {
+	txn := append([]data.TxnOp{w.check}, ops...)
+	return append(txn, data.TxnOp{Verb: data.TxnSet, Key: w.check.Key, Value: w.record})
+}
+
+// getForWrite reads the group like Get, along with the groupWrite of a change of it. Protected groups
+// are refused with ErrProtected, they change through change requests only. Groups written by older
+// versions get their record with the first change.
+func (repo *ConfigGroupDBRepository) getForWrite(ctx context.Context, name string, version string) (model.ConfigGroup, groupWrite, error) {
+	pairs, err := repo.groupPairs(ctx, name, version)
 	if err != nil {
-		return model.ConfigGroup{}, err
+		return model.ConfigGroup{}, groupWrite{}, err
 	}
-	for key := range configs {
-		var config model.ConfigWithLabels
-		err := repo.db.Get(key, &config)
-		if err != nil {
-			return model.ConfigGroup{}, err
+	if len(pairs) == 0 {
+		return model.ConfigGroup{}, groupWrite{}, model.ErrGroupNotFound
+	}
+	configGroup, err := readGroup(repo.keyring, name, version, pairs)
+	if err != nil {
+		return model.ConfigGroup{}, groupWrite{}, err
+	}
+	write := groupWrite{
+		check:  data.TxnOp{Verb: data.TxnCheckNotExists, Key: groupKey(name, version)},
+		record: groupRecord{Name: name, Version: version},
+	}
+	for _, pair := range pairs {
+		if pair.Key == groupKey(name, version) {
+			if err := pair.Decode(&write.record); err != nil {
+				return model.ConfigGroup{}, groupWrite{}, err
+			}
+			write.check = data.TxnOp{Verb: data.TxnCheckIndex, Key: pair.Key, Index: pair.ModifyIndex}
+		}
+	}
+	if write.record.Protected {
+		return model.ConfigGroup{}, groupWrite{}, protectedError(name, version)
+	}
+	return configGroup, write, nil
+}
+
+func protectedError(name string, version string) error {
+	return fmt.Errorf("%w: configGroup %s can only be changed through a change request", model.ErrProtected, model.Ref{Name: name, Version: version})
+}
+
+// readGroup decodes the group from the pairs of its keys, sorted by key so the same group always
+// reads the same. Groups written by older versions may not have a record.
+func readGroup(keyring *secrets.Keyring, name string, version string, pairs []data.Pair) (model.ConfigGroup, error) {
+	configGroup := model.ConfigGroup{Name: name, Version: version}
+	for _, pair := range pairs {
+		switch {
+		case pair.Key == groupKey(name, version):
+			var record groupRecord
+			if err := pair.Decode(&record); err != nil {
+				return model.ConfigGroup{}, err
+			}
+			configGroup.Variables = record.Variables
+			configGroup.Protected = record.Protected
+		case strings.HasPrefix(pair.Key, groupConfigsPrefix(name, version)):
+			var config model.ConfigWithLabels
+			if err := pair.Decode(&config); err != nil {
+				return model.ConfigGroup{}, err
+			}
+			if err := openConfig(keyring, &config.Config); err != nil {
+				return model.ConfigGroup{}, err
+			}
+			configGroup.Configs = append(configGroup.Configs, &config)
 		}
-		configGroup.Configs = append(configGroup.Configs, &config)
 	}
 	return configGroup, nil
 }
@@ -115,40 +207,92 @@ func (repo *ConfigGroupDBRepository) Get(name string, version string) (model.Con
 // This `Delete` method in the `ConfigGroupDBRepository` struct is responsible for deleting a specific
 // configuration group by its name and version from the repository. Here's a breakdown of what the
 // method does:
-func (repo *ConfigGroupDBRepository) Delete(name string, version string) error {
-	// Check if the group exists
-	_, err := repo.Get(name, version)
+func (repo *ConfigGroupDBRepository) Delete(ctx context.Context, name string, version string) error {
+	ctx, end := instrument(ctx, "configGroup", "Delete")
+	defer end()
+
+	// Move the record and the configs as they are stored, with their secret params encrypted
+	removeOps := []data.TxnOp{
+		unlocked(model.KindConfigGroup, name, version),
+		{Verb: data.TxnDeleteTree, Key: groupTreePrefix(name, version)},
+		{Verb: data.TxnDelete, Key: groupKey(name, version)},
+	}
+	return moveToTrash(ctx, repo.db, model.KindConfigGroup, name, version, removeOps, func(pairs []data.Pair) error {
+		// Check if the group exists and may be deleted
+		if len(pairs) == 0 {
+			return model.ErrGroupNotFound
+		}
+		var record groupRecord
+		if pairs[0].Key == groupKey(name, version) {
+			if err := pairs[0].Decode(&record); err != nil {
+				return err
+			}
+		}
+		if record.Protected {
+			return protectedError(name, version)
+		}
+		return nil
+	})
+}
+
+// The `List` method in the `ConfigGroupDBRepository` struct is responsible for retrieving every
+// configuration group in the repository, sorted by name and version.
+func (repo *ConfigGroupDBRepository) List(ctx context.Context) ([]model.ConfigGroup, error) {
+	ctx, end := instrument(ctx, "configGroup", "List")
+	defer end()
+
+	pairs, err := repo.db.Pairs(ctx, configGroupsPrefix)
 	if err != nil {
-		// If the group does not exist, return the error
-		return err
+		return nil, err
 	}
 
-	configs, err := repo.db.List(fmt.Sprintf("config-groups/%s/%s/configs", name, version))
-	if err != nil {
-		return err
+	// Collect the keys of each group, in key order
+	groupPairs := make(map[model.Ref][]data.Pair)
+	var refs []model.Ref
+	for _, pair := range pairs {
+		ref, ok := parseGroupKey(pair.Key)
+		if !ok {
+			continue
+		}
+		if _, seen := groupPairs[ref]; !seen {
+			refs = append(refs, ref)
+		}
+		groupPairs[ref] = append(groupPairs[ref], pair)
 	}
-	for key := range configs {
-		err := repo.db.Delete(key)
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Name != refs[j].Name {
+			return refs[i].Name < refs[j].Name
+		}
+		return refs[i].Version < refs[j].Version
+	})
+
+	groups := make([]model.ConfigGroup, 0, len(refs))
+	for _, ref := range refs {
+		group, err := readGroup(repo.keyring, ref.Name, ref.Version, groupPairs[ref])
 		if err != nil {
-			return err
+			return nil, err
 		}
+		groups = append(groups, group)
 	}
-	return repo.db.Delete(fmt.Sprintf("config-groups/%s/%s", name, version))
+	return groups, nil
 }
 
 // The `AddConfigToGroup` method in the `ConfigGroupDBRepository` struct is responsible for adding a
 // new configuration to a specific configuration group within the repository. Here's a breakdown of
 // what the method does:
-func (repo *ConfigGroupDBRepository) AddConfigToGroup(groupName string, version string, configName string, configVersion string) error {
+func (repo *ConfigGroupDBRepository) AddConfigToGroup(ctx context.Context, groupName string, version string, configName string, configVersion string) error {
+	ctx, end := instrument(ctx, "configGroup", "AddConfigToGroup")
+	defer end()
+
 	// Get the config
 	var config model.Config
-	err := repo.db.Get(fmt.Sprintf("configs/%s/%s", configName, configVersion), &config)
+	err := repo.db.Get(ctx, configKey(configName, configVersion), &config)
 	if err != nil {
 		return err
 	}
 
 	// Get the config group
-	configGroup, err := repo.Get(groupName, version)
+	configGroup, write, err := repo.getForWrite(ctx, groupName, version)
 	if err != nil {
 		return err
 	}
@@ -160,99 +304,92 @@ func (repo *ConfigGroupDBRepository) AddConfigToGroup(groupName string, version
 		}
 	}
 
-	// Check if the config group is empty
-	isEmpty := len(configGroup.Configs) == 0
-
 	// Add the config to the group
-	keyType := "config-groups"
-	name := fmt.Sprintf("%s/%s/configs/%s", groupName, version, configName)
-	_, err = repo.db.Put(keyType, name, configVersion, config)
-	if err != nil {
-		return err
-	}
-
-	// If the config group was empty, delete the old key
-	if isEmpty {
-		oldKey := fmt.Sprintf("config-groups/%s/%s", groupName, version)
-		err = repo.db.Delete(oldKey)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return repo.db.Txn(ctx, write.ops(data.TxnOp{Verb: data.TxnSet, Key: groupConfigKey(groupName, version, model.ConfigWithLabels{Config: config}), Value: config}))
 }
 
 // The `Update` method in the `ConfigGroupDBRepository` struct is responsible for updating an existing
 // configuration group in the repository. Here's a breakdown of what the method does:
-func (repo *ConfigGroupDBRepository) Update(configGroup model.ConfigGroup) error {
+func (repo *ConfigGroupDBRepository) Update(ctx context.Context, configGroup model.ConfigGroup) error {
+	ctx, end := instrument(ctx, "configGroup", "Update")
+	defer end()
+
 	// Validation
-	if strings.TrimSpace(configGroup.Name) == "" {
-		return errors.New("configGroup name cannot be empty")
-	}
-	if strings.TrimSpace(configGroup.Version) == "" {
-		return errors.New("configGroup version cannot be empty")
+	if err := model.ValidateName("configGroup", configGroup.Name); err != nil {
+		return err
 	}
-
-	// Update the group
-	_, err := repo.db.Put("config-groups", configGroup.Name, configGroup.Version, configGroup)
-	if err != nil {
+	if err := model.ValidateVersion("configGroup", configGroup.Version); err != nil {
 		return err
 	}
 
-	return nil
+	// Update the group record
+	return repo.putRecord(ctx, configGroup)
+}
+
+// putRecord writes the group record holding the name, version, variables and protection of the group.
+func (repo *ConfigGroupDBRepository) putRecord(ctx context.Context, configGroup model.ConfigGroup) error {
+	record := groupRecord{
+		Name:      configGroup.Name,
+		Version:   configGroup.Version,
+		Variables: configGroup.Variables,
+		Protected: configGroup.Protected,
+	}
+	return repo.db.Put(ctx, groupKey(configGroup.Name, configGroup.Version), record)
 }
 
 // The `RemoveConfigFromGroup` method in the `ConfigGroupDBRepository` struct is responsible for
 // removing a specific configuration from a configuration group within the repository. Here's a
 // breakdown of what the method does:
-func (repo *ConfigGroupDBRepository) RemoveConfigFromGroup(groupName string, version string, configName string, configVersion string) error {
+func (repo *ConfigGroupDBRepository) RemoveConfigFromGroup(ctx context.Context, groupName string, version string, configName string, configVersion string) error {
+	ctx, end := instrument(ctx, "configGroup", "RemoveConfigFromGroup")
+	defer end()
+
 	// Get the config group
-	configGroup, err := repo.Get(groupName, version)
+	configGroup, write, err := repo.getForWrite(ctx, groupName, version)
 	if err != nil {
 		return err
 	}
 
 	// Check if the config exists in the group
-	found := false
+	var removed *model.ConfigWithLabels
 	for i, existingConfig := range configGroup.Configs {
 		if existingConfig.Name == configName && existingConfig.Version == configVersion {
 			// Remove the config from the group
+			removed = existingConfig
 			configGroup.Configs = append(configGroup.Configs[:i], configGroup.Configs[i+1:]...)
-			found = true
 			break
 		}
 	}
-	if !found {
+	if removed == nil {
 		return errors.New("config not found in the group")
 	}
 
-	// Delete the config from the database
-	key := fmt.Sprintf("config-groups/%s/%s/configs/%s/%s", groupName, version, configName, configVersion)
-	err = repo.db.Delete(key)
+	// Delete the config from the database, the record written with it keeps an emptied group existing.
+	// Locked groups don't lose configs.
+	err = repo.db.Txn(ctx, write.ops(
+		unlocked(model.KindConfigGroup, groupName, version),
+		data.TxnOp{Verb: data.TxnDelete, Key: groupConfigKey(groupName, version, *removed)},
+	))
 	if err != nil {
-		return err
-	}
-
-	// Check if there are no more configs in the group
-	if len(configGroup.Configs) == 0 {
-		// Update the group key in the database
-		keyType := "config-groups"
-		_, err = repo.db.Put(keyType, groupName, version, configGroup)
-		if err != nil {
-			return err
-		}
+		return lockedError(ctx, repo.db, model.KindConfigGroup, groupName, version, err)
 	}
-
 	return nil
 }
 
 // This `AddConfigWithLabelToGroup` method in the `ConfigGroupDBRepository` struct is responsible for
 // adding a new configuration with labels to a specific configuration group within the repository.
 // Here's a breakdown of what the method does:
-func (repo *ConfigGroupDBRepository) AddConfigWithLabelToGroup(groupName string, version string, config model.ConfigWithLabels) error {
+func (repo *ConfigGroupDBRepository) AddConfigWithLabelToGroup(ctx context.Context, groupName string, version string, config model.ConfigWithLabels) error {
+	ctx, end := instrument(ctx, "configGroup", "AddConfigWithLabelToGroup")
+	defer end()
+
+	// Validation
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
 	// Get the config group
-	configGroup, err := repo.Get(groupName, version)
+	configGroup, write, err := repo.getForWrite(ctx, groupName, version)
 	if err != nil {
 		return err
 	}
@@ -269,50 +406,33 @@ func (repo *ConfigGroupDBRepository) AddConfigWithLabelToGroup(groupName string,
 		return config.Labels[i].Key < config.Labels[j].Key
 	})
 
-	// Check if the config group is empty
-	isEmpty := len(configGroup.Configs) == 0
-
-	// Add the config to the group, ensure that labels are part of key in Consul /config-groups/{name}/{version}/{labels}/{configName}/{configVersion}
-	keyType := "config-groups"
-	labels := ""
-	// labels are in format key1:value1;key2:value2
-	for _, label := range config.Labels {
-		labels += fmt.Sprintf("%s:%s;", label.Key, label.Value)
-	}
-	name := fmt.Sprintf("%s/%s/configs/%s/%s", groupName, version, labels, config.Name)
-	// If the config group is empty, delete the old key
-	if isEmpty {
-		oldKey := fmt.Sprintf("config-groups/%s/%s", groupName, version)
-		err = repo.db.Delete(oldKey)
-		if err != nil {
-			return err
-		}
-	}
-	_, err = repo.db.Put(keyType, name, config.Version, config)
+	// Add the config to the group, the labels are part of its key
+	sealed, err := sealGroupConfig(repo.keyring, &config)
 	if err != nil {
 		return err
 	}
-
-	return nil
-
+	return repo.db.Txn(ctx, write.ops(data.TxnOp{Verb: data.TxnSet, Key: groupConfigKey(groupName, version, *sealed), Value: sealed}))
 }
 
 // This `SearchConfigsWithLabelsInGroup` method in the `ConfigGroupDBRepository` struct is responsible
 // for searching and retrieving configurations within a specific configuration group that match a given
 // set of labels. Here's a breakdown of what the method does:
-func (repo *ConfigGroupDBRepository) SearchConfigsWithLabelsInGroup(groupName string, version string, labels []model.Label, configName string, configVersion string) ([]*model.ConfigWithLabels, error) {
+func (repo *ConfigGroupDBRepository) SearchConfigsWithLabelsInGroup(ctx context.Context, groupName string, version string, labels []model.Label, configName string, configVersion string) ([]*model.ConfigWithLabels, error) {
+	ctx, end := instrument(ctx, "configGroup", "SearchConfigsWithLabelsInGroup")
+	defer end()
+
 	// Check if Config Group exists
-	configGroup, err := repo.Get(groupName, version)
+	configGroup, err := repo.Get(ctx, groupName, version)
 	if err != nil {
 		return nil, err
 	}
 
 	// Convert labels to a map
+	if err := model.ValidateLabels(labels); err != nil {
+		return nil, err
+	}
 	labelsMap := make(map[string]string)
 	for _, label := range labels {
-		if label.Key == "" || label.Value == "" {
-			return nil, errors.New("invalid label format. Expected format is key:value")
-		}
 		labelsMap[label.Key] = label.Value
 	}
 
@@ -341,7 +461,10 @@ func (repo *ConfigGroupDBRepository) SearchConfigsWithLabelsInGroup(groupName st
 // The `RemoveConfigsWithLabelsFromGroup` method in the `ConfigGroupDBRepository` struct is responsible
 // for removing configurations from a specific configuration group that match a given set of labels.
 // Here's a breakdown of what the method does:
-func (repo *ConfigGroupDBRepository) RemoveConfigsWithLabelsFromGroup(groupName string, version string, labels []model.Label, configName string, configVersion string) error {
+func (repo *ConfigGroupDBRepository) RemoveConfigsWithLabelsFromGroup(ctx context.Context, groupName string, version string, labels []model.Label, configName string, configVersion string) error {
+	ctx, end := instrument(ctx, "configGroup", "RemoveConfigsWithLabelsFromGroup")
+	defer end()
+
 	// Check if the config name, version and labels are valid
 	if configName == "" {
 		return errors.New("config name cannot be empty")
@@ -354,26 +477,20 @@ func (repo *ConfigGroupDBRepository) RemoveConfigsWithLabelsFromGroup(groupName
 	}
 
 	// Get the config group
-	configGroup, err := repo.Get(groupName, version)
+	configGroup, write, err := repo.getForWrite(ctx, groupName, version)
 	if err != nil {
 		return err
 	}
 
 	// Convert labels to a map
+	if err := model.ValidateLabels(labels); err != nil {
+		return err
+	}
 	labelsMap := make(map[string]string)
 	for _, label := range labels {
-		if label.Key == "" || label.Value == "" {
-			return errors.New("invalid label format. Expected format is key:value")
-		}
 		labelsMap[label.Key] = label.Value
 	}
 
-	// Convert labels to a string
-	labelsStr := ""
-	for _, label := range labels {
-		labelsStr += fmt.Sprintf("%s:%s;", label.Key, label.Value)
-	}
-
 	// If configName or configVersion are incorrect, return an error
 	if configName == "" || configVersion == "" {
 		return errors.New("config name and version must be provided")
@@ -403,24 +520,16 @@ func (repo *ConfigGroupDBRepository) RemoveConfigsWithLabelsFromGroup(groupName
 		return errors.New("no configs with all labels found to remove")
 	}
 
-	// Remove the matching configs from the group
+	// Remove the matching configs from the group, the record written with them keeps an emptied group
+	// existing. Locked groups don't lose configs.
+	removes := []data.TxnOp{unlocked(model.KindConfigGroup, groupName, version)}
 	for _, configToRemove := range configsToRemove {
-		configKey := fmt.Sprintf("/config-groups/%s/%s/configs/%s/%s/%s", groupName, version, labelsStr, configToRemove.Config.Name, configToRemove.Config.Version)
-		err = repo.db.Delete(configKey)
-		if err != nil {
-			return err
-		}
+		removes = append(removes, data.TxnOp{Verb: data.TxnDelete, Key: groupConfigKey(groupName, version, *configToRemove)})
 	}
-
-	// If all configs are removed, update the group with an empty configs array
-	if len(configGroup.Configs) == len(configsToRemove) {
-		configGroup.Configs = []*model.ConfigWithLabels{}
-		err = repo.Update(configGroup)
-		if err != nil {
-			return err
-		}
+	err = repo.db.Txn(ctx, write.ops(removes...))
+	if err != nil {
+		return lockedError(ctx, repo.db, model.KindConfigGroup, groupName, version, err)
 	}
-
 	return nil
 }
 
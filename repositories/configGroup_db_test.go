@@ -1,20 +1,26 @@
 package repositories
 
 import (
+	"context"
+	"fmt"
 	"project/data"
+	"project/metrics"
 	"project/model"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestConfigGroupDBRepository_Add_Get_Delete(t *testing.T) {
 	// Create a new database instance
-	db, err := data.NewDatabase()
+	db, err := data.NewDatabase(data.Options{})
 	assert.NoError(t, err)
 
 	// Create a new ConfigGroupDBRepository instance
-	repo := NewConfigGroupDBRepository(db)
+	repo := NewConfigGroupDBRepository(db, nil)
+	ctx := context.Background()
+	t.Cleanup(func() { emptyTrash(t, db, "test-group") })
 
 	// Create a new config group
 	configGroup := model.ConfigGroup{
@@ -47,17 +53,180 @@ func TestConfigGroupDBRepository_Add_Get_Delete(t *testing.T) {
 	}
 
 	// Add the config group to the repository
-	err = repo.Add(configGroup)
+	err = repo.Add(ctx, configGroup)
 	assert.NoError(t, err)
 
 	// Retrieve the config group from the repository
-	retrievedConfigGroup, err := repo.Get(configGroup.Name, configGroup.Version)
+	retrievedConfigGroup, err := repo.Get(ctx, configGroup.Name, configGroup.Version)
 	assert.NoError(t, err)
 
 	// Check if the retrieved config group is the same as the original config group
 	assert.Equal(t, configGroup, retrievedConfigGroup)
 
 	// Delete the config group from the repository
-	err = repo.Delete(configGroup.Name, configGroup.Version)
+	err = repo.Delete(ctx, configGroup.Name, configGroup.Version)
 	assert.NoError(t, err)
 }
+
+func TestConfigGroupDBRepository_EscapedLabels(t *testing.T) {
+	db, err := data.NewDatabase(data.Options{})
+	assert.NoError(t, err)
+	repo := NewConfigGroupDBRepository(db, nil)
+	ctx := context.Background()
+	t.Cleanup(func() { emptyTrash(t, db, "escaped-group") })
+
+	// Label values may hold the characters separating key segments and labels
+	configGroup := model.ConfigGroup{Name: "escaped-group", Version: "1.0", Configs: []*model.ConfigWithLabels{}}
+	err = repo.Add(ctx, configGroup)
+	assert.NoError(t, err)
+	defer repo.Delete(ctx, configGroup.Name, configGroup.Version)
+	labels := []model.Label{{Key: "region", Value: "eu/west:1"}}
+	config := model.ConfigWithLabels{Config: model.Config{Name: "db", Version: "1.0", Params: map[string]string{"port": "5432"}}, Labels: labels}
+	err = repo.AddConfigWithLabelToGroup(ctx, configGroup.Name, configGroup.Version, config)
+	assert.NoError(t, err)
+
+	keys, err := db.Keys(ctx, groupConfigsPrefix(configGroup.Name, configGroup.Version))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"config-groups/escaped-group/1.0/configs/region:eu%2Fwest%3A1;/db/1.0"}, keys)
+
+	found, err := repo.SearchConfigsWithLabelsInGroup(ctx, configGroup.Name, configGroup.Version, labels, "db", "1.0")
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+	err = repo.RemoveConfigsWithLabelsFromGroup(ctx, configGroup.Name, configGroup.Version, labels, "db", "1.0")
+	assert.NoError(t, err)
+	keys, err = db.Keys(ctx, groupConfigsPrefix(configGroup.Name, configGroup.Version))
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	// Names which would change the layout of the keys are rejected
+	err = repo.AddConfigWithLabelToGroup(ctx, configGroup.Name, configGroup.Version, model.ConfigWithLabels{Config: model.Config{Name: "../db", Version: "1.0"}})
+	assert.ErrorIs(t, err, model.ErrInvalid)
+}
+
+func TestConfigGroupDBRepository_VersionPrefix(t *testing.T) {
+	db, err := data.NewDatabase(data.Options{})
+	assert.NoError(t, err)
+	repo := NewConfigGroupDBRepository(db, nil)
+	ctx := context.Background()
+	_ = repo.Delete(ctx, "prefix-group", "1")
+	_ = repo.Delete(ctx, "prefix-group", "1.0")
+	t.Cleanup(func() { emptyTrash(t, db, "prefix-group") })
+
+	// The key of version 1 is a prefix of the keys of version 1.0, they are still separate groups
+	config := &model.ConfigWithLabels{Config: model.Config{Name: "db", Version: "1.0", Params: map[string]string{"k": "v"}}}
+	assert.NoError(t, repo.Add(ctx, model.ConfigGroup{Name: "prefix-group", Version: "1.0", Configs: []*model.ConfigWithLabels{config}}))
+	_, err = repo.Get(ctx, "prefix-group", "1")
+	assert.EqualError(t, err, "configGroup not found")
+	assert.NoError(t, repo.Add(ctx, model.ConfigGroup{Name: "prefix-group", Version: "1"}))
+	group, err := repo.Get(ctx, "prefix-group", "1")
+	assert.NoError(t, err)
+	assert.Empty(t, group.Configs)
+
+	assert.NoError(t, repo.Delete(ctx, "prefix-group", "1"))
+	group, err = repo.Get(ctx, "prefix-group", "1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.ConfigWithLabels{config}, group.Configs)
+	assert.NoError(t, repo.Delete(ctx, "prefix-group", "1.0"))
+}
+
+func TestConfigGroupDBRepository_Protected(t *testing.T) {
+	db, err := data.NewDatabase(data.Options{})
+	assert.NoError(t, err)
+	repo := NewConfigGroupDBRepository(db, nil)
+	ctx := context.Background()
+	clean := func() {
+		assert.NoError(t, db.Txn(ctx, []data.TxnOp{{Verb: data.TxnDeleteTree, Key: groupKey("protected-group", "1.0")}}))
+	}
+	clean()
+	t.Cleanup(clean)
+
+	// Protected groups only change through change requests, the repository refuses other writes
+	config := &model.ConfigWithLabels{Config: model.Config{Name: "db", Version: "1.0", Params: map[string]string{"k": "v"}}}
+	assert.NoError(t, repo.Add(ctx, model.ConfigGroup{Name: "protected-group", Version: "1.0", Protected: true, Configs: []*model.ConfigWithLabels{config}}))
+	err = repo.AddConfigWithLabelToGroup(ctx, "protected-group", "1.0", model.ConfigWithLabels{Config: model.Config{Name: "cache", Version: "1.0"}})
+	assert.ErrorIs(t, err, model.ErrProtected)
+	assert.ErrorIs(t, repo.RemoveConfigFromGroup(ctx, "protected-group", "1.0", "db", "1.0"), model.ErrProtected)
+	assert.ErrorIs(t, repo.Delete(ctx, "protected-group", "1.0"), model.ErrProtected)
+	group, err := repo.Get(ctx, "protected-group", "1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, []*model.ConfigWithLabels{config}, group.Configs)
+}
+
+// benchmarkGroupSizes are the numbers of configs of the groups read by the benchmarks.
+var benchmarkGroupSizes = []int{1, 10, 100, 1000}
+
+// addBenchmarkGroup writes a group with size configs, in transactions to keep the setup short.
+func addBenchmarkGroup(b *testing.B, db *data.Database, name string, size int) {
+	ctx := context.Background()
+	assert.NoError(b, db.Txn(ctx, []data.TxnOp{{Verb: data.TxnDeleteTree, Key: groupKey(name, "1.0")}}))
+	ops := []data.TxnOp{{Verb: data.TxnSet, Key: groupKey(name, "1.0"), Value: groupRecord{Name: name, Version: "1.0"}}}
+	for i := 0; i < size; i++ {
+		config := model.ConfigWithLabels{
+			Config: model.Config{Name: fmt.Sprintf("config-%04d", i), Version: "1.0", Params: map[string]string{"key": "value"}},
+			Labels: []model.Label{{Key: "env", Value: "prod"}},
+		}
+		ops = append(ops, data.TxnOp{Verb: data.TxnSet, Key: groupConfigKey(name, "1.0", config), Value: config})
+		if len(ops) == data.MaxTxnOps {
+			assert.NoError(b, db.Txn(ctx, ops))
+			ops = nil
+		}
+	}
+	assert.NoError(b, db.Txn(ctx, ops))
+}
+
+// reportStoreReads reports the store reads per operation next to the time per operation, so the
+// benchmarks show the round trips a read costs against the size of the group.
+func reportStoreReads(b *testing.B, run func()) {
+	reads := func() float64 {
+		return testutil.ToFloat64(metrics.StoreOperations.WithLabelValues("get")) +
+			testutil.ToFloat64(metrics.StoreOperations.WithLabelValues("list")) +
+			testutil.ToFloat64(metrics.StoreOperations.WithLabelValues("keys"))
+	}
+	before := reads()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		run()
+	}
+	b.StopTimer()
+	b.ReportMetric((reads()-before)/float64(b.N), "reads/op")
+}
+
+func BenchmarkConfigGroupDBRepository_Get(b *testing.B) {
+	db, err := data.NewDatabase(data.Options{})
+	assert.NoError(b, err)
+	repo := NewConfigGroupDBRepository(db, nil)
+	ctx := context.Background()
+	for _, size := range benchmarkGroupSizes {
+		name := fmt.Sprintf("bench-get-%d", size)
+		addBenchmarkGroup(b, db, name, size)
+		b.Run(fmt.Sprintf("configs=%d", size), func(b *testing.B) {
+			reportStoreReads(b, func() {
+				group, err := repo.Get(ctx, name, "1.0")
+				if err != nil || len(group.Configs) != size {
+					b.Fatalf("read %d configs: %v", len(group.Configs), err)
+				}
+			})
+		})
+		assert.NoError(b, db.Txn(ctx, []data.TxnOp{{Verb: data.TxnDeleteTree, Key: groupKey(name, "1.0")}}))
+	}
+}
+
+func BenchmarkConfigGroupDBRepository_Exists(b *testing.B) {
+	db, err := data.NewDatabase(data.Options{})
+	assert.NoError(b, err)
+	repo := NewConfigGroupDBRepository(db, nil)
+	ctx := context.Background()
+	for _, size := range benchmarkGroupSizes {
+		name := fmt.Sprintf("bench-exists-%d", size)
+		addBenchmarkGroup(b, db, name, size)
+		b.Run(fmt.Sprintf("configs=%d", size), func(b *testing.B) {
+			reportStoreReads(b, func() {
+				exists, err := repo.exists(ctx, name, "1.0")
+				if err != nil || !exists {
+					b.Fatalf("group not found: %v", err)
+				}
+			})
+		})
+		assert.NoError(b, db.Txn(ctx, []data.TxnOp{{Verb: data.TxnDeleteTree, Key: groupKey(name, "1.0")}}))
+	}
+}
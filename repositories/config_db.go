@@ -3,73 +3,124 @@
 package repositories
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"project/data"
 	"project/model"
-	"strings"
+	"project/secrets"
+	"sort"
 )
 
 type ConfigDBRepository struct {
-	db *data.Database
+	db      *data.Database
+	keyring *secrets.Keyring
 }
 
-func NewConfigDBRepository(db *data.Database) model.ConfigRepository {
+// NewConfigDBRepository creates the repository, using the keyring to encrypt secret params. Without
+// a keyring configs with secret params are rejected.
+func NewConfigDBRepository(db *data.Database, keyring *secrets.Keyring) model.ConfigRepository {
 	return &ConfigDBRepository{
-		db: db,
+		db:      db,
+		keyring: keyring,
 	}
 }
 
 // Add adds a new configuration to the database.
-func (repo *ConfigDBRepository) Add(config model.Config) error {
+func (repo *ConfigDBRepository) Add(ctx context.Context, config model.Config) error {
+	ctx, end := instrument(ctx, "config", "Add")
+	defer end()
+
 	// Validation
-	if strings.TrimSpace(config.Name) == "" {
-		return errors.New("config name cannot be empty")
-	}
-	if strings.TrimSpace(config.Version) == "" {
-		return errors.New("config version cannot be empty")
+	if err := config.Validate(); err != nil {
+		return err
 	}
 
 	// Check if the config already exists
-	existingConfig, err := repo.Get(config.Name, config.Version)
+	existingConfig, err := repo.Get(ctx, config.Name, config.Version)
 	if err == nil && existingConfig.Name != "" && existingConfig.Version != "" {
 		return errors.New("config with this name and version already exists")
 	}
 
-	// Add the config
-	_, err = repo.db.Put("configs", config.Name, config.Version, config)
+	// Encrypt the secret params
+	config, err = sealConfig(repo.keyring, config)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// Add the config
+	return repo.db.Put(ctx, configKey(config.Name, config.Version), config)
 }
 
 // Get retrieves a configuration from the database based on the name and version.
-func (r *ConfigDBRepository) Get(name string, version string) (model.Config, error) {
+func (r *ConfigDBRepository) Get(ctx context.Context, name string, version string) (model.Config, error) {
+	ctx, end := instrument(ctx, "config", "Get")
+	defer end()
+
 	var config model.Config
-	err := r.db.Get(fmt.Sprintf("configs/%s/%s", name, version), &config)
+	err := r.db.Get(ctx, configKey(name, version), &config)
 	if err != nil {
 		return model.Config{}, err
 	}
 
 	// Check if the retrieved config is empty
 	if config.Name == "" && config.Version == "" && config.Params == nil {
-		return model.Config{}, fmt.Errorf("no configuration found with name %s and version %s", name, version)
+		return model.Config{}, fmt.Errorf("%w with name %s and version %s", model.ErrConfigNotFound, name, version)
+	}
+
+	// Decrypt the secret params
+	if err := openConfig(r.keyring, &config); err != nil {
+		return model.Config{}, err
 	}
 
 	return config, nil
 }
 
-// Delete deletes a configuration from the database based on the name and version.
-func (repo *ConfigDBRepository) Delete(name string, version string) error {
-	// Check if the config exists
-	_, err := repo.Get(name, version)
+// Delete moves a configuration to the trash based on the name and version, see `TrashDBRepository`.
+func (repo *ConfigDBRepository) Delete(ctx context.Context, name string, version string) error {
+	ctx, end := instrument(ctx, "config", "Delete")
+	defer end()
+
+	// Move the config as it is stored, with its secret params encrypted
+	removeOps := []data.TxnOp{
+		unlocked(model.KindConfig, name, version),
+		{Verb: data.TxnDelete, Key: configKey(name, version)},
+	}
+	return moveToTrash(ctx, repo.db, model.KindConfig, name, version, removeOps, func(pairs []data.Pair) error {
+		// Check if the config exists
+		if len(pairs) == 0 {
+			return fmt.Errorf("%w with name %s and version %s", model.ErrConfigNotFound, name, version)
+		}
+		return nil
+	})
+}
+
+// List retrieves all configurations from the database, sorted by name and version.
+func (repo *ConfigDBRepository) List(ctx context.Context) ([]model.Config, error) {
+	ctx, end := instrument(ctx, "config", "List")
+	defer end()
+
+	pairs, err := repo.db.Pairs(ctx, configsPrefix)
 	if err != nil {
-		// If the config does not exist, return the error
-		return err
+		return nil, err
 	}
 
-	// Delete the config
-	return repo.db.Delete(fmt.Sprintf("configs/%s/%s", name, version))
+	configs := make([]model.Config, 0, len(pairs))
+	for _, pair := range pairs {
+		var config model.Config
+		if err := pair.Decode(&config); err != nil {
+			return nil, err
+		}
+		if err := openConfig(repo.keyring, &config); err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+	sort.Slice(configs, func(i, j int) bool {
+		if configs[i].Name != configs[j].Name {
+			return configs[i].Name < configs[j].Name
+		}
+		return configs[i].Version < configs[j].Version
+	})
+	return configs, nil
 }
@@ -3,7 +3,9 @@
 package repositories
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"project/data"
 	"project/model"
@@ -13,11 +15,13 @@ import (
 
 func TestConfigDBRepository_Add_Get_Delete(t *testing.T) {
 	// Create a new database instance
-	db, err := data.NewDatabase()
+	db, err := data.NewDatabase(data.Options{})
 	assert.NoError(t, err)
 
 	// Create a new ConfigDBRepository instance
-	repo := NewConfigDBRepository(db)
+	repo := NewConfigDBRepository(db, nil)
+	ctx := context.Background()
+	t.Cleanup(func() { emptyTrash(t, db, "test") })
 
 	// Add a configuration to the database
 	config := model.Config{
@@ -29,17 +33,36 @@ func TestConfigDBRepository_Add_Get_Delete(t *testing.T) {
 			"param2": "value2",
 		},
 	}
-	err = repo.Add(config)
+	err = repo.Add(ctx, config)
 	assert.NoError(t, err)
 
 	// Get the configuration from the database
-	retrievedConfig, err := repo.Get(config.Name, config.Version)
+	retrievedConfig, err := repo.Get(ctx, config.Name, config.Version)
 	assert.NoError(t, err)
 
 	// Check if the retrieved configuration is the same as the original configuration
 	assert.Equal(t, config, retrievedConfig)
 
 	// Delete the configuration from the database
-	err = repo.Delete(config.Name, config.Version)
+	err = repo.Delete(ctx, config.Name, config.Version)
 	assert.NoError(t, err)
 }
+
+func TestConfigDBRepository_Cancellation(t *testing.T) {
+	db, err := data.NewDatabase(data.Options{})
+	assert.NoError(t, err)
+	repo := NewConfigDBRepository(db, nil)
+
+	// A request which is gone doesn't reach Consul
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = repo.Get(ctx, "test", "1.0")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Reads which outlast the read timeout are abandoned
+	db, err = data.NewDatabase(data.Options{ReadTimeout: time.Nanosecond})
+	assert.NoError(t, err)
+	repo = NewConfigDBRepository(db, nil)
+	_, err = repo.List(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
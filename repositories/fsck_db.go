@@ -0,0 +1,169 @@
+// The StoreCheckDBRepository reads the whole keyspace in Consul and reports the keys the other
+// repositories wouldn't read or write the way they are stored, together with the changes repairing them.
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"project/data"
+	"project/model"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type StoreCheckDBRepository struct {
+	db *data.Database
+}
+
+func NewStoreCheckDBRepository(db *data.Database) *StoreCheckDBRepository {
+	return &StoreCheckDBRepository{
+		db: db,
+	}
+}
+
+// scannedGroup is what the scan found under the keys of a group.
+type scannedGroup struct {
+	record  bool
+	configs int
+}
+
+func (repo *StoreCheckDBRepository) Scan(ctx context.Context) (model.StoreScan, error) {
+	ctx, end := instrument(ctx, "storeCheck", "Scan")
+	defer end()
+
+	values, err := listValues(ctx, repo.db, configsPrefix, configGroupsPrefix)
+	if err != nil {
+		return model.StoreScan{}, err
+	}
+
+	scan := model.StoreScan{Scanned: len(values), Configs: make(map[string]model.Config), GroupConfigs: make(map[string]model.Config)}
+	groups := make(map[model.Ref]*scannedGroup)
+	for _, key := range sortedKeys(values) {
+		segments := strings.Split(key, "/")
+		if segments[0]+"/" == configsPrefix {
+			scan.Issues = append(scan.Issues, checkConfig(key, segments, values, scan.Configs)...)
+			continue
+		}
+
+		if len(segments) < 3 {
+			scan.Issues = append(scan.Issues, model.Issue{
+				Kind:   model.IssueOrphan,
+				Key:    key,
+				Detail: "key outside of any group",
+				Repair: []model.KeyChange{{Action: model.KeyDelete, Key: key, Reason: "key outside of any group"}},
+			})
+			continue
+		}
+		ref := model.Ref{Name: legacySegment(segments[1]), Version: legacySegment(segments[2])}
+		group := groups[ref]
+		if group == nil {
+			group = &scannedGroup{}
+			groups[ref] = group
+		}
+		if len(segments) == 3 {
+			group.record = true
+			scan.Issues = append(scan.Issues, checkGroupRecord(key, ref, values)...)
+			continue
+		}
+
+		var config model.ConfigWithLabels
+		if err := decodeValue(values[key], &config); err != nil || config.Name == "" || config.Version == "" {
+			scan.Issues = append(scan.Issues, model.Issue{Kind: model.IssueUndecodable, Key: key, Detail: "value isn't a group config"})
+			continue
+		}
+		group.configs++
+		scan.GroupConfigs[key] = config.Config
+		scan.Issues = append(scan.Issues, relocate(key, groupConfigKey(ref.Name, ref.Version, config), values)...)
+	}
+
+	// Groups whose configs were written but not their record, e.g. by older versions or an interrupted Add
+	refs := make([]model.Ref, 0, len(groups))
+	for ref, group := range groups {
+		if !group.record {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return groupKey(refs[i].Name, refs[i].Version) < groupKey(refs[j].Name, refs[j].Version)
+	})
+	for _, ref := range refs {
+		key := groupKey(ref.Name, ref.Version)
+		scan.Issues = append(scan.Issues, model.Issue{
+			Kind:   model.IssueOrphan,
+			Key:    key,
+			Detail: fmt.Sprintf("%d configs of group %s without a group record", groups[ref].configs, ref),
+			Repair: []model.KeyChange{{Action: model.KeySet, Key: key, Value: groupRecord{Name: ref.Name, Version: ref.Version}, Reason: "group without record"}},
+		})
+	}
+	return scan, nil
+}
+
+func (repo *StoreCheckDBRepository) Apply(ctx context.Context, changes []model.KeyChange) error {
+	ctx, end := instrument(ctx, "storeCheck", "Apply")
+	defer end()
+
+	return applyKeyChanges(ctx, repo.db, changes)
+}
+
+// checkConfig checks a standalone config and adds it to configs if it can be decoded.
+func checkConfig(key string, segments []string, values map[string]interface{}, configs map[string]model.Config) []model.Issue {
+	if len(segments) != 3 {
+		return []model.Issue{{Kind: model.IssueUndecodable, Key: key, Detail: "key isn't configs/{name}/{version}"}}
+	}
+	var config model.Config
+	if err := decodeValue(values[key], &config); err != nil || config.Name == "" || config.Version == "" {
+		return []model.Issue{{Kind: model.IssueUndecodable, Key: key, Detail: "value isn't a config"}}
+	}
+	ref := model.Ref{Name: legacySegment(segments[1]), Version: legacySegment(segments[2])}
+	if ref.Name != config.Name || ref.Version != config.Version {
+		return []model.Issue{{Kind: model.IssueMismatch, Key: key, Detail: fmt.Sprintf("key is %s but the value is %s@%s", ref, config.Name, config.Version)}}
+	}
+	configs[key] = config
+	return relocate(key, configKey(config.Name, config.Version), values)
+}
+
+// checkGroupRecord checks the value under the group key. A placeholder or a record naming another group
+// is rewritten with the name and version of the key, keeping the variables of the record.
+func checkGroupRecord(key string, ref model.Ref, values map[string]interface{}) []model.Issue {
+	canonical := groupKey(ref.Name, ref.Version)
+	rewrite := func(kind model.IssueKind, detail string, record groupRecord) []model.Issue {
+		record.Name, record.Version = ref.Name, ref.Version
+		repair := []model.KeyChange{{Action: model.KeySet, Key: canonical, Value: record, Reason: detail}}
+		if key != canonical {
+			repair = append(repair, model.KeyChange{Action: model.KeyDelete, Key: key, Reason: "moved to " + canonical})
+		}
+		return []model.Issue{{Kind: kind, Key: key, Detail: detail, Repair: repair}}
+	}
+
+	if values[key] == nil {
+		return rewrite(model.IssuePlaceholder, "group key holds a placeholder instead of a record", groupRecord{})
+	}
+	var record groupRecord
+	if err := decodeValue(values[key], &record); err != nil {
+		return []model.Issue{{Kind: model.IssueUndecodable, Key: key, Detail: "value isn't a group record"}}
+	}
+	if record.Name != ref.Name || record.Version != ref.Version {
+		return rewrite(model.IssueMismatch, fmt.Sprintf("record of group %s names %s@%s", ref, record.Name, record.Version), record)
+	}
+	return relocate(key, canonical, values)
+}
+
+// relocate reports a value stored under another key than canonical. It is moved there, or deleted if
+// canonical already holds the same value. Marks canonical as taken in values, so later keys with the
+// same canonical key are reported as duplicates.
+func relocate(key string, canonical string, values map[string]interface{}) []model.Issue {
+	if canonical == key {
+		return nil
+	}
+	if existing, ok := values[canonical]; ok {
+		if !reflect.DeepEqual(existing, values[key]) {
+			return []model.Issue{{Kind: model.IssueDuplicate, Key: key, Detail: canonical + " holds a different value"}}
+		}
+		detail := "copy of " + canonical
+		return []model.Issue{{Kind: model.IssueDuplicate, Key: key, Detail: detail, Repair: []model.KeyChange{{Action: model.KeyDelete, Key: key, Reason: detail}}}}
+	}
+	values[canonical] = values[key]
+	detail := "belongs at " + canonical
+	return []model.Issue{{Kind: model.IssueNonCanonical, Key: key, Detail: detail, Repair: []model.KeyChange{{Action: model.KeyMove, Key: key, Target: canonical, Reason: detail}}}}
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"project/data"
+	"project/model"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreCheckDBRepository_Scan(t *testing.T) {
+	db, err := data.NewDatabase(data.Options{})
+	assert.NoError(t, err)
+	repo := NewStoreCheckDBRepository(db)
+	ctx := context.Background()
+
+	labelled := model.ConfigWithLabels{Config: model.Config{Name: "db", Version: "1.0"}, Labels: []model.Label{{Key: "env", Value: "prod"}}}
+	keys := map[string]interface{}{
+		// A group whose record names another group, with a labelled config stored under both layouts
+		"config-groups/fsck-group/1.0":                          groupRecord{Name: "other", Version: "1.0", Variables: map[string]string{"region": "eu"}},
+		"config-groups/fsck-group/1.0/configs/db/1.0":           labelled,
+		"config-groups/fsck-group/1.0/configs/env:prod;/db/1.0": labelled,
+		"config-groups/fsck-group/1.0/configs/cache/1.0":        "not a config",
+		// Configs of a group whose record was never written
+		"config-groups/fsck-orphan/1.0/configs/db/1.0": labelled.Config,
+		"configs/fsck-config/1.0":                      model.Config{Name: "fsck-config", Version: "2.0"},
+	}
+	for key, value := range keys {
+		assert.NoError(t, db.Put(ctx, key, value))
+	}
+	defer func() {
+		for _, prefix := range []string{"config-groups/fsck-", "configs/fsck-"} {
+			keys, _ := db.Keys(ctx, prefix)
+			for _, key := range keys {
+				db.Delete(ctx, key)
+			}
+		}
+	}()
+	ours := func(issues []model.Issue) map[string]model.IssueKind {
+		kinds := make(map[string]model.IssueKind)
+		for _, issue := range issues {
+			if strings.Contains(issue.Key, "fsck-") {
+				kinds[issue.Key] = issue.Kind
+			}
+		}
+		return kinds
+	}
+
+	scan, err := repo.Scan(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]model.IssueKind{
+		"config-groups/fsck-group/1.0":                   model.IssueMismatch,
+		"config-groups/fsck-group/1.0/configs/db/1.0":    model.IssueDuplicate,
+		"config-groups/fsck-group/1.0/configs/cache/1.0": model.IssueUndecodable,
+		"config-groups/fsck-orphan/1.0":                  model.IssueOrphan,
+		"configs/fsck-config/1.0":                        model.IssueMismatch,
+	}, ours(scan.Issues))
+	assert.Contains(t, scan.GroupConfigs, "config-groups/fsck-group/1.0/configs/env:prod;/db/1.0")
+
+	// Applying the repairs leaves only the issues which need an operator
+	var repairs []model.KeyChange
+	for _, issue := range scan.Issues {
+		if strings.Contains(issue.Key, "fsck-") {
+			repairs = append(repairs, issue.Repair...)
+		}
+	}
+	assert.NoError(t, repo.Apply(ctx, repairs))
+	var record groupRecord
+	assert.NoError(t, db.Get(ctx, "config-groups/fsck-group/1.0", &record))
+	assert.Equal(t, groupRecord{Name: "fsck-group", Version: "1.0", Variables: map[string]string{"region": "eu"}}, record)
+
+	scan, err = repo.Scan(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]model.IssueKind{
+		"config-groups/fsck-group/1.0/configs/cache/1.0": model.IssueUndecodable,
+		"configs/fsck-config/1.0":                        model.IssueMismatch,
+	}, ours(scan.Issues))
+}
@@ -0,0 +1,109 @@
+// The key helpers below describe where configurations and configuration groups are stored in the
+// Consul key-value store. Names, versions and labels are escaped with the encoding of the data
+// package, so they can't change the layout of the keys.
+package repositories
+
+import (
+	"fmt"
+	"project/data"
+	"project/model"
+	"strings"
+)
+
+const (
+	configsPrefix        = "configs/"
+	configGroupsPrefix   = "config-groups/"
+	changeRequestsPrefix = "change-requests/"
+	locksPrefix          = "locks/"
+	trashPrefix          = "trash/"
+)
+
+// CachedPrefixes are the prefixes read on every request, worth keeping in memory with data.CacheOptions.
+var CachedPrefixes = []string{configsPrefix, configGroupsPrefix}
+
+// configKey returns the key of a standalone config: configs/{name}/{version}
+func configKey(name string, version string) string {
+	return data.Key("configs", name, version)
+}
+
+// groupKey returns the key of a config group: config-groups/{name}/{version}
+func groupKey(name string, version string) string {
+	return data.Key("config-groups", name, version)
+}
+
+// groupTreePrefix returns the prefix under which all the configs of a group are stored.
+func groupTreePrefix(name string, version string) string {
+	return groupKey(name, version) + "/"
+}
+
+// groupConfigsPrefix returns the prefix of the keys of the configs of a group.
+func groupConfigsPrefix(name string, version string) string {
+	return groupTreePrefix(name, version) + "configs/"
+}
+
+// groupConfigKey returns the key of a config in a group. Configs without labels are stored at
+// config-groups/{name}/{version}/configs/{configName}/{configVersion}, configs with labels have the
+// labels segment before the config name, e.g. .../configs/env:prod;team:db;/{configName}/{configVersion}
+func groupConfigKey(name string, version string, config model.ConfigWithLabels) string {
+	if len(config.Labels) == 0 {
+		return groupConfigsPrefix(name, version) + data.Key(config.Name, config.Version)
+	}
+	return groupConfigsPrefix(name, version) + data.LabelsSegment(labelsMap(config.Labels)) + "/" + data.Key(config.Name, config.Version)
+}
+
+// parseGroupKey returns the group name and version of a key under configGroupsPrefix.
+func parseGroupKey(key string) (model.Ref, bool) {
+	// Only the group segments are decoded, the labels segment of the config keys below them isn't one
+	segments := strings.SplitN(key, "/", 4)
+	if len(segments) < 3 {
+		return model.Ref{}, false
+	}
+	group, err := data.SplitKey(strings.Join(segments[1:3], "/"))
+	if err != nil {
+		return model.Ref{}, false
+	}
+	return model.Ref{Name: group[0], Version: group[1]}, true
+}
+
+// changeRequestKey returns the key of the record of a change request: change-requests/{id}
+func changeRequestKey(id string) string {
+	return data.Key("change-requests", id)
+}
+
+// changeRequestConfigKey returns the key of the i-th config of the group proposed by a change request,
+// numbered so the configs are read back in the order they were proposed in.
+func changeRequestConfigKey(id string, i int) string {
+	return changeRequestKey(id) + "/configs/" + fmt.Sprintf("%04d", i)
+}
+
+// lockKey returns the key of the lock of a config or group: locks/configs/{name}/{version} or
+// locks/config-groups/{name}/{version}
+func lockKey(kind string, name string, version string) string {
+	if kind == model.KindConfigGroup {
+		return locksPrefix + groupKey(name, version)
+	}
+	return locksPrefix + configKey(name, version)
+}
+
+func labelsMap(labels []model.Label) map[string]string {
+	m := make(map[string]string, len(labels))
+	for _, label := range labels {
+		m[label.Key] = label.Value
+	}
+	return m
+}
+
+// reencryptionJobKey is the key holding the state of the last re-encryption job.
+const reencryptionJobKey = "admin/reencryption/job"
+
+// schemaKey is the key holding the schema marker of the keyspace.
+const schemaKey = "admin/schema"
+
+// scheduleKey is the key holding the state of the scheduler.
+const scheduleKey = "admin/schedule"
+
+// trashKey returns the key of a trash entry: trash/{id}. The keys of the deleted object are stored
+// below it, at trash/{id}/{key}.
+func trashKey(id string) string {
+	return data.Key("trash", id)
+}
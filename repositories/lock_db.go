@@ -0,0 +1,92 @@
+// The LockDBRepository stores the locks of configs and config groups in Consul under locks/, in the
+// layout of the keys of the objects they lock.
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"project/data"
+	"project/model"
+	"sort"
+)
+
+type LockDBRepository struct {
+	db *data.Database
+}
+
+func NewLockDBRepository(db *data.Database) *LockDBRepository {
+	return &LockDBRepository{
+		db: db,
+	}
+}
+
+func (repo *LockDBRepository) Get(ctx context.Context, kind string, name string, version string) (*model.Lock, error) {
+	ctx, end := instrument(ctx, "lock", "Get")
+	defer end()
+
+	var lock *model.Lock
+	if err := repo.db.Get(ctx, lockKey(kind, name, version), &lock); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// List returns the locks sorted by kind, name and version.
+func (repo *LockDBRepository) List(ctx context.Context) ([]model.Lock, error) {
+	ctx, end := instrument(ctx, "lock", "List")
+	defer end()
+
+	pairs, err := repo.db.Pairs(ctx, locksPrefix)
+	if err != nil {
+		return nil, err
+	}
+	locks := make([]model.Lock, 0, len(pairs))
+	for _, pair := range pairs {
+		var lock model.Lock
+		if err := pair.Decode(&lock); err != nil {
+			return nil, err
+		}
+		locks = append(locks, lock)
+	}
+	sort.SliceStable(locks, func(i, j int) bool {
+		if locks[i].Kind != locks[j].Kind {
+			return locks[i].Kind < locks[j].Kind
+		}
+		if locks[i].Name != locks[j].Name {
+			return locks[i].Name < locks[j].Name
+		}
+		return locks[i].Version < locks[j].Version
+	})
+	return locks, nil
+}
+
+// Lock only writes the lock if the key doesn't exist, so two callers can't both take it.
+func (repo *LockDBRepository) Lock(ctx context.Context, lock model.Lock) (bool, error) {
+	ctx, end := instrument(ctx, "lock", "Lock")
+	defer end()
+
+	return repo.db.PutCAS(ctx, lockKey(lock.Kind, lock.Name, lock.Version), lock, 0)
+}
+
+func (repo *LockDBRepository) Unlock(ctx context.Context, kind string, name string, version string) error {
+	ctx, end := instrument(ctx, "lock", "Unlock")
+	defer end()
+
+	return repo.db.Delete(ctx, lockKey(kind, name, version))
+}
+
+// unlocked returns the op checking that the config or group isn't locked, part of the transactions of
+// the writes locks forbid.
+func unlocked(kind string, name string, version string) data.TxnOp {
+	return data.TxnOp{Verb: data.TxnCheckNotExists, Key: lockKey(kind, name, version)}
+}
+
+// lockedError returns an error wrapping model.ErrLocked, naming the owner and the reason, if a write
+// failed with err because the config or group is locked, and err otherwise.
+func lockedError(ctx context.Context, db *data.Database, kind string, name string, version string, err error) error {
+	var lock *model.Lock
+	if getErr := db.Get(ctx, lockKey(kind, name, version), &lock); getErr != nil || lock == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s %s is locked by %s: %s", model.ErrLocked, kind, model.Ref{Name: name, Version: version}, lock.Owner, lock.Reason)
+}
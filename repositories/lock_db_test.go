@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+	"project/data"
+	"project/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockDBRepository(t *testing.T) {
+	ctx := context.Background()
+	db, err := data.NewDatabase(data.Options{})
+	require.NoError(t, err)
+	repo := NewLockDBRepository(db)
+	clean := func() {
+		require.NoError(t, db.Txn(ctx, []data.TxnOp{
+			{Verb: data.TxnDeleteTree, Key: lockKey(model.KindConfig, "lock-test", "1.0")},
+			{Verb: data.TxnDeleteTree, Key: lockKey(model.KindConfigGroup, "lock-test", "1.0")},
+		}))
+	}
+	clean()
+	t.Cleanup(clean)
+
+	lock, err := repo.Get(ctx, model.KindConfigGroup, "lock-test", "1.0")
+	require.NoError(t, err)
+	assert.Nil(t, lock)
+
+	groupLock := model.Lock{Kind: model.KindConfigGroup, Name: "lock-test", Version: "1.0", Owner: "alice", Reason: "release", LockedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	locked, err := repo.Lock(ctx, groupLock)
+	require.NoError(t, err)
+	assert.True(t, locked)
+	// A lock is only taken once
+	locked, err = repo.Lock(ctx, model.Lock{Kind: model.KindConfigGroup, Name: "lock-test", Version: "1.0", Owner: "bob"})
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	// The lock of a config with the same name and version is another key
+	configLock := groupLock
+	configLock.Kind = model.KindConfig
+	locked, err = repo.Lock(ctx, configLock)
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	lock, err = repo.Get(ctx, model.KindConfigGroup, "lock-test", "1.0")
+	require.NoError(t, err)
+	assert.Equal(t, &groupLock, lock)
+
+	locks, err := repo.List(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, locks, groupLock)
+	assert.Contains(t, locks, configLock)
+
+	require.NoError(t, repo.Unlock(ctx, model.KindConfigGroup, "lock-test", "1.0"))
+	lock, err = repo.Get(ctx, model.KindConfigGroup, "lock-test", "1.0")
+	require.NoError(t, err)
+	assert.Nil(t, lock)
+}
+
+func TestLockDBRepository_LockedWrites(t *testing.T) {
+	ctx := context.Background()
+	db, err := data.NewDatabase(data.Options{})
+	require.NoError(t, err)
+	repo := NewLockDBRepository(db)
+	configs := NewConfigDBRepository(db, nil)
+	groups := NewConfigGroupDBRepository(db, nil)
+	apply := NewApplyDBRepository(db, nil)
+	clean := func() {
+		require.NoError(t, db.Txn(ctx, []data.TxnOp{
+			{Verb: data.TxnDeleteTree, Key: lockKey(model.KindConfig, "locked-test", "1.0")},
+			{Verb: data.TxnDeleteTree, Key: lockKey(model.KindConfigGroup, "locked-test", "1.0")},
+			{Verb: data.TxnDelete, Key: configKey("locked-test", "1.0")},
+			{Verb: data.TxnDeleteTree, Key: groupKey("locked-test", "1.0")},
+		}))
+	}
+	clean()
+	t.Cleanup(clean)
+	t.Cleanup(func() { emptyTrash(t, db, "locked-test") })
+
+	require.NoError(t, configs.Add(ctx, model.Config{Name: "locked-test", Version: "1.0", Params: map[string]string{"a": "1"}}))
+	require.NoError(t, groups.Add(ctx, model.ConfigGroup{Name: "locked-test", Version: "1.0", Configs: []*model.ConfigWithLabels{
+		{Config: model.Config{Name: "db", Version: "1.0", Params: map[string]string{"a": "1"}}},
+	}}))
+	for _, kind := range []string{model.KindConfig, model.KindConfigGroup} {
+		locked, err := repo.Lock(ctx, model.Lock{Kind: kind, Name: "locked-test", Version: "1.0", Owner: "alice", Reason: "release"})
+		require.NoError(t, err)
+		require.True(t, locked)
+	}
+
+	// The writes removing what a lock keeps check the lock in their own transaction
+	assert.ErrorIs(t, configs.Delete(ctx, "locked-test", "1.0"), model.ErrLocked)
+	assert.ErrorIs(t, groups.Delete(ctx, "locked-test", "1.0"), model.ErrLocked)
+	assert.ErrorIs(t, groups.RemoveConfigFromGroup(ctx, "locked-test", "1.0", "db", "1.0"), model.ErrLocked)
+	indexes, err := apply.Indexes(ctx)
+	require.NoError(t, err)
+	err = apply.Apply(ctx, model.ApplyPlan{Steps: []model.PlanStep{{
+		Action: model.PlanDelete, Kind: model.KindConfig, Name: "locked-test", Version: "1.0",
+		Index: indexes[model.ObjectID(model.KindConfig, "locked-test", "1.0")], RequireUnlocked: true,
+	}}})
+	assert.ErrorIs(t, err, model.ErrLocked)
+
+	_, err = configs.Get(ctx, "locked-test", "1.0")
+	assert.NoError(t, err)
+	group, err := groups.Get(ctx, "locked-test", "1.0")
+	require.NoError(t, err)
+	assert.Len(t, group.Configs, 1)
+	assert.Empty(t, trashEntries(t, NewTrashDBRepository(db), "locked-test"))
+
+	// Adding configs to a locked group is allowed
+	assert.NoError(t, groups.AddConfigWithLabelToGroup(ctx, "locked-test", "1.0", model.ConfigWithLabels{Config: model.Config{Name: "cache", Version: "1.0"}}))
+}
@@ -0,0 +1,284 @@
+// The MigrationDBRepository holds the migrations of the Consul keyspace: it plans the key changes
+// bringing keys written by older versions to the current layout, applies them and keeps the schema
+// marker recording the last migration applied.
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project/data"
+	"project/model"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// migrations are the steps of the keyspace layout, in version order. New migrations are appended with
+// the next version, released ones never change.
+var migrations = []model.Migration{
+	{
+		Version:     1,
+		Name:        "canonical-keys",
+		Description: "moves configs, groups and group configs to their escaped keys, with the sorted labels of group configs in the key, and deletes stray duplicates",
+	},
+	{
+		Version:     2,
+		Name:        "group-records",
+		Description: "writes a record for groups stored without one or with an empty placeholder value",
+	},
+}
+
+type MigrationDBRepository struct {
+	db *data.Database
+}
+
+func NewMigrationDBRepository(db *data.Database) *MigrationDBRepository {
+	return &MigrationDBRepository{
+		db: db,
+	}
+}
+
+func (repo *MigrationDBRepository) GetSchema(ctx context.Context) (model.SchemaMarker, error) {
+	ctx, end := instrument(ctx, "migration", "GetSchema")
+	defer end()
+
+	var marker model.SchemaMarker
+	if err := repo.db.Get(ctx, schemaKey, &marker); err != nil {
+		return model.SchemaMarker{}, err
+	}
+	return marker, nil
+}
+
+func (repo *MigrationDBRepository) SaveSchema(ctx context.Context, marker model.SchemaMarker) error {
+	ctx, end := instrument(ctx, "migration", "SaveSchema")
+	defer end()
+
+	return repo.db.Put(ctx, schemaKey, marker)
+}
+
+func (repo *MigrationDBRepository) Migrations() []model.Migration {
+	return append([]model.Migration(nil), migrations...)
+}
+
+func (repo *MigrationDBRepository) Plan(ctx context.Context, version int) ([]model.KeyChange, []string, error) {
+	ctx, end := instrument(ctx, "migration", "Plan")
+	defer end()
+
+	switch version {
+	case 1:
+		return repo.planCanonicalKeys(ctx)
+	case 2:
+		return repo.planGroupRecords(ctx)
+	}
+	return nil, nil, fmt.Errorf("unknown migration %d", version)
+}
+
+func (repo *MigrationDBRepository) Apply(ctx context.Context, changes []model.KeyChange) error {
+	ctx, end := instrument(ctx, "migration", "Apply")
+	defer end()
+
+	return applyKeyChanges(ctx, repo.db, changes)
+}
+
+// applyKeyChanges batches the changes into transactions. A move reads the value right before the
+// transaction writing it, a value deleted in the meantime is not moved.
+func applyKeyChanges(ctx context.Context, db *data.Database, changes []model.KeyChange) error {
+	var ops []data.TxnOp
+	flush := func() error {
+		if len(ops) == 0 {
+			return nil
+		}
+		err := db.Txn(ctx, ops)
+		ops = nil
+		return err
+	}
+	for _, change := range changes {
+		// A move takes two operations, so it never spans two transactions
+		if len(ops)+2 > data.MaxTxnOps {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		switch change.Action {
+		case model.KeyMove:
+			var value json.RawMessage
+			if err := db.Get(ctx, change.Key, &value); err != nil {
+				return err
+			}
+			if value == nil {
+				continue
+			}
+			ops = append(ops, data.TxnOp{Verb: data.TxnSet, Key: change.Target, Value: value}, data.TxnOp{Verb: data.TxnDelete, Key: change.Key})
+		case model.KeyDelete:
+			ops = append(ops, data.TxnOp{Verb: data.TxnDelete, Key: change.Key})
+		case model.KeySet:
+			ops = append(ops, data.TxnOp{Verb: data.TxnSet, Key: change.Key, Value: change.Value})
+		default:
+			return fmt.Errorf("unknown key change %q of %s", change.Action, change.Key)
+		}
+	}
+	return flush()
+}
+
+// planCanonicalKeys compares every config and group key with the key the current version would write
+// the same value under. Keys written before names were escaped, labelled configs which Add stored
+// without their labels in the key and labels in the order they were given are all moved. A key whose
+// canonical key already holds the same value is a stray copy and deleted, one whose canonical key holds
+// a different value is left for an operator to resolve.
+func (repo *MigrationDBRepository) planCanonicalKeys(ctx context.Context) ([]model.KeyChange, []string, error) {
+	values, err := listValues(ctx, repo.db, configsPrefix, configGroupsPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var changes []model.KeyChange
+	var conflicts []string
+	for _, key := range sortedKeys(values) {
+		canonical, reason, err := canonicalKey(key, values[key])
+		if err != nil {
+			conflicts = append(conflicts, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		if canonical == key {
+			continue
+		}
+		if canonical == "" {
+			changes = append(changes, model.KeyChange{Action: model.KeyDelete, Key: key, Reason: reason})
+			continue
+		}
+		if existing, ok := values[canonical]; ok {
+			if reflect.DeepEqual(existing, values[key]) {
+				changes = append(changes, model.KeyChange{Action: model.KeyDelete, Key: key, Reason: "duplicate of " + canonical})
+			} else {
+				conflicts = append(conflicts, fmt.Sprintf("%s: %s already holds a different value", key, canonical))
+			}
+			continue
+		}
+		changes = append(changes, model.KeyChange{Action: model.KeyMove, Key: key, Target: canonical, Reason: reason})
+		// Later keys with the same canonical key are duplicates of this one
+		values[canonical] = values[key]
+	}
+	return changes, conflicts, nil
+}
+
+// canonicalKey returns the key the value under key belongs at and why it differs, or an empty key for
+// a stray key which belongs nowhere.
+func canonicalKey(key string, value interface{}) (string, string, error) {
+	segments := strings.Split(key, "/")
+	if segments[0]+"/" == configsPrefix {
+		var config model.Config
+		if err := decodeValue(value, &config); err != nil || config.Name == "" || config.Version == "" {
+			return "", "", errors.New("value isn't a config")
+		}
+		return configKey(config.Name, config.Version), "name or version not escaped", nil
+	}
+
+	if len(segments) < 3 {
+		return "", "key outside of any group", nil
+	}
+	group := model.Ref{Name: legacySegment(segments[1]), Version: legacySegment(segments[2])}
+	if len(segments) == 3 {
+		return groupKey(group.Name, group.Version), "group name or version not escaped", nil
+	}
+
+	var config model.ConfigWithLabels
+	if err := decodeValue(value, &config); err != nil || config.Name == "" || config.Version == "" {
+		return "", "", errors.New("value isn't a group config")
+	}
+	reason := "key not in canonical form"
+	switch {
+	case segments[3] != "configs":
+		reason = "config stored outside of configs/"
+	case len(config.Labels) > 0 && len(segments) == 6:
+		reason = "labels missing from key"
+	case len(config.Labels) == 0 && len(segments) == 7:
+		reason = "labels in key of a config without labels"
+	}
+	return groupConfigKey(group.Name, group.Version, config), reason, nil
+}
+
+// planGroupRecords finds the groups without a record. Older versions stored no value under the group
+// key of a group with configs, and a null placeholder under the one of an empty group.
+func (repo *MigrationDBRepository) planGroupRecords(ctx context.Context) ([]model.KeyChange, []string, error) {
+	values, err := listValues(ctx, repo.db, configGroupsPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	groups := make(map[model.Ref]bool)
+	var conflicts []string
+	for _, key := range sortedKeys(values) {
+		ref, ok := parseGroupKey(key)
+		if !ok {
+			conflicts = append(conflicts, key+": key outside of any group")
+			continue
+		}
+		groups[ref] = true
+	}
+	refs := make([]model.Ref, 0, len(groups))
+	for ref := range groups {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return groupKey(refs[i].Name, refs[i].Version) < groupKey(refs[j].Name, refs[j].Version)
+	})
+
+	var changes []model.KeyChange
+	for _, ref := range refs {
+		key := groupKey(ref.Name, ref.Version)
+		value, ok := values[key]
+		if ok && value != nil {
+			continue
+		}
+		reason := "group without record"
+		if ok {
+			reason = "placeholder group key"
+		}
+		changes = append(changes, model.KeyChange{Action: model.KeySet, Key: key, Value: groupRecord{Name: ref.Name, Version: ref.Version}, Reason: reason})
+	}
+	return changes, conflicts, nil
+}
+
+// listValues returns the decoded values under the prefixes.
+func listValues(ctx context.Context, db *data.Database, prefixes ...string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for _, prefix := range prefixes {
+		prefixValues, err := db.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range prefixValues {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// legacySegment decodes a key segment. Segments written before keys were escaped may not be valid
+// escaped segments, they are taken as they are.
+func legacySegment(segment string) string {
+	if decoded, err := data.UnescapeSegment(segment); err == nil {
+		return decoded
+	}
+	return segment
+}
+
+// decodeValue converts a value as returned by data.Database.List into out.
+func decodeValue(value interface{}, out interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, out)
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"project/data"
+	"project/model"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationDBRepository_PlanApply(t *testing.T) {
+	db, err := data.NewDatabase(data.Options{})
+	assert.NoError(t, err)
+	repo := NewMigrationDBRepository(db)
+	groups := NewConfigGroupDBRepository(db, nil)
+	ctx := context.Background()
+
+	// Keys in the layouts written by older versions
+	labelled := model.ConfigWithLabels{Config: model.Config{Name: "db", Version: "1.0", Params: map[string]string{"port": "5432"}}, Labels: []model.Label{{Key: "env", Value: "prod"}}}
+	duplicate := model.ConfigWithLabels{Config: model.Config{Name: "cache", Version: "1.0"}, Labels: []model.Label{{Key: "env", Value: "prod"}}}
+	legacy := map[string]interface{}{
+		// A labelled config Add stored without its labels in the key, in a group without a record
+		"config-groups/legacy-group/1.0/configs/db/1.0": labelled,
+		// A labelled config stored both with and without its labels
+		"config-groups/legacy-group/1.0/configs/cache/1.0":           duplicate,
+		"config-groups/legacy-group/1.0/configs/env:prod;/cache/1.0": duplicate,
+		// An empty group with a placeholder and a config whose name wasn't escaped
+		"config-groups/legacy-empty/1.0": nil,
+		"configs/legacy config/1.0":      model.Config{Name: "legacy config", Version: "1.0"},
+	}
+	for key, value := range legacy {
+		assert.NoError(t, db.Put(ctx, key, value))
+	}
+	defer func() {
+		for _, prefix := range []string{"config-groups/legacy-group/", "config-groups/legacy-empty/", "configs/legacy"} {
+			keys, _ := db.Keys(ctx, prefix)
+			for _, key := range keys {
+				db.Delete(ctx, key)
+			}
+		}
+	}()
+	ours := func(changes []model.KeyChange) []model.KeyChange {
+		var filtered []model.KeyChange
+		for _, change := range changes {
+			if strings.Contains(change.Key, "legacy") {
+				filtered = append(filtered, change)
+			}
+		}
+		return filtered
+	}
+
+	changes, _, err := repo.Plan(ctx, 1)
+	assert.NoError(t, err)
+	changes = ours(changes)
+	assert.Equal(t, []model.KeyChange{
+		{Action: model.KeyDelete, Key: "config-groups/legacy-group/1.0/configs/cache/1.0", Reason: "duplicate of config-groups/legacy-group/1.0/configs/env:prod;/cache/1.0"},
+		{Action: model.KeyMove, Key: "config-groups/legacy-group/1.0/configs/db/1.0", Target: "config-groups/legacy-group/1.0/configs/env:prod;/db/1.0", Reason: "labels missing from key"},
+		{Action: model.KeyMove, Key: "configs/legacy config/1.0", Target: "configs/legacy%20config/1.0", Reason: "name or version not escaped"},
+	}, changes)
+	assert.NoError(t, repo.Apply(ctx, changes))
+
+	changes, _, err = repo.Plan(ctx, 2)
+	assert.NoError(t, err)
+	changes = ours(changes)
+	assert.Equal(t, []model.KeyChange{
+		{Action: model.KeySet, Key: "config-groups/legacy-empty/1.0", Value: groupRecord{Name: "legacy-empty", Version: "1.0"}, Reason: "placeholder group key"},
+		{Action: model.KeySet, Key: "config-groups/legacy-group/1.0", Value: groupRecord{Name: "legacy-group", Version: "1.0"}, Reason: "group without record"},
+	}, changes)
+	assert.NoError(t, repo.Apply(ctx, changes))
+
+	// The migrated group reads and removes its labelled configs by their canonical keys
+	group, err := groups.Get(ctx, "legacy-group", "1.0")
+	assert.NoError(t, err)
+	assert.Len(t, group.Configs, 2)
+	err = groups.RemoveConfigsWithLabelsFromGroup(ctx, "legacy-group", "1.0", labelled.Labels, "db", "1.0")
+	assert.NoError(t, err)
+	keys, err := db.Keys(ctx, groupConfigsPrefix("legacy-group", "1.0"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"config-groups/legacy-group/1.0/configs/env:prod;/cache/1.0"}, keys)
+
+	// Nothing is left to migrate
+	changes, _, err = repo.Plan(ctx, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, ours(changes))
+	changes, _, err = repo.Plan(ctx, 2)
+	assert.NoError(t, err)
+	assert.Empty(t, ours(changes))
+}
@@ -0,0 +1,120 @@
+// The ReencryptionDBRepository walks the configs, config groups, change requests and trash entries
+// stored in Consul and rewraps their encrypted params with the primary key of the keyring, and persists
+// the state of the job doing so.
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project/data"
+	"project/model"
+	"project/secrets"
+	"sort"
+)
+
+// maxCASAttempts is how many times a value modified concurrently is read and rewrapped again.
+const maxCASAttempts = 3
+
+type ReencryptionDBRepository struct {
+	db      *data.Database
+	keyring *secrets.Keyring
+}
+
+func NewReencryptionDBRepository(db *data.Database, keyring *secrets.Keyring) *ReencryptionDBRepository {
+	return &ReencryptionDBRepository{
+		db:      db,
+		keyring: keyring,
+	}
+}
+
+func (repo *ReencryptionDBRepository) GetJob(ctx context.Context) (*model.ReencryptionJob, error) {
+	ctx, end := instrument(ctx, "reencryption", "GetJob")
+	defer end()
+
+	var job *model.ReencryptionJob
+	if err := repo.db.Get(ctx, reencryptionJobKey, &job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (repo *ReencryptionDBRepository) SaveJob(ctx context.Context, job model.ReencryptionJob) error {
+	ctx, end := instrument(ctx, "reencryption", "SaveJob")
+	defer end()
+
+	return repo.db.Txn(ctx, []data.TxnOp{{Verb: data.TxnSet, Key: reencryptionJobKey, Value: job}})
+}
+
+func (repo *ReencryptionDBRepository) Keys(ctx context.Context) ([]string, error) {
+	ctx, end := instrument(ctx, "reencryption", "Keys")
+	defer end()
+
+	var keys []string
+	for _, prefix := range []string{configsPrefix, configGroupsPrefix, changeRequestsPrefix, trashPrefix} {
+		prefixKeys, err := repo.db.Keys(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, prefixKeys...)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Reencrypt reads the value as generic JSON, so configs, group configs with labels and group records
+// are all handled alike and no field is lost when the value is written back. The write only succeeds
+// if the value wasn't modified since it was read, otherwise it is retried.
+func (repo *ReencryptionDBRepository) Reencrypt(ctx context.Context, key string) (bool, error) {
+	ctx, end := instrument(ctx, "reencryption", "Reencrypt")
+	defer end()
+
+	if repo.keyring == nil {
+		return false, errors.New("re-encryption requires an encryption keyring to be configured")
+	}
+
+	for attempt := 0; attempt < maxCASAttempts; attempt++ {
+		var value map[string]json.RawMessage
+		index, err := repo.db.GetWithIndex(ctx, key, &value)
+		if err != nil {
+			return false, err
+		}
+		if index == 0 || value["params"] == nil {
+			return false, nil
+		}
+
+		var params map[string]string
+		if err := json.Unmarshal(value["params"], &params); err != nil {
+			return false, fmt.Errorf("%w: %s: %v", model.ErrUnreadableSecret, key, err)
+		}
+		changed := false
+		for name, param := range params {
+			if !repo.keyring.NeedsRewrap(param) {
+				continue
+			}
+			rewrapped, err := repo.keyring.Rewrap(param)
+			if err != nil {
+				return false, fmt.Errorf("%w: %s: param %s: %v", model.ErrUnreadableSecret, key, name, err)
+			}
+			params[name] = rewrapped
+			changed = true
+		}
+		if !changed {
+			return false, nil
+		}
+
+		value["params"], err = json.Marshal(params)
+		if err != nil {
+			return false, err
+		}
+		ok, err := repo.db.PutCAS(ctx, key, value, index)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("%s: value kept changing while it was re-encrypted", key)
+}
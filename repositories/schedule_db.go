@@ -0,0 +1,38 @@
+// The ScheduleDBRepository persists the state of the scheduler in Consul, written with a
+// compare-and-set so concurrent servers don't handle the same run twice.
+package repositories
+
+import (
+	"context"
+	"project/data"
+	"project/model"
+)
+
+type ScheduleDBRepository struct {
+	db *data.Database
+}
+
+func NewScheduleDBRepository(db *data.Database) *ScheduleDBRepository {
+	return &ScheduleDBRepository{
+		db: db,
+	}
+}
+
+func (repo *ScheduleDBRepository) GetState(ctx context.Context) (model.ScheduleState, uint64, error) {
+	ctx, end := instrument(ctx, "schedule", "GetState")
+	defer end()
+
+	var state model.ScheduleState
+	index, err := repo.db.GetWithIndex(ctx, scheduleKey, &state)
+	if err != nil {
+		return model.ScheduleState{}, 0, err
+	}
+	return state, index, nil
+}
+
+func (repo *ScheduleDBRepository) SaveState(ctx context.Context, state model.ScheduleState, index uint64) (bool, error) {
+	ctx, end := instrument(ctx, "schedule", "SaveState")
+	defer end()
+
+	return repo.db.PutCAS(ctx, scheduleKey, state, index)
+}
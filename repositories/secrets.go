@@ -0,0 +1,54 @@
+// The helpers below encrypt the secret params of a config before it is written to the database and
+// decrypt them after it is read, using the keyring the repositories were created with.
+package repositories
+
+import (
+	"fmt"
+	"project/model"
+	"project/secrets"
+)
+
+// sealConfig returns a copy of the config with its secret params encrypted. Values which are already
+// encrypted, e.g. copied from another key or imported from an export, are kept as they are. Configs
+// coming from the API can't carry those, `Config.Validate` rejects them.
+func sealConfig(keyring *secrets.Keyring, config model.Config) (model.Config, error) {
+	if len(config.Secrets) == 0 {
+		return config, nil
+	}
+	for _, secret := range config.Secrets {
+		if _, ok := config.Params[secret]; !ok {
+			return model.Config{}, fmt.Errorf("secret param %s is not one of the params of the config", secret)
+		}
+	}
+
+	params, err := keyring.EncryptParams(config.Params, config.Secrets)
+	if err != nil {
+		return model.Config{}, err
+	}
+	config.Params = params
+	return config, nil
+}
+
+// openConfig decrypts the secret params of a config read from the database in place.
+func openConfig(keyring *secrets.Keyring, config *model.Config) error {
+	if len(config.Secrets) == 0 {
+		return nil
+	}
+	params, err := keyring.DecryptParams(config.Params, config.Secrets)
+	if err != nil {
+		return err
+	}
+	config.Params = params
+	return nil
+}
+
+// sealGroupConfig returns a copy of a group config with its secret params encrypted.
+func sealGroupConfig(keyring *secrets.Keyring, config *model.ConfigWithLabels) (*model.ConfigWithLabels, error) {
+	sealed, err := sealConfig(keyring, config.Config)
+	if err != nil {
+		return nil, err
+	}
+	copied := *config
+	copied.Config = sealed
+	return &copied, nil
+}
@@ -0,0 +1,46 @@
+// The StatusDBRepository reports whether the Consul cluster backing the store is reachable, its
+// leader and peers, and how long a round trip to it takes.
+package repositories
+
+import (
+	"context"
+	"errors"
+	"project/data"
+	"project/model"
+	"time"
+)
+
+type StatusDBRepository struct {
+	db *data.Database
+}
+
+func NewStatusDBRepository(db *data.Database) *StatusDBRepository {
+	return &StatusDBRepository{
+		db: db,
+	}
+}
+
+func (repo *StatusDBRepository) Status(ctx context.Context) (model.StoreStatus, error) {
+	ctx, end := instrument(ctx, "status", "Status")
+	defer end()
+
+	status := model.StoreStatus{Backend: "consul"}
+
+	start := time.Now()
+	leader, err := repo.db.Leader(ctx)
+	status.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		return status, err
+	}
+	if leader == "" {
+		return status, errors.New("consul cluster has no leader")
+	}
+	status.Leader = leader
+
+	peers, err := repo.db.Peers(ctx)
+	if err != nil {
+		return status, err
+	}
+	status.Peers = peers
+	return status, nil
+}
@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"context"
+	"log/slog"
+	"project/metrics"
+	"project/tracing"
+	"time"
+)
+
+// instrument starts a span for a repository method, the returned func ends it, records the duration
+// of the method and logs the call at debug level, with the request ID of the context.
+func instrument(ctx context.Context, repository string, method string) (context.Context, func()) {
+	ctx, span := tracing.Start(ctx, repository+"Repository."+method)
+	observe := metrics.ObserveRepository(repository, method)
+	start := time.Now()
+	return ctx, func() {
+		observe()
+		span.End()
+		slog.DebugContext(ctx, "repository call", "repository", repository, "method", method, "duration_ms", float64(time.Since(start).Microseconds())/1000)
+	}
+}
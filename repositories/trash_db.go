@@ -0,0 +1,307 @@
+// The TrashDBRepository keeps deleted configs and config groups in Consul: the entry describing the
+// deleted object under trash/{id}, and below it the keys of the object with their values as they were
+// stored, so secret params stay encrypted and nothing has to be decoded to restore them.
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"project/data"
+	"project/model"
+	"strings"
+	"time"
+)
+
+type TrashDBRepository struct {
+	db *data.Database
+}
+
+func NewTrashDBRepository(db *data.Database) *TrashDBRepository {
+	return &TrashDBRepository{
+		db: db,
+	}
+}
+
+// List returns the entries oldest first, their IDs starting with the time of the deletion.
+func (repo *TrashDBRepository) List(ctx context.Context) ([]model.TrashEntry, error) {
+	ctx, end := instrument(ctx, "trash", "List")
+	defer end()
+
+	pairs, err := repo.db.Pairs(ctx, trashPrefix)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]model.TrashEntry, 0)
+	for _, pair := range pairs {
+		// The keys of the deleted objects are below the entries
+		if strings.Count(pair.Key, "/") != 1 {
+			continue
+		}
+		var entry model.TrashEntry
+		if err := pair.Decode(&entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Restore writes the keys of the object back and removes the entry. The object is checked not to exist
+// in the first transaction, and the last one writes the key of the object itself with a
+// compare-and-set, together with the removal of the entry, both guarded by the index of the entry. A
+// restore which fails halfway, e.g. because the object was created again in the meantime, leaves the
+// entry in the trash and removes what it wrote if the object still doesn't exist.
+func (repo *TrashDBRepository) Restore(ctx context.Context, id string) (model.TrashEntry, error) {
+	ctx, end := instrument(ctx, "trash", "Restore")
+	defer end()
+
+	pairs, err := repo.db.Pairs(ctx, trashKey(id))
+	if err != nil {
+		return model.TrashEntry{}, err
+	}
+	var entry *model.TrashEntry
+	var entryIndex uint64
+	var copies []data.Pair
+	prefix := trashKey(id) + "/"
+	for _, pair := range pairs {
+		if pair.Key == trashKey(id) {
+			if err := pair.Decode(&entry); err != nil {
+				return model.TrashEntry{}, err
+			}
+			entryIndex = pair.ModifyIndex
+			continue
+		}
+		if strings.HasPrefix(pair.Key, prefix) {
+			copies = append(copies, data.Pair{Key: strings.TrimPrefix(pair.Key, prefix), Value: pair.Value})
+		}
+	}
+	if entry == nil {
+		return model.TrashEntry{}, fmt.Errorf("%w: %s", model.ErrTrashEntryNotFound, id)
+	}
+	if len(copies) == 0 {
+		return model.TrashEntry{}, fmt.Errorf("trash entry %s holds no %s %s", id, entry.Kind, model.Ref{Name: entry.Name, Version: entry.Version})
+	}
+
+	key := trashedObjectKey(*entry)
+	checkEntry := data.TxnOp{Verb: data.TxnCheckIndex, Key: trashKey(id), Index: entryIndex}
+	ops := []data.TxnOp{checkEntry, {Verb: data.TxnCheckNotExists, Key: key}}
+	// Groups deleted before their record was kept hold only their configs, nothing else marks them as
+	// existing
+	object := data.TxnOp{Verb: data.TxnCheckNotExists, Key: key}
+	var written []string
+	for _, pair := range copies {
+		if pair.Key == key {
+			object = data.TxnOp{Verb: data.TxnCAS, Key: key, Value: rawValue(pair.Value), Index: 0}
+			continue
+		}
+		ops = append(ops, data.TxnOp{Verb: data.TxnSet, Key: pair.Key, Value: rawValue(pair.Value)})
+		written = append(written, pair.Key)
+	}
+	final := []data.TxnOp{checkEntry, object, {Verb: data.TxnDeleteTree, Key: prefix}, {Verb: data.TxnDelete, Key: trashKey(id)}}
+
+	batches := [][]data.TxnOp{append(ops, final[1:]...)}
+	if len(ops)+len(final)-1 > data.MaxTxnOps {
+		batches = [][]data.TxnOp{ops, final}
+	}
+	if _, err := runBatched(ctx, repo.db, batches); err != nil {
+		if len(batches) > 1 {
+			repo.rollback(ctx, key, written)
+		}
+		var existing json.RawMessage
+		index, getErr := repo.db.GetWithIndex(ctx, key, &existing)
+		if getErr != nil {
+			return model.TrashEntry{}, err
+		}
+		if index != 0 {
+			return model.TrashEntry{}, fmt.Errorf("%w: %s %s was created again since it was deleted", model.ErrRestoreConflict, entry.Kind, model.Ref{Name: entry.Name, Version: entry.Version})
+		}
+		var current model.TrashEntry
+		if index, getErr := repo.db.GetWithIndex(ctx, trashKey(id), &current); getErr == nil && index != entryIndex {
+			return model.TrashEntry{}, fmt.Errorf("%w: %s was restored or purged in the meantime", model.ErrTrashEntryNotFound, id)
+		}
+		return model.TrashEntry{}, err
+	}
+	return *entry, nil
+}
+
+// rollback removes the keys written by a restore which failed, unless the object was created in the
+// meantime and the keys may be its own.
+func (repo *TrashDBRepository) rollback(ctx context.Context, key string, written []string) {
+	for start := 0; start < len(written); start += data.MaxTxnOps - 1 {
+		ops := []data.TxnOp{{Verb: data.TxnCheckNotExists, Key: key}}
+		for _, k := range written[start:min(start+data.MaxTxnOps-1, len(written))] {
+			ops = append(ops, data.TxnOp{Verb: data.TxnDelete, Key: k})
+		}
+		if err := repo.db.Txn(ctx, ops); err != nil {
+			return
+		}
+	}
+}
+
+func (repo *TrashDBRepository) Purge(ctx context.Context, id string) error {
+	ctx, end := instrument(ctx, "trash", "Purge")
+	defer end()
+
+	return repo.db.Txn(ctx, []data.TxnOp{
+		{Verb: data.TxnDeleteTree, Key: trashKey(id) + "/"},
+		{Verb: data.TxnDelete, Key: trashKey(id)},
+	})
+}
+
+// trashAttempts is how many times a deletion reads the object again when it changed while it was moved.
+const trashAttempts = 3
+
+// moveToTrash moves a config or group to the trash with removeOps, after check accepted its stored
+// keys. The keys are copied below a new trash entry first, in as many transactions as needed, and the
+// last transaction writes the entry and removes the object, so an object is never gone without its
+// entry. It also checks that the keys are still at the indexes they were read at, so e.g. a config
+// added to the group in the meantime isn't lost. A changed object is read and checked again, up to
+// trashAttempts times.
+func moveToTrash(ctx context.Context, db *data.Database, kind string, name string, version string, removeOps []data.TxnOp, check func(pairs []data.Pair) error) error {
+	for attempt := 1; ; attempt++ {
+		pairs, err := storedPairs(ctx, db, kind, name, version)
+		if err != nil {
+			return err
+		}
+		if err := check(pairs); err != nil {
+			return err
+		}
+		err = movePairs(ctx, db, kind, name, version, pairs, removeOps)
+		if err == nil {
+			return nil
+		}
+		current, readErr := storedPairs(ctx, db, kind, name, version)
+		if readErr != nil || attempt == trashAttempts {
+			return err
+		}
+		if samePairs(pairs, current) {
+			return lockedError(ctx, db, kind, name, version, err)
+		}
+	}
+}
+
+// movePairs writes the copies of the pairs, then the entry with removeOps and the checks that the pairs
+// are unchanged. Copies written by a move which failed are removed, they are never read without their
+// entry.
+func movePairs(ctx context.Context, db *data.Database, kind string, name string, version string, pairs []data.Pair, removeOps []data.TxnOp) error {
+	copies, entry, err := trashOps(kind, name, version, pairs)
+	if err != nil {
+		return err
+	}
+	final := append([]data.TxnOp{entry}, unchanged(kind, name, version, pairs, data.MaxTxnOps-1-len(removeOps))...)
+	final = append(final, removeOps...)
+	if _, err := runBatched(ctx, db, [][]data.TxnOp{copies, final}); err != nil {
+		db.Txn(ctx, []data.TxnOp{
+			{Verb: data.TxnCheckNotExists, Key: entry.Key},
+			{Verb: data.TxnDeleteTree, Key: entry.Key + "/"},
+		})
+		return err
+	}
+	return nil
+}
+
+// unchanged returns the checks that the pairs of an object are still at the indexes they were read at,
+// in at most room ops. The key of the object comes first, for a group its record, which every write of
+// the group rewrites. The checks of the configs of a group are left out when they don't fit.
+func unchanged(kind string, name string, version string, pairs []data.Pair, room int) []data.TxnOp {
+	key := objectKey(kind, name, version)
+	// Groups stored before records were kept get one with their next write
+	checks := []data.TxnOp{{Verb: data.TxnCheckNotExists, Key: key}}
+	var configs []data.TxnOp
+	for _, pair := range pairs {
+		if pair.Key == key {
+			checks[0] = data.TxnOp{Verb: data.TxnCheckIndex, Key: key, Index: pair.ModifyIndex}
+			continue
+		}
+		configs = append(configs, data.TxnOp{Verb: data.TxnCheckIndex, Key: pair.Key, Index: pair.ModifyIndex})
+	}
+	if len(checks)+len(configs) <= room {
+		checks = append(checks, configs...)
+	}
+	return checks
+}
+
+// samePairs reports whether both reads of an object returned the same keys at the same indexes.
+func samePairs(a []data.Pair, b []data.Pair) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || a[i].ModifyIndex != b[i].ModifyIndex {
+			return false
+		}
+	}
+	return true
+}
+
+// trashOps returns the ops copying the pairs of an object below a new trash entry, and the op writing
+// the entry, which belongs in the transaction removing the object.
+func trashOps(kind string, name string, version string, pairs []data.Pair) ([]data.TxnOp, data.TxnOp, error) {
+	now := time.Now().UTC()
+	id, err := newTrashID(now)
+	if err != nil {
+		return nil, data.TxnOp{}, err
+	}
+	entry := model.TrashEntry{ID: id, Kind: kind, Name: name, Version: version, DeletedAt: now}
+	copies := make([]data.TxnOp, 0, len(pairs))
+	for _, pair := range pairs {
+		copies = append(copies, data.TxnOp{Verb: data.TxnSet, Key: trashKey(id) + "/" + pair.Key, Value: rawValue(pair.Value)})
+	}
+	return copies, data.TxnOp{Verb: data.TxnSet, Key: trashKey(id), Value: entry}, nil
+}
+
+// storedPairs returns the keys of a config or a group with their values as they are stored and their
+// modify indexes, the record of a group first. Groups stored before records were kept have none.
+func storedPairs(ctx context.Context, db *data.Database, kind string, name string, version string) ([]data.Pair, error) {
+	key := objectKey(kind, name, version)
+	var value json.RawMessage
+	index, err := db.GetWithIndex(ctx, key, &value)
+	if err != nil {
+		return nil, err
+	}
+	var pairs []data.Pair
+	if index != 0 {
+		pairs = append(pairs, data.Pair{Key: key, Value: value, ModifyIndex: index})
+	}
+	if kind != model.KindConfigGroup {
+		return pairs, nil
+	}
+	configs, err := db.Pairs(ctx, groupTreePrefix(name, version))
+	if err != nil {
+		return nil, err
+	}
+	return append(pairs, configs...), nil
+}
+
+// trashedObjectKey returns the key of the config or the record of the group of the entry.
+func trashedObjectKey(entry model.TrashEntry) string {
+	return objectKey(entry.Kind, entry.Name, entry.Version)
+}
+
+// objectKey returns the key of a config, or the key of the record of a group.
+func objectKey(kind string, name string, version string) string {
+	if kind == model.KindConfigGroup {
+		return groupKey(name, version)
+	}
+	return configKey(name, version)
+}
+
+// rawValue returns the stored JSON value to be written back as it is, an empty value as null.
+func rawValue(value []byte) json.RawMessage {
+	if len(value) == 0 {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(value)
+}
+
+// newTrashID returns an ID starting with the time of the deletion, so IDs sort by age.
+func newTrashID(now time.Time) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return now.Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix), nil
+}
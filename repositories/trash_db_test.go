@@ -0,0 +1,241 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"project/data"
+	"project/model"
+	"project/secrets"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// emptyTrash purges the trash entries of the objects with the names, so tests deleting them don't leave
+// entries behind.
+func emptyTrash(t *testing.T, db *data.Database, names ...string) {
+	ctx := context.Background()
+	repo := NewTrashDBRepository(db)
+	entries, err := repo.List(ctx)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		for _, name := range names {
+			if entry.Name == name {
+				require.NoError(t, repo.Purge(ctx, entry.ID))
+			}
+		}
+	}
+}
+
+// trashEntries returns the entries of the objects with the name.
+func trashEntries(t *testing.T, repo *TrashDBRepository, name string) []model.TrashEntry {
+	entries, err := repo.List(context.Background())
+	require.NoError(t, err)
+	var found []model.TrashEntry
+	for _, entry := range entries {
+		if entry.Name == name {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+func TestTrashDBRepository_Config(t *testing.T) {
+	ctx := context.Background()
+	db, err := data.NewDatabase(data.Options{})
+	require.NoError(t, err)
+	keyring, err := secrets.NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	require.NoError(t, err)
+	configs := NewConfigDBRepository(db, keyring)
+	repo := NewTrashDBRepository(db)
+	// Encrypted values are left out of the store, other tests read it without a keyring
+	clean := func() {
+		require.NoError(t, db.Delete(ctx, configKey("trash-config", "1.0")))
+		emptyTrash(t, db, "trash-config")
+	}
+	clean()
+	t.Cleanup(clean)
+
+	config := model.Config{Name: "trash-config", Version: "1.0", Params: map[string]string{"host": "a", "password": "hunter2"}, Secrets: []string{"password"}}
+	require.NoError(t, configs.Add(ctx, config))
+	require.NoError(t, configs.Delete(ctx, "trash-config", "1.0"))
+	_, err = configs.Get(ctx, "trash-config", "1.0")
+	assert.Error(t, err)
+
+	// The config is kept as it was stored, with its secret params encrypted
+	entries := trashEntries(t, repo, "trash-config")
+	require.Len(t, entries, 1)
+	assert.Equal(t, model.KindConfig, entries[0].Kind)
+	var stored model.Config
+	require.NoError(t, db.Get(ctx, trashKey(entries[0].ID)+"/"+configKey("trash-config", "1.0"), &stored))
+	assert.True(t, secrets.IsEncrypted(stored.Params["password"]))
+
+	restored, err := repo.Restore(ctx, entries[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, entries[0], restored)
+	read, err := configs.Get(ctx, "trash-config", "1.0")
+	require.NoError(t, err)
+	assert.Equal(t, config, read)
+	assert.Empty(t, trashEntries(t, repo, "trash-config"))
+	_, err = repo.Restore(ctx, entries[0].ID)
+	assert.ErrorIs(t, err, model.ErrTrashEntryNotFound)
+
+	// A config created again since it was deleted isn't overwritten
+	require.NoError(t, configs.Delete(ctx, "trash-config", "1.0"))
+	require.NoError(t, configs.Add(ctx, model.Config{Name: "trash-config", Version: "1.0", Params: map[string]string{"host": "b"}}))
+	entries = trashEntries(t, repo, "trash-config")
+	require.Len(t, entries, 1)
+	_, err = repo.Restore(ctx, entries[0].ID)
+	assert.ErrorIs(t, err, model.ErrRestoreConflict)
+	read, err = configs.Get(ctx, "trash-config", "1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "b", read.Params["host"])
+
+	require.NoError(t, repo.Purge(ctx, entries[0].ID))
+	assert.Empty(t, trashEntries(t, repo, "trash-config"))
+	keys, err := db.Keys(ctx, trashKey(entries[0].ID))
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestTrashDBRepository_Group(t *testing.T) {
+	ctx := context.Background()
+	db, err := data.NewDatabase(data.Options{})
+	require.NoError(t, err)
+	groups := NewConfigGroupDBRepository(db, nil)
+	repo := NewTrashDBRepository(db)
+	clean := func() {
+		require.NoError(t, db.Txn(ctx, []data.TxnOp{
+			{Verb: data.TxnDeleteTree, Key: groupTreePrefix("trash-group", "1.0")},
+			{Verb: data.TxnDelete, Key: groupKey("trash-group", "1.0")},
+		}))
+		emptyTrash(t, db, "trash-group")
+	}
+	clean()
+	t.Cleanup(clean)
+
+	// More configs than fit in one transaction
+	group := model.ConfigGroup{Name: "trash-group", Version: "1.0", Variables: map[string]string{"region": "eu"}}
+	for i := 0; i < 2*data.MaxTxnOps; i++ {
+		group.Configs = append(group.Configs, &model.ConfigWithLabels{
+			Config: model.Config{Name: fmt.Sprintf("config-%04d", i), Version: "1.0", Params: map[string]string{"key": "value"}},
+			Labels: []model.Label{{Key: "env", Value: "prod"}},
+		})
+	}
+	ops := []data.TxnOp{{Verb: data.TxnSet, Key: groupKey(group.Name, group.Version), Value: groupRecord{Name: group.Name, Version: group.Version, Variables: group.Variables}}}
+	for _, config := range group.Configs {
+		ops = append(ops, data.TxnOp{Verb: data.TxnSet, Key: groupConfigKey(group.Name, group.Version, *config), Value: config})
+		if len(ops) == data.MaxTxnOps {
+			require.NoError(t, db.Txn(ctx, ops))
+			ops = nil
+		}
+	}
+	require.NoError(t, db.Txn(ctx, ops))
+
+	require.NoError(t, groups.Delete(ctx, "trash-group", "1.0"))
+	_, err = groups.Get(ctx, "trash-group", "1.0")
+	assert.ErrorIs(t, err, model.ErrGroupNotFound)
+	entries := trashEntries(t, repo, "trash-group")
+	require.Len(t, entries, 1)
+	assert.Equal(t, model.KindConfigGroup, entries[0].Kind)
+
+	_, err = repo.Restore(ctx, entries[0].ID)
+	require.NoError(t, err)
+	restored, err := groups.Get(ctx, "trash-group", "1.0")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, group.Configs, restored.Configs)
+	assert.Equal(t, group.Variables, restored.Variables)
+	assert.Empty(t, trashEntries(t, repo, "trash-group"))
+
+	// A group created again since it was deleted isn't touched, and the entry stays
+	require.NoError(t, groups.Delete(ctx, "trash-group", "1.0"))
+	require.NoError(t, groups.Add(ctx, model.ConfigGroup{Name: "trash-group", Version: "1.0", Configs: group.Configs[:1]}))
+	entries = trashEntries(t, repo, "trash-group")
+	require.Len(t, entries, 1)
+	_, err = repo.Restore(ctx, entries[0].ID)
+	assert.ErrorIs(t, err, model.ErrRestoreConflict)
+	restored, err = groups.Get(ctx, "trash-group", "1.0")
+	require.NoError(t, err)
+	assert.Len(t, restored.Configs, 1)
+	assert.Len(t, trashEntries(t, repo, "trash-group"), 1)
+	clean()
+
+	// Groups stored without a record are restored from their configs
+	ops = nil
+	for _, config := range group.Configs[:3] {
+		ops = append(ops, data.TxnOp{Verb: data.TxnSet, Key: groupConfigKey(group.Name, group.Version, *config), Value: config})
+	}
+	require.NoError(t, db.Txn(ctx, ops))
+	require.NoError(t, groups.Delete(ctx, "trash-group", "1.0"))
+	entries = trashEntries(t, repo, "trash-group")
+	require.Len(t, entries, 1)
+	_, err = repo.Restore(ctx, entries[0].ID)
+	require.NoError(t, err)
+	restored, err = groups.Get(ctx, "trash-group", "1.0")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, group.Configs[:3], restored.Configs)
+	assert.Empty(t, trashEntries(t, repo, "trash-group"))
+}
+
+func TestTrashDBRepository_ChangedWhileMoved(t *testing.T) {
+	ctx := context.Background()
+	db, err := data.NewDatabase(data.Options{})
+	require.NoError(t, err)
+	groups := NewConfigGroupDBRepository(db, nil)
+	repo := NewTrashDBRepository(db)
+	clean := func() {
+		require.NoError(t, db.Txn(ctx, []data.TxnOp{
+			{Verb: data.TxnDeleteTree, Key: groupTreePrefix("moved-group", "1.0")},
+			{Verb: data.TxnDelete, Key: groupKey("moved-group", "1.0")},
+		}))
+		emptyTrash(t, db, "moved-group")
+	}
+	clean()
+	t.Cleanup(clean)
+	require.NoError(t, groups.Add(ctx, model.ConfigGroup{Name: "moved-group", Version: "1.0", Configs: []*model.ConfigWithLabels{
+		{Config: model.Config{Name: "db", Version: "1.0", Params: map[string]string{"a": "1"}}},
+	}}))
+	trashKeys := func() []string {
+		keys, err := db.Keys(ctx, trashPrefix)
+		require.NoError(t, err)
+		return keys
+	}
+	before := trashKeys()
+
+	// A move based on keys read before a config was added fails and leaves no copies behind
+	stale, err := storedPairs(ctx, db, model.KindConfigGroup, "moved-group", "1.0")
+	require.NoError(t, err)
+	require.NoError(t, groups.AddConfigWithLabelToGroup(ctx, "moved-group", "1.0", model.ConfigWithLabels{Config: model.Config{Name: "cache", Version: "1.0"}}))
+	err = movePairs(ctx, db, model.KindConfigGroup, "moved-group", "1.0", stale, []data.TxnOp{
+		{Verb: data.TxnDeleteTree, Key: groupTreePrefix("moved-group", "1.0")},
+		{Verb: data.TxnDelete, Key: groupKey("moved-group", "1.0")},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, before, trashKeys())
+	group, err := groups.Get(ctx, "moved-group", "1.0")
+	require.NoError(t, err)
+	assert.Len(t, group.Configs, 2)
+
+	// A deletion racing with a write reads the group again, and moves what it holds then
+	raced := false
+	err = moveToTrash(ctx, db, model.KindConfigGroup, "moved-group", "1.0", []data.TxnOp{
+		{Verb: data.TxnDeleteTree, Key: groupTreePrefix("moved-group", "1.0")},
+		{Verb: data.TxnDelete, Key: groupKey("moved-group", "1.0")},
+	}, func(pairs []data.Pair) error {
+		if !raced {
+			raced = true
+			return groups.AddConfigWithLabelToGroup(ctx, "moved-group", "1.0", model.ConfigWithLabels{Config: model.Config{Name: "queue", Version: "1.0"}})
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	entries := trashEntries(t, repo, "moved-group")
+	require.Len(t, entries, 1)
+	_, err = repo.Restore(ctx, entries[0].ID)
+	require.NoError(t, err)
+	group, err = groups.Get(ctx, "moved-group", "1.0")
+	require.NoError(t, err)
+	assert.Len(t, group.Configs, 3)
+}
@@ -0,0 +1,45 @@
+// Helpers for writes which may need more operations than a single Consul transaction allows.
+package repositories
+
+import (
+	"context"
+	"project/data"
+)
+
+// runBatched writes the groups of ops in order, packing whole groups into transactions of at most
+// data.MaxTxnOps operations. A group is only split when it is larger than a transaction on its own, so
+// the guard leading a group is always checked in the same transaction as its first writes. Everything
+// is written atomically when it fits in one transaction. On failure it returns the index of the first
+// group of the transaction which was rolled back, the groups before it are written.
+func runBatched(ctx context.Context, db *data.Database, groups [][]data.TxnOp) (int, error) {
+	var batch []data.TxnOp
+	first := 0
+	flush := func(next int) error {
+		if len(batch) > 0 {
+			if err := db.Txn(ctx, batch); err != nil {
+				return err
+			}
+		}
+		batch = nil
+		first = next
+		return nil
+	}
+	for i, ops := range groups {
+		if len(batch)+len(ops) > data.MaxTxnOps {
+			if err := flush(i); err != nil {
+				return first, err
+			}
+		}
+		for len(ops) > data.MaxTxnOps {
+			if err := db.Txn(ctx, ops[:data.MaxTxnOps]); err != nil {
+				return i, err
+			}
+			ops = ops[data.MaxTxnOps:]
+		}
+		batch = append(batch, ops...)
+	}
+	if err := flush(len(groups)); err != nil {
+		return first, err
+	}
+	return len(groups), nil
+}
@@ -0,0 +1,309 @@
+// Package secrets implements envelope encryption of secret param values.
+//
+// Every value is encrypted with its own random data key (DEK) using AES-256-GCM, and the data key is
+// wrapped with a key encryption key (KEK) from a Keyring loaded from a local file. The ciphertext is
+// tagged with the id of the KEK, so the keyring can hold several keys: new values are encrypted with
+// the primary key and older values stay readable until they are re-encrypted. Rotating a key means
+// adding a new key to the keyring file, making it the primary and reloading the keyring; Rewrap then
+// moves existing values to the new key without touching their ciphertext.
+//
+// Encrypted values have the format enc:v1:{keyID}:{wrappedDEK}:{ciphertext}, both parts base64 encoded.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const encryptedPrefix = "enc:v1:"
+
+type Keyring struct {
+	// path is the file the keyring was loaded from, empty for keyrings created with NewKeyring
+	path string
+
+	mu      sync.RWMutex
+	primary string
+	keys    map[string][]byte
+}
+
+// keyringFile is the layout of the keyring file, e.g.
+//
+//	{"primary": "2024-06", "keys": {"2024-01": "<base64 32 bytes>", "2024-06": "<base64 32 bytes>"}}
+type keyringFile struct {
+	Primary string            `yaml:"primary"`
+	Keys    map[string]string `yaml:"keys"`
+}
+
+// LoadKeyring reads a keyring from a JSON or YAML file.
+func LoadKeyring(path string) (*Keyring, error) {
+	keyring, err := readKeyring(path)
+	if err != nil {
+		return nil, err
+	}
+	keyring.path = path
+	return keyring, nil
+}
+
+// Reload reads the keyring file again, so keys can be added and the primary key changed without
+// restarting. On error the keyring is left unchanged.
+func (k *Keyring) Reload() error {
+	if k.path == "" {
+		return errors.New("keyring was not loaded from a file")
+	}
+	loaded, err := readKeyring(k.path)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.primary = loaded.primary
+	k.keys = loaded.keys
+	return nil
+}
+
+func readKeyring(path string) (*Keyring, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keyringFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("keyring %s: %w", path, err)
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("keyring %s: key %s: %w", path, id, err)
+		}
+		keys[id] = key
+	}
+	keyring, err := NewKeyring(file.Primary, keys)
+	if err != nil {
+		return nil, fmt.Errorf("keyring %s: %w", path, err)
+	}
+	return keyring, nil
+}
+
+// NewKeyring creates a keyring from 32 byte keys indexed by id, encrypting with the primary key.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("keyring has no keys")
+	}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %s must be 32 bytes long, got %d", id, len(key))
+		}
+	}
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the keyring", primary)
+	}
+	return &Keyring{primary: primary, keys: keys}, nil
+}
+
+// IsEncrypted reports whether the value was produced by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// KeyID returns the id of the key an encrypted value is wrapped with.
+func KeyID(value string) (string, error) {
+	keyID, _, _, err := parse(value)
+	return keyID, err
+}
+
+// Primary returns the id of the key new values are encrypted with.
+func (k *Keyring) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+// Encrypt encrypts the value with a new data key wrapped by the primary key.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dek, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+	return k.wrap(dek, ciphertext)
+}
+
+// Decrypt returns the plaintext of a value produced by Encrypt with any key of the keyring.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	dek, ciphertext, err := k.unwrap(value)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dek, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRewrap reports whether the value is encrypted with a key other than the primary key.
+func (k *Keyring) NeedsRewrap(value string) bool {
+	keyID, err := KeyID(value)
+	return err == nil && keyID != k.Primary()
+}
+
+// Rewrap returns the value with its data key wrapped by the primary key. The ciphertext itself is
+// kept, so the plaintext is never exposed.
+func (k *Keyring) Rewrap(value string) (string, error) {
+	dek, ciphertext, err := k.unwrap(value)
+	if err != nil {
+		return "", err
+	}
+	return k.wrap(dek, ciphertext)
+}
+
+// wrap encrypts the data key with the primary key and formats the encrypted value.
+func (k *Keyring) wrap(dek []byte, ciphertext []byte) (string, error) {
+	k.mu.RLock()
+	primary, kek := k.primary, k.keys[k.primary]
+	k.mu.RUnlock()
+
+	wrapped, err := seal(kek, dek, []byte(primary))
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + primary + ":" + encode(wrapped) + ":" + encode(ciphertext), nil
+}
+
+// unwrap parses an encrypted value and decrypts its data key.
+func (k *Keyring) unwrap(value string) (dek []byte, ciphertext []byte, err error) {
+	keyID, wrapped, ciphertext, err := parse(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	k.mu.RLock()
+	kek, ok := k.keys[keyID]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("secret is encrypted with unknown key %q", keyID)
+	}
+	dek, err = open(kek, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unwrapping data key with key %q: %w", keyID, err)
+	}
+	return dek, ciphertext, nil
+}
+
+func parse(value string) (keyID string, wrapped []byte, ciphertext []byte, err error) {
+	if !IsEncrypted(value) {
+		return "", nil, nil, errors.New("value is not encrypted")
+	}
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, errors.New("malformed encrypted value")
+	}
+	if wrapped, err = base64.RawStdEncoding.DecodeString(parts[1]); err != nil {
+		return "", nil, nil, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	if ciphertext, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	return parts[0], wrapped, ciphertext, nil
+}
+
+// seal encrypts plaintext with AES-256-GCM, prefixing the result with the random nonce.
+func seal(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key []byte, sealed []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encode(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+// EncryptParams returns a copy of params with the named params encrypted. Values which are already
+// encrypted are kept as they are. A nil keyring can't encrypt anything.
+func (k *Keyring) EncryptParams(params map[string]string, names []string) (map[string]string, error) {
+	result := make(map[string]string, len(params))
+	for key, value := range params {
+		result[key] = value
+	}
+	for _, name := range names {
+		value, ok := result[name]
+		if !ok || IsEncrypted(value) {
+			continue
+		}
+		if k == nil {
+			return nil, errors.New("secret params require an encryption keyring to be configured")
+		}
+		encrypted, err := k.Encrypt(value)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = encrypted
+	}
+	return result, nil
+}
+
+// DecryptParams returns a copy of params with the named encrypted params decrypted.
+func (k *Keyring) DecryptParams(params map[string]string, names []string) (map[string]string, error) {
+	result := make(map[string]string, len(params))
+	for key, value := range params {
+		result[key] = value
+	}
+	for _, name := range names {
+		value, ok := result[name]
+		if !ok || !IsEncrypted(value) {
+			continue
+		}
+		if k == nil {
+			return nil, errors.New("encrypted params found but no encryption keyring is configured")
+		}
+		decrypted, err := k.Decrypt(value)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting param %s: %w", name, err)
+		}
+		result[name] = decrypted
+	}
+	return result, nil
+}
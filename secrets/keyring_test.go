@@ -0,0 +1,74 @@
+package secrets
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyring_EncryptDecrypt(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	keyring, err := NewKeyring("old", map[string][]byte{"old": oldKey})
+	assert.NoError(t, err)
+
+	encrypted, err := keyring.Encrypt("s3cr3t")
+	assert.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "s3cr3t")
+
+	decrypted, err := keyring.Decrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t", decrypted)
+
+	// After rotation values encrypted with the old key stay readable
+	rotated, err := NewKeyring("new", map[string][]byte{"old": oldKey, "new": newKey})
+	assert.NoError(t, err)
+	decrypted, err = rotated.Decrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t", decrypted)
+
+	// A keyring without the key can't decrypt
+	other, err := NewKeyring("new", map[string][]byte{"new": newKey})
+	assert.NoError(t, err)
+	_, err = other.Decrypt(encrypted)
+	assert.Error(t, err)
+
+	_, err = NewKeyring("missing", map[string][]byte{"old": oldKey})
+	assert.Error(t, err)
+	_, err = NewKeyring("short", map[string][]byte{"short": []byte("too short")})
+	assert.Error(t, err)
+}
+
+func TestKeyring_Rewrap(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	old, err := NewKeyring("old", map[string][]byte{"old": oldKey})
+	assert.NoError(t, err)
+	encrypted, err := old.Encrypt("s3cr3t")
+	assert.NoError(t, err)
+	assert.False(t, old.NeedsRewrap(encrypted))
+
+	rotated, err := NewKeyring("new", map[string][]byte{"old": oldKey, "new": newKey})
+	assert.NoError(t, err)
+	assert.True(t, rotated.NeedsRewrap(encrypted))
+
+	rewrapped, err := rotated.Rewrap(encrypted)
+	assert.NoError(t, err)
+	assert.False(t, rotated.NeedsRewrap(rewrapped))
+	keyID, err := KeyID(rewrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", keyID)
+
+	// Once rewrapped the old key can be dropped from the keyring
+	retired, err := NewKeyring("new", map[string][]byte{"new": newKey})
+	assert.NoError(t, err)
+	decrypted, err := retired.Decrypt(rewrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t", decrypted)
+
+	assert.False(t, rotated.NeedsRewrap("plain"))
+}
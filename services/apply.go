@@ -0,0 +1,201 @@
+// The `ApplyService` struct compares a set of manifests with the configurations and configuration
+// groups in the store and applies the resulting plan. Plans deleting locked objects or removing configs
+// from locked groups are refused.
+package services
+
+import (
+	"context"
+	"fmt"
+	"project/model"
+	"project/tracing"
+	"sort"
+)
+
+type ApplyService struct {
+	configRepo model.ConfigRepository
+	groupRepo  model.ConfigGroupRepository
+	locks      model.LockRepository
+	applyRepo  model.ApplyRepository
+}
+
+func NewApplyService(configRepo model.ConfigRepository, groupRepo model.ConfigGroupRepository, locks model.LockRepository, applyRepo model.ApplyRepository) ApplyService {
+	return ApplyService{
+		configRepo: configRepo,
+		groupRepo:  groupRepo,
+		locks:      locks,
+		applyRepo:  applyRepo,
+	}
+}
+
+// Apply computes the plan which turns the store into the state described by the manifests and, unless
+// dryRun is set, applies it. With prune, objects missing from the manifests are deleted. Plans changing
+// a protected group are refused.
+func (s ApplyService) Apply(ctx context.Context, manifests []model.Manifest, dryRun bool, prune bool) (model.ApplyPlan, error) {
+	ctx, span := tracing.Start(ctx, "ApplyService.Apply")
+	defer span.End()
+
+	plan, err := s.Plan(ctx, manifests, prune)
+	if err != nil {
+		return model.ApplyPlan{}, err
+	}
+	plan.DryRun = dryRun
+
+	if dryRun || len(plan.Steps) == 0 {
+		return plan, nil
+	}
+	if err := s.checkProtected(ctx, plan); err != nil {
+		return model.ApplyPlan{}, err
+	}
+	if err := s.checkLocked(ctx, plan); err != nil {
+		return model.ApplyPlan{}, err
+	}
+	if err := s.applyRepo.Apply(ctx, plan); err != nil {
+		return model.ApplyPlan{}, err
+	}
+	plan.Applied = true
+	return plan, nil
+}
+
+// checkProtected refuses plans which update or delete a protected group, those go through change
+// requests. The plan itself may still be computed in a dry run.
+func (s ApplyService) checkProtected(ctx context.Context, plan model.ApplyPlan) error {
+	for _, step := range plan.Steps {
+		if step.Kind != model.KindConfigGroup || step.Action == model.PlanCreate {
+			continue
+		}
+		group, err := s.groupRepo.Get(ctx, step.Name, step.Version)
+		if err != nil {
+			return err
+		}
+		if group.Protected {
+			return protectedError(step.Name, step.Version)
+		}
+	}
+	return nil
+}
+
+// checkLocked refuses plans which delete a locked config or group, or remove configs from a locked group.
+// The steps doing so require their object to be unlocked when the plan is written as well.
+func (s ApplyService) checkLocked(ctx context.Context, plan model.ApplyPlan) error {
+	for _, step := range plan.Steps {
+		if !step.RequireUnlocked {
+			continue
+		}
+		if err := checkUnlocked(ctx, s.locks, step.Kind, step.Name, step.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Plan computes the creates, updates and deletes needed to reach the state described by the manifests.
+func (s ApplyService) Plan(ctx context.Context, manifests []model.Manifest, prune bool) (model.ApplyPlan, error) {
+	ctx, span := tracing.Start(ctx, "ApplyService.Plan")
+	defer span.End()
+
+	desiredConfigs := make(map[model.Ref]*model.Config)
+	desiredGroups := make(map[model.Ref]*model.ConfigGroup)
+	for _, manifest := range manifests {
+		if err := manifest.Validate(); err != nil {
+			return model.ApplyPlan{}, err
+		}
+		ref := manifest.Ref()
+		switch manifest.Kind {
+		case model.KindConfig:
+			if _, ok := desiredConfigs[ref]; ok {
+				return model.ApplyPlan{}, fmt.Errorf("%w: config %s is declared more than once", model.ErrInvalid, ref)
+			}
+			desiredConfigs[ref] = manifest.Config
+		case model.KindConfigGroup:
+			if _, ok := desiredGroups[ref]; ok {
+				return model.ApplyPlan{}, fmt.Errorf("%w: configGroup %s is declared more than once", model.ErrInvalid, ref)
+			}
+			desiredGroups[ref] = manifest.Group
+		}
+	}
+
+	// The indexes are read first, so a write between them and the lists fails the apply
+	indexes, err := s.applyRepo.Indexes(ctx)
+	if err != nil {
+		return model.ApplyPlan{}, err
+	}
+	currentConfigs, err := s.configRepo.List(ctx)
+	if err != nil {
+		return model.ApplyPlan{}, err
+	}
+	currentGroups, err := s.groupRepo.List(ctx)
+	if err != nil {
+		return model.ApplyPlan{}, err
+	}
+
+	plan := model.ApplyPlan{Prune: prune, Steps: []model.PlanStep{}}
+
+	// Configs
+	existingConfigs := make(map[model.Ref]bool, len(currentConfigs))
+	for i := range currentConfigs {
+		current := currentConfigs[i]
+		ref := model.Ref{Name: current.Name, Version: current.Version}
+		existingConfigs[ref] = true
+
+		desired, ok := desiredConfigs[ref]
+		if !ok {
+			if prune {
+				plan.Steps = append(plan.Steps, model.PlanStep{Action: model.PlanDelete, Kind: model.KindConfig, Name: ref.Name, Version: ref.Version, Index: indexes[model.ObjectID(model.KindConfig, ref.Name, ref.Version)], RequireUnlocked: true})
+			}
+			continue
+		}
+		changes := model.DiffParams(current.Params, desired.Params)
+		for i, change := range changes {
+			if current.IsSecret(change.Key) || desired.IsSecret(change.Key) {
+				changes[i] = model.MaskParamChange(change)
+			}
+		}
+		if len(changes) > 0 || current.Extends != desired.Extends || !current.SameSecrets(*desired) {
+			plan.Steps = append(plan.Steps, model.PlanStep{Action: model.PlanUpdate, Kind: model.KindConfig, Name: ref.Name, Version: ref.Version, Config: desired, Params: changes, Index: indexes[model.ObjectID(model.KindConfig, ref.Name, ref.Version)]})
+		}
+	}
+	for _, ref := range sortedRefs(desiredConfigs) {
+		if !existingConfigs[ref] {
+			plan.Steps = append(plan.Steps, model.PlanStep{Action: model.PlanCreate, Kind: model.KindConfig, Name: ref.Name, Version: ref.Version, Config: desiredConfigs[ref]})
+		}
+	}
+
+	// Config groups
+	existingGroups := make(map[model.Ref]bool, len(currentGroups))
+	for _, current := range currentGroups {
+		ref := model.Ref{Name: current.Name, Version: current.Version}
+		existingGroups[ref] = true
+
+		desired, ok := desiredGroups[ref]
+		if !ok {
+			if prune {
+				plan.Steps = append(plan.Steps, model.PlanStep{Action: model.PlanDelete, Kind: model.KindConfigGroup, Name: ref.Name, Version: ref.Version, Index: indexes[model.ObjectID(model.KindConfigGroup, ref.Name, ref.Version)], RequireUnlocked: true})
+			}
+			continue
+		}
+		if diff := model.DiffGroups(current, *desired); !diff.Empty() {
+			plan.Steps = append(plan.Steps, model.PlanStep{Action: model.PlanUpdate, Kind: model.KindConfigGroup, Name: ref.Name, Version: ref.Version, Group: desired, Diff: &diff, Index: indexes[model.ObjectID(model.KindConfigGroup, ref.Name, ref.Version)], RequireUnlocked: len(diff.Removed) > 0})
+		}
+	}
+	for _, ref := range sortedRefs(desiredGroups) {
+		if !existingGroups[ref] {
+			plan.Steps = append(plan.Steps, model.PlanStep{Action: model.PlanCreate, Kind: model.KindConfigGroup, Name: ref.Name, Version: ref.Version, Group: desiredGroups[ref]})
+		}
+	}
+
+	return plan, nil
+}
+
+func sortedRefs[V any](m map[model.Ref]V) []model.Ref {
+	refs := make([]model.Ref, 0, len(m))
+	for ref := range m {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Name != refs[j].Name {
+			return refs[i].Name < refs[j].Name
+		}
+		return refs[i].Version < refs[j].Version
+	})
+	return refs
+}
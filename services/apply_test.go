@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"project/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryApplyRepository writes plans to the in-memory configs and groups. Every stored object is at
+// index 1, updates and deletes must carry it.
+type memoryApplyRepository struct {
+	configs memoryConfigRepository
+	groups  *memoryConfigGroupRepository
+	applied int
+}
+
+func (repo *memoryApplyRepository) Indexes(_ context.Context) (map[string]uint64, error) {
+	indexes := make(map[string]uint64)
+	for ref := range repo.configs {
+		indexes[model.ObjectID(model.KindConfig, ref.Name, ref.Version)] = 1
+	}
+	for _, group := range repo.groups.groups {
+		indexes[model.ObjectID(model.KindConfigGroup, group.Name, group.Version)] = 1
+	}
+	return indexes, nil
+}
+
+func (repo *memoryApplyRepository) Apply(ctx context.Context, plan model.ApplyPlan) error {
+	for _, step := range plan.Steps {
+		if step.Action != model.PlanCreate && step.Index != 1 {
+			return model.ErrApplyConflict
+		}
+	}
+	repo.applied++
+	for _, step := range plan.Steps {
+		switch step.Kind {
+		case model.KindConfig:
+			if step.Action == model.PlanDelete {
+				repo.configs.Delete(ctx, step.Name, step.Version)
+				continue
+			}
+			repo.configs.Add(ctx, *step.Config)
+		case model.KindConfigGroup:
+			groups := repo.groups.groups[:0]
+			for _, group := range repo.groups.groups {
+				if group.Name != step.Name || group.Version != step.Version {
+					groups = append(groups, group)
+				}
+			}
+			if step.Action != model.PlanDelete {
+				groups = append(groups, *step.Group)
+			}
+			repo.groups.groups = groups
+		}
+	}
+	return nil
+}
+
+func TestApplyService_Apply(t *testing.T) {
+	ctx := context.Background()
+	configs := memoryConfigRepository{
+		{Name: "db", Version: "1.0"}:    {Name: "db", Version: "1.0", Params: map[string]string{"host": "a"}},
+		{Name: "cache", Version: "1.0"}: {Name: "cache", Version: "1.0", Params: map[string]string{"ttl": "60"}},
+		{Name: "old", Version: "1.0"}:   {Name: "old", Version: "1.0", Params: map[string]string{"x": "1"}},
+	}
+	groups := &memoryConfigGroupRepository{groups: []model.ConfigGroup{{Name: "legacy", Version: "1.0", Configs: []*model.ConfigWithLabels{
+		{Config: model.Config{Name: "svc", Version: "1.0", Params: map[string]string{"a": "1"}}},
+	}}}}
+	applyRepo := &memoryApplyRepository{configs: configs, groups: groups}
+	service := NewApplyService(configs, groups, memoryLockRepository{}, applyRepo)
+
+	manifests := []model.Manifest{
+		{Kind: model.KindConfig, Config: &model.Config{Name: "db", Version: "1.0", Params: map[string]string{"host": "b"}}},
+		{Kind: model.KindConfig, Config: &model.Config{Name: "cache", Version: "1.0", Params: map[string]string{"ttl": "60"}}},
+		{Kind: model.KindConfig, Config: &model.Config{Name: "queue", Version: "1.0", Params: map[string]string{"size": "10"}}},
+	}
+
+	// A dry run computes the plan without writing it
+	plan, err := service.Apply(ctx, manifests, true, true)
+	require.NoError(t, err)
+	assert.True(t, plan.DryRun)
+	assert.False(t, plan.Applied)
+	assert.Equal(t, 0, applyRepo.applied)
+	steps := make(map[string]model.PlanAction)
+	for _, step := range plan.Steps {
+		steps[step.Kind+" "+step.Name] = step.Action
+	}
+	// The unchanged config has no step
+	assert.Equal(t, map[string]model.PlanAction{
+		"Config db":          model.PlanUpdate,
+		"Config queue":       model.PlanCreate,
+		"Config old":         model.PlanDelete,
+		"ConfigGroup legacy": model.PlanDelete,
+	}, steps)
+	assert.Equal(t, []model.ParamChange{{Key: "host", Old: "a", New: "b"}}, planStep(plan, "db").Params)
+
+	// Without prune nothing is deleted
+	plan, err = service.Apply(ctx, manifests, false, false)
+	require.NoError(t, err)
+	assert.True(t, plan.Applied)
+	assert.Len(t, plan.Steps, 2)
+	assert.Equal(t, "b", configs[model.Ref{Name: "db", Version: "1.0"}].Params["host"])
+	assert.Contains(t, configs, model.Ref{Name: "queue", Version: "1.0"})
+	assert.Contains(t, configs, model.Ref{Name: "old", Version: "1.0"})
+
+	// Applying the same manifests again changes nothing
+	plan, err = service.Apply(ctx, manifests, false, false)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Steps)
+
+	// Prune deletes what the manifests don't declare
+	plan, err = service.Apply(ctx, manifests, false, true)
+	require.NoError(t, err)
+	assert.Len(t, plan.Steps, 2)
+	assert.NotContains(t, configs, model.Ref{Name: "old", Version: "1.0"})
+	assert.Empty(t, groups.groups)
+
+	// An object declared twice is invalid input
+	_, err = service.Apply(ctx, append(manifests, manifests[0]), false, false)
+	assert.ErrorIs(t, err, model.ErrInvalid)
+}
+
+func planStep(plan model.ApplyPlan, name string) model.PlanStep {
+	for _, step := range plan.Steps {
+		if step.Name == name {
+			return step
+		}
+	}
+	return model.PlanStep{}
+}
@@ -0,0 +1,222 @@
+// The `ChangeRequestService` struct proposes new states of config groups as change requests, which are
+// applied once enough reviewers approved them. The proposed group replaces the stored one as a whole,
+// so the diff of a pending change request is always computed against the current group. A change
+// request removing configs from a locked group isn't applied until the group is unlocked.
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"project/auth"
+	"project/model"
+	"project/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type ChangeRequestService struct {
+	repo   model.ChangeRequestRepository
+	groups model.ConfigGroupRepository
+	locks  model.LockRepository
+	// approvals is the number of approvals new change requests require
+	approvals int
+	now       func() time.Time
+}
+
+func NewChangeRequestService(repo model.ChangeRequestRepository, groups model.ConfigGroupRepository, locks model.LockRepository, approvals int) ChangeRequestService {
+	return ChangeRequestService{
+		repo:      repo,
+		groups:    groups,
+		locks:     locks,
+		approvals: approvals,
+		now:       time.Now,
+	}
+}
+
+// Create stores a pending change request proposing the group, authored by the caller.
+func (s ChangeRequestService) Create(ctx context.Context, group model.ConfigGroup, description string) (model.ChangeRequest, error) {
+	ctx, span := tracing.Start(ctx, "ChangeRequestService.Create", attribute.String("name", group.Name), attribute.String("version", group.Version))
+	defer span.End()
+
+	if err := group.Validate(); err != nil {
+		return model.ChangeRequest{}, err
+	}
+	now := s.now().UTC()
+	id, err := newChangeRequestID(now)
+	if err != nil {
+		return model.ChangeRequest{}, err
+	}
+	request := model.ChangeRequest{
+		ID:                id,
+		Group:             group,
+		Description:       description,
+		Author:            auth.FromContext(ctx).Subject,
+		Status:            model.ChangeRequestPending,
+		RequiredApprovals: s.approvals,
+		Approvals:         []model.Approval{},
+		CreatedAt:         now,
+	}
+	if err := s.repo.Add(ctx, request); err != nil {
+		return model.ChangeRequest{}, err
+	}
+	return s.withDiff(ctx, request)
+}
+
+func (s ChangeRequestService) Get(ctx context.Context, id string) (model.ChangeRequest, error) {
+	ctx, span := tracing.Start(ctx, "ChangeRequestService.Get", attribute.String("id", id))
+	defer span.End()
+
+	request, _, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return model.ChangeRequest{}, err
+	}
+	return s.withDiff(ctx, request)
+}
+
+// List returns the change requests, oldest first, only those with the status unless it is empty.
+func (s ChangeRequestService) List(ctx context.Context, status model.ChangeRequestStatus) ([]model.ChangeRequest, error) {
+	ctx, span := tracing.Start(ctx, "ChangeRequestService.List", attribute.String("status", string(status)))
+	defer span.End()
+
+	requests, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.ChangeRequest, 0, len(requests))
+	for _, request := range requests {
+		if status != "" && request.Status != status {
+			continue
+		}
+		request, err := s.withDiff(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, request)
+	}
+	return result, nil
+}
+
+// Approve records the approval of the caller, who must hold the changes:review permission and not be
+// the author. The approval completing the required ones applies the change request.
+func (s ChangeRequestService) Approve(ctx context.Context, id string) (model.ChangeRequest, error) {
+	ctx, span := tracing.Start(ctx, "ChangeRequestService.Approve", attribute.String("id", id))
+	defer span.End()
+
+	reviewer := auth.FromContext(ctx)
+	return s.update(ctx, id, reviewer, func(request *model.ChangeRequest) (bool, error) {
+		if request.ApprovedBy(reviewer.Subject) {
+			return false, nil
+		}
+		now := s.now().UTC()
+		request.Approvals = append(request.Approvals, model.Approval{Reviewer: reviewer.Subject, At: now})
+		if len(request.Approvals) < request.RequiredApprovals {
+			return false, nil
+		}
+		diff, err := s.diff(ctx, request.Group)
+		if err != nil {
+			return false, err
+		}
+		if len(diff.Removed) > 0 {
+			if err := checkUnlocked(ctx, s.locks, model.KindConfigGroup, request.Group.Name, request.Group.Version); err != nil {
+				return false, err
+			}
+		}
+		request.Status = model.ChangeRequestApplied
+		request.ClosedAt = &now
+		request.Diff = &diff
+		return true, nil
+	})
+}
+
+// Reject closes the change request without applying it. The caller must be allowed to approve it.
+func (s ChangeRequestService) Reject(ctx context.Context, id string, reason string) (model.ChangeRequest, error) {
+	ctx, span := tracing.Start(ctx, "ChangeRequestService.Reject", attribute.String("id", id))
+	defer span.End()
+
+	reviewer := auth.FromContext(ctx)
+	return s.update(ctx, id, reviewer, func(request *model.ChangeRequest) (bool, error) {
+		now := s.now().UTC()
+		request.Status = model.ChangeRequestRejected
+		request.RejectedBy = reviewer.Subject
+		request.Reason = reason
+		request.ClosedAt = &now
+		return false, nil
+	})
+}
+
+// update applies the review of a pending change request and saves it, or applies it if review reports
+// so. It is retried if the change request was modified concurrently, e.g. by another approval.
+func (s ChangeRequestService) update(ctx context.Context, id string, reviewer auth.Identity, review func(request *model.ChangeRequest) (bool, error)) (model.ChangeRequest, error) {
+	if !reviewer.Can(auth.PermissionReviewChanges) {
+		return model.ChangeRequest{}, fmt.Errorf("%w: reviewing requires the %s permission", model.ErrNotReviewer, auth.PermissionReviewChanges)
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		request, index, err := s.repo.Get(ctx, id)
+		if err != nil {
+			return model.ChangeRequest{}, err
+		}
+		if request.Status != model.ChangeRequestPending {
+			return model.ChangeRequest{}, fmt.Errorf("%w: change request %s was %s", model.ErrChangeRequestClosed, id, request.Status)
+		}
+		if request.Author == reviewer.Subject {
+			return model.ChangeRequest{}, fmt.Errorf("%w: %s is the author of change request %s", model.ErrNotReviewer, reviewer.Subject, id)
+		}
+
+		apply, err := review(&request)
+		if err != nil {
+			return model.ChangeRequest{}, err
+		}
+		var saved bool
+		if apply {
+			saved, err = s.repo.Apply(ctx, request, index)
+		} else {
+			saved, err = s.repo.Save(ctx, request, index)
+		}
+		if err != nil {
+			return model.ChangeRequest{}, err
+		}
+		if saved {
+			return s.withDiff(ctx, request)
+		}
+	}
+	return model.ChangeRequest{}, errors.New("change request " + id + " was modified concurrently, try again")
+}
+
+// withDiff sets the diff of a pending change request against the current group. Closed change
+// requests keep the diff they were applied with.
+func (s ChangeRequestService) withDiff(ctx context.Context, request model.ChangeRequest) (model.ChangeRequest, error) {
+	if request.Status != model.ChangeRequestPending {
+		return request, nil
+	}
+	diff, err := s.diff(ctx, request.Group)
+	if err != nil {
+		return model.ChangeRequest{}, err
+	}
+	request.Diff = &diff
+	return request, nil
+}
+
+// diff compares the stored group with the proposed one, a group which doesn't exist yet as empty.
+func (s ChangeRequestService) diff(ctx context.Context, proposed model.ConfigGroup) (model.GroupDiff, error) {
+	current, err := s.groups.Get(ctx, proposed.Name, proposed.Version)
+	if errors.Is(err, model.ErrGroupNotFound) {
+		current, err = model.ConfigGroup{Name: proposed.Name, Version: proposed.Version}, nil
+	}
+	if err != nil {
+		return model.GroupDiff{}, err
+	}
+	return model.DiffGroups(current, proposed), nil
+}
+
+// newChangeRequestID returns an ID starting with the creation time, so IDs sort by age.
+func newChangeRequestID(now time.Time) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return now.Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix), nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"project/auth"
+	"project/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (repo *memoryConfigGroupRepository) Get(_ context.Context, name string, version string) (model.ConfigGroup, error) {
+	for _, group := range repo.groups {
+		if group.Name == name && group.Version == version {
+			return group, nil
+		}
+	}
+	return model.ConfigGroup{}, model.ErrGroupNotFound
+}
+
+// memoryChangeRequestRepository versions every change request like the modify index of a Consul key,
+// and applies them to the groups.
+type memoryChangeRequestRepository struct {
+	requests map[string]model.ChangeRequest
+	indexes  map[string]uint64
+	groups   *memoryConfigGroupRepository
+}
+
+func (repo *memoryChangeRequestRepository) Add(_ context.Context, request model.ChangeRequest) error {
+	repo.requests[request.ID] = request
+	repo.indexes[request.ID] = 1
+	return nil
+}
+
+func (repo *memoryChangeRequestRepository) Get(_ context.Context, id string) (model.ChangeRequest, uint64, error) {
+	request, ok := repo.requests[id]
+	if !ok {
+		return model.ChangeRequest{}, 0, model.ErrChangeRequestNotFound
+	}
+	request.Approvals = append([]model.Approval(nil), request.Approvals...)
+	return request, repo.indexes[id], nil
+}
+
+func (repo *memoryChangeRequestRepository) List(_ context.Context) ([]model.ChangeRequest, error) {
+	requests := make([]model.ChangeRequest, 0, len(repo.requests))
+	for _, request := range repo.requests {
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+func (repo *memoryChangeRequestRepository) Save(_ context.Context, request model.ChangeRequest, index uint64) (bool, error) {
+	if repo.indexes[request.ID] != index {
+		return false, nil
+	}
+	repo.requests[request.ID] = request
+	repo.indexes[request.ID]++
+	return true, nil
+}
+
+func (repo *memoryChangeRequestRepository) Apply(ctx context.Context, request model.ChangeRequest, index uint64) (bool, error) {
+	saved, err := repo.Save(ctx, request, index)
+	if !saved || err != nil {
+		return saved, err
+	}
+	for i, group := range repo.groups.groups {
+		if group.Name == request.Group.Name && group.Version == request.Group.Version {
+			repo.groups.groups[i] = request.Group
+			return true, nil
+		}
+	}
+	repo.groups.groups = append(repo.groups.groups, request.Group)
+	return true, nil
+}
+
+func TestChangeRequestService(t *testing.T) {
+	groups := &memoryConfigGroupRepository{groups: []model.ConfigGroup{{Name: "payments", Version: "2.0", Protected: true, Configs: []*model.ConfigWithLabels{
+		{Config: model.Config{Name: "db", Version: "1.0", Params: map[string]string{"host": "a"}}},
+	}}}}
+	repo := &memoryChangeRequestRepository{requests: map[string]model.ChangeRequest{}, indexes: map[string]uint64{}, groups: groups}
+	service := NewChangeRequestService(repo, groups, nil, 2)
+	service.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	as := func(subject string, permissions ...string) context.Context {
+		return auth.WithIdentity(context.Background(), auth.Identity{Subject: subject, Permissions: permissions})
+	}
+
+	// Direct writes to the protected group are refused
+	groupService := NewConfigGroupService(groups, nil, NewConfigService(memoryConfigRepository{}, nil))
+	err := groupService.RemoveConfigFromGroup(context.Background(), "payments", "2.0", "db", "1.0")
+	assert.ErrorIs(t, err, model.ErrProtected)
+
+	proposed := model.ConfigGroup{Name: "payments", Version: "2.0", Protected: true, Configs: []*model.ConfigWithLabels{
+		{Config: model.Config{Name: "db", Version: "1.0", Params: map[string]string{"host": "b"}}},
+	}}
+	request, err := service.Create(as("alice"), proposed, "move the database")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", request.Author)
+	assert.Equal(t, model.ChangeRequestPending, request.Status)
+	assert.Equal(t, []model.ParamChange{{Key: "host", Old: "a", New: "b"}}, request.Diff.Changed[0].Params)
+
+	// Only reviewers other than the author approve
+	_, err = service.Approve(as("bob"), request.ID)
+	assert.ErrorIs(t, err, model.ErrNotReviewer)
+	_, err = service.Approve(as("alice", auth.PermissionReviewChanges), request.ID)
+	assert.ErrorIs(t, err, model.ErrNotReviewer)
+
+	// The first approval leaves the group as it is, an approval is counted once per reviewer
+	request, err = service.Approve(as("bob", auth.PermissionReviewChanges), request.ID)
+	require.NoError(t, err)
+	request, err = service.Approve(as("bob", auth.PermissionReviewChanges), request.ID)
+	require.NoError(t, err)
+	assert.Len(t, request.Approvals, 1)
+	assert.Equal(t, model.ChangeRequestPending, request.Status)
+	assert.Equal(t, "a", groups.groups[0].Configs[0].Params["host"])
+
+	// The second one applies it
+	request, err = service.Approve(as("carol", auth.PermissionReviewChanges), request.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ChangeRequestApplied, request.Status)
+	assert.NotNil(t, request.ClosedAt)
+	assert.Equal(t, "b", groups.groups[0].Configs[0].Params["host"])
+	_, err = service.Reject(as("dave", auth.PermissionReviewChanges), request.ID, "too late")
+	assert.ErrorIs(t, err, model.ErrChangeRequestClosed)
+
+	// Applied change requests keep the diff they were applied with
+	request, err = service.Get(context.Background(), request.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "b", request.Diff.Changed[0].Params[0].New)
+
+	// A rejected change request is never applied
+	proposed.Configs = nil
+	request, err = service.Create(as("alice"), proposed, "")
+	require.NoError(t, err)
+	request, err = service.Reject(as("bob", auth.PermissionReviewChanges), request.ID, "keeps the database")
+	require.NoError(t, err)
+	assert.Equal(t, model.ChangeRequestRejected, request.Status)
+	assert.Equal(t, "bob", request.RejectedBy)
+	assert.Len(t, groups.groups[0].Configs, 1)
+
+	pending, err := service.List(context.Background(), model.ChangeRequestPending)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
@@ -1,33 +1,186 @@
 // The code defines a ConfigService struct with methods to add, get, and delete configuration data
-// using a ConfigRepository.
+// using a ConfigRepository. Configs which extend a parent are returned with the inherited params merged
+// and with ${...} references inside the params resolved. A config outside of its activeFrom and
+// activeUntil window is not in effect, so it can't be read, inherited from or referenced. Locked configs
+// can't be deleted.
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"project/model"
+	"project/tracing"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// ErrInheritanceCycle is returned when a config extends itself through its parents.
+var ErrInheritanceCycle = errors.New("inheritance cycle detected")
+
 type ConfigService struct {
-	repo model.ConfigRepository
+	repo  model.ConfigRepository
+	locks model.LockRepository
+	// now is the time the activity windows of configs are checked against
+	now func() time.Time
 }
 
-func NewConfigService(repo model.ConfigRepository) ConfigService {
+func NewConfigService(repo model.ConfigRepository, locks model.LockRepository) ConfigService {
 	return ConfigService{
-		repo: repo,
+		repo:  repo,
+		locks: locks,
+		now:   time.Now,
+	}
+}
+
+func (s ConfigService) Add(ctx context.Context, config model.Config) error {
+	ctx, span := tracing.Start(ctx, "ConfigService.Add")
+	defer span.End()
+
+	// The parent must exist and must not extend the new config. It may be staged for later, like the
+	// new config
+	if config.Extends != "" {
+		parent, err := model.ParseRef(config.Extends)
+		if err != nil {
+			return err
+		}
+		self := model.Ref{Name: config.Name, Version: config.Version}
+		if _, err := s.resolve(ctx, parent, []model.Ref{self}, time.Time{}); err != nil {
+			return err
+		}
 	}
+	return s.repo.Add(ctx, config)
 }
 
-func (s ConfigService) Add(config model.Config) error {
-	return s.repo.Add(config)
+// Get returns the effective config, with the params of its parents merged in and references resolved.
+func (s ConfigService) Get(ctx context.Context, name string, version string) (model.Config, error) {
+	ctx, span := tracing.Start(ctx, "ConfigService.Get", attribute.String("name", name), attribute.String("version", version))
+	defer span.End()
+
+	resolved, err := s.Resolve(ctx, name, version, false)
+	if err != nil {
+		return model.Config{}, err
+	}
+	return resolved.Config, nil
 }
 
-func (s ConfigService) Get(name string, version string) (model.Config, error) {
-	return s.repo.Get(name, version)
+// GetRaw returns the config as it is stored, without the params of its parents, also outside of its
+// activity window.
+func (s ConfigService) GetRaw(ctx context.Context, name string, version string) (model.Config, error) {
+	ctx, span := tracing.Start(ctx, "ConfigService.GetRaw", attribute.String("name", name), attribute.String("version", version))
+	defer span.End()
+
+	return s.repo.Get(ctx, name, version)
 }
 
-func (s ConfigService) Delete(name string, version string) error {
-	err := s.repo.Delete(name, version)
+// Resolve returns the effective config together with the layer each param came from. In strict mode
+// a reference which can't be resolved is an error, otherwise it is left as it is.
+func (s ConfigService) Resolve(ctx context.Context, name string, version string, strict bool) (model.ResolvedConfig, error) {
+	ctx, span := tracing.Start(ctx, "ConfigService.Resolve", attribute.String("name", name), attribute.String("version", version))
+	defer span.End()
+
+	ref := model.Ref{Name: name, Version: version}
+	resolved, err := s.resolve(ctx, ref, nil, s.now())
+	if err != nil {
+		return model.ResolvedConfig{}, err
+	}
+
+	in := s.interpolator(ctx, strict)
+	params, tainted, err := in.interpolate(scope{id: ref.String(), params: resolved.Params, secrets: resolved.Secrets})
+	if err != nil {
+		return model.ResolvedConfig{}, err
+	}
+	resolved.Params = params
+	resolved.Secrets = append(resolved.Secrets, tainted...)
+	return resolved, nil
+}
+
+func (s ConfigService) Delete(ctx context.Context, name string, version string) error {
+	ctx, span := tracing.Start(ctx, "ConfigService.Delete", attribute.String("name", name), attribute.String("version", version))
+	defer span.End()
+
+	if err := checkUnlocked(ctx, s.locks, model.KindConfig, name, version); err != nil {
+		return err
+	}
+	err := s.repo.Delete(ctx, name, version)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// interpolator returns an interpolator which looks up referenced configs through this service.
+func (s ConfigService) interpolator(ctx context.Context, strict bool) *interpolator {
+	return &interpolator{
+		lookupConfig: func(ref model.Ref) (model.Config, error) {
+			resolved, err := s.resolve(ctx, ref, nil, s.now())
+			return resolved.Config, err
+		},
+		strict: strict,
+	}
+}
+
+// resolve merges the config with its parents, where chain holds the configs already visited. Unless
+// at is zero every layer must be active at that time.
+func (s ConfigService) resolve(ctx context.Context, ref model.Ref, chain []model.Ref, at time.Time) (model.ResolvedConfig, error) {
+	for _, visited := range chain {
+		if visited == ref {
+			path := make([]string, 0, len(chain)+1)
+			for _, link := range chain {
+				path = append(path, link.String())
+			}
+			path = append(path, ref.String())
+			return model.ResolvedConfig{}, fmt.Errorf("%w: %s", ErrInheritanceCycle, strings.Join(path, " -> "))
+		}
+	}
+
+	config, err := s.repo.Get(ctx, ref.Name, ref.Version)
+	if err != nil {
+		return model.ResolvedConfig{}, err
+	}
+	if !at.IsZero() {
+		if err := config.CheckActive(at); err != nil {
+			return model.ResolvedConfig{}, err
+		}
+	}
+
+	resolved := model.ResolvedConfig{
+		Config:     config,
+		Provenance: make(map[string]string),
+	}
+	resolved.Params = make(map[string]string)
+
+	if config.Extends != "" {
+		parentRef, err := model.ParseRef(config.Extends)
+		if err != nil {
+			return model.ResolvedConfig{}, err
+		}
+		parent, err := s.resolve(ctx, parentRef, append(chain, ref), at)
+		if err != nil {
+			return model.ResolvedConfig{}, fmt.Errorf("resolving parent of %s: %w", ref, err)
+		}
+		for key, value := range parent.Params {
+			resolved.Params[key] = value
+			resolved.Provenance[key] = parent.Provenance[key]
+		}
+		resolved.Layers = parent.Layers
+
+		// Params which are secret in a parent stay secret
+		resolved.Secrets = append([]string{}, config.Secrets...)
+		for _, secret := range parent.Secrets {
+			if !config.IsSecret(secret) {
+				resolved.Secrets = append(resolved.Secrets, secret)
+			}
+		}
+	}
+
+	// Params of the config itself override the inherited ones
+	for key, value := range config.Params {
+		resolved.Params[key] = value
+		resolved.Provenance[key] = ref.String()
+	}
+	resolved.Layers = append(resolved.Layers, ref.String())
+	return resolved, nil
+}
@@ -1,51 +1,208 @@
 // The `ConfigGroupService` struct provides methods for interacting with configuration groups in a
-// project.
+// project. The resolved view of a group and searches leave out the configs outside of their activity
+// window, the stored group keeps them. Protected groups refuse the writes of the service, they are
+// changed through a ChangeRequestService. Locked groups can't be deleted and don't lose configs.
 package services
 
-import "project/model"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"project/model"
+	"project/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
 
 type ConfigGroupService struct {
-	repo model.ConfigGroupRepository
+	repo    model.ConfigGroupRepository
+	locks   model.LockRepository
+	configs ConfigService
+	// now is the time the activity windows of the group configs are checked against
+	now func() time.Time
 }
 
-func NewConfigGroupService(repo model.ConfigGroupRepository) ConfigGroupService {
+func NewConfigGroupService(repo model.ConfigGroupRepository, locks model.LockRepository, configs ConfigService) ConfigGroupService {
 	return ConfigGroupService{
-		repo: repo,
+		repo:    repo,
+		locks:   locks,
+		configs: configs,
+		now:     time.Now,
 	}
 }
 
-func (s ConfigGroupService) Add(group model.ConfigGroup) error {
-	return s.repo.Add(group)
+func (s ConfigGroupService) Add(ctx context.Context, group model.ConfigGroup) error {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.Add")
+	defer span.End()
+
+	return s.repo.Add(ctx, group)
 }
 
-func (s ConfigGroupService) Get(name string, version string) (model.ConfigGroup, error) {
-	return s.repo.Get(name, version)
+// Get returns the group as it is stored, without resolving references in the params of its configs.
+func (s ConfigGroupService) Get(ctx context.Context, name string, version string) (model.ConfigGroup, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.Get", attribute.String("name", name), attribute.String("version", version))
+	defer span.End()
+
+	return s.repo.Get(ctx, name, version)
 }
 
-func (s ConfigGroupService) Delete(name string, version string) error {
-	err := s.repo.Delete(name, version)
+// Resolve returns the group with its active configs, with the ${...} references in their params
+// resolved, group variables included. In strict mode a reference which can't be resolved is an error.
+func (s ConfigGroupService) Resolve(ctx context.Context, name string, version string, strict bool) (model.ConfigGroup, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.Resolve", attribute.String("name", name), attribute.String("version", version))
+	defer span.End()
+
+	group, err := s.repo.Get(ctx, name, version)
+	if err != nil {
+		return model.ConfigGroup{}, err
+	}
+	group.Configs, err = s.interpolateConfigs(ctx, group, activeConfigs(group.Configs, s.now()), strict)
+	if err != nil {
+		return model.ConfigGroup{}, err
+	}
+	return group, nil
+}
+
+func (s ConfigGroupService) Delete(ctx context.Context, name string, version string) error {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.Delete", attribute.String("name", name), attribute.String("version", version))
+	defer span.End()
+
+	if err := s.writable(ctx, name, version); err != nil {
+		return err
+	}
+	if err := checkUnlocked(ctx, s.locks, model.KindConfigGroup, name, version); err != nil {
+		return err
+	}
+	err := s.repo.Delete(ctx, name, version)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s ConfigGroupService) AddConfigToGroup(groupName string, version string, configName string, configVersion string) error {
-	return s.repo.AddConfigToGroup(groupName, version, configName, configVersion)
+func (s ConfigGroupService) AddConfigToGroup(ctx context.Context, groupName string, version string, configName string, configVersion string) error {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.AddConfigToGroup", attribute.String("name", groupName), attribute.String("version", version))
+	defer span.End()
+
+	if err := s.writable(ctx, groupName, version); err != nil {
+		return err
+	}
+	return s.repo.AddConfigToGroup(ctx, groupName, version, configName, configVersion)
+}
+
+func (s ConfigGroupService) RemoveConfigFromGroup(ctx context.Context, groupName string, version string, configName string, configVersion string) error {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.RemoveConfigFromGroup", attribute.String("name", groupName), attribute.String("version", version))
+	defer span.End()
+
+	if err := s.writable(ctx, groupName, version); err != nil {
+		return err
+	}
+	if err := checkUnlocked(ctx, s.locks, model.KindConfigGroup, groupName, version); err != nil {
+		return err
+	}
+	return s.repo.RemoveConfigFromGroup(ctx, groupName, version, configName, configVersion)
+}
+
+func (s ConfigGroupService) AddConfigWithLabelToGroup(ctx context.Context, groupName string, version string, config model.ConfigWithLabels) error {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.AddConfigWithLabelToGroup", attribute.String("name", groupName), attribute.String("version", version))
+	defer span.End()
+
+	if err := s.writable(ctx, groupName, version); err != nil {
+		return err
+	}
+	return s.repo.AddConfigWithLabelToGroup(ctx, groupName, version, config)
 }
 
-func (s ConfigGroupService) RemoveConfigFromGroup(groupName string, version string, configName string, configVersion string) error {
-	return s.repo.RemoveConfigFromGroup(groupName, version, configName, configVersion)
+// SearchConfigsWithLabelsInGroup returns the matching active configs with the references in their
+// params resolved, none if every match is outside of its activity window.
+func (s ConfigGroupService) SearchConfigsWithLabelsInGroup(ctx context.Context, groupName string, version string, labels []model.Label, configName string, configVersion string, strict bool) ([]*model.ConfigWithLabels, error) {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.SearchConfigsWithLabelsInGroup", attribute.String("name", groupName), attribute.String("version", version))
+	defer span.End()
+
+	configs, err := s.repo.SearchConfigsWithLabelsInGroup(ctx, groupName, version, labels, configName, configVersion)
+	if err != nil {
+		return nil, err
+	}
+	configs = activeConfigs(configs, s.now())
+	if len(configs) == 0 {
+		// The handler answers 404 when nothing matched
+		return configs, nil
+	}
+	group, err := s.repo.Get(ctx, groupName, version)
+	if err != nil {
+		return nil, err
+	}
+	return s.interpolateConfigs(ctx, group, configs, strict)
 }
 
-func (s ConfigGroupService) AddConfigWithLabelToGroup(groupName string, version string, config model.ConfigWithLabels) error {
-	return s.repo.AddConfigWithLabelToGroup(groupName, version, config)
+func (s ConfigGroupService) RemoveConfigsWithLabelsFromGroup(ctx context.Context, groupName string, version string, labels []model.Label, configName string, configVersion string) error {
+	ctx, span := tracing.Start(ctx, "ConfigGroupService.RemoveConfigsWithLabelsFromGroup", attribute.String("name", groupName), attribute.String("version", version))
+	defer span.End()
+
+	if err := s.writable(ctx, groupName, version); err != nil {
+		return err
+	}
+	if err := checkUnlocked(ctx, s.locks, model.KindConfigGroup, groupName, version); err != nil {
+		return err
+	}
+	return s.repo.RemoveConfigsWithLabelsFromGroup(ctx, groupName, version, labels, configName, configVersion)
 }
 
-func (s ConfigGroupService) SearchConfigsWithLabelsInGroup(groupName string, version string, labels []model.Label, configName string, configVersion string) ([]*model.ConfigWithLabels, error) {
-	return s.repo.SearchConfigsWithLabelsInGroup(groupName, version, labels, configName, configVersion)
+// writable returns an error wrapping model.ErrProtected if the group is protected. A group which
+// doesn't exist is left to the write to report. The repository checks the protection again with the
+// write, this check only gives the error before anything else is read.
+func (s ConfigGroupService) writable(ctx context.Context, name string, version string) error {
+	group, err := s.repo.Get(ctx, name, version)
+	if errors.Is(err, model.ErrGroupNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if group.Protected {
+		return protectedError(name, version)
+	}
+	return nil
 }
 
-func (s ConfigGroupService) RemoveConfigsWithLabelsFromGroup(groupName string, version string, labels []model.Label, configName string, configVersion string) error {
-	return s.repo.RemoveConfigsWithLabelsFromGroup(groupName, version, labels, configName, configVersion)
+func protectedError(name string, version string) error {
+	return fmt.Errorf("%w: configGroup %s can only be changed through a change request", model.ErrProtected, model.Ref{Name: name, Version: version})
+}
+
+// activeConfigs returns the configs which are active at t.
+func activeConfigs(configs []*model.ConfigWithLabels, t time.Time) []*model.ConfigWithLabels {
+	active := make([]*model.ConfigWithLabels, 0, len(configs))
+	for _, config := range configs {
+		if config.ActiveAt(t) {
+			active = append(active, config)
+		}
+	}
+	return active
+}
+
+// interpolateConfigs returns copies of the configs with the references in their params resolved
+// against the variables of the group.
+func (s ConfigGroupService) interpolateConfigs(ctx context.Context, group model.ConfigGroup, configs []*model.ConfigWithLabels, strict bool) ([]*model.ConfigWithLabels, error) {
+	groupID := model.Ref{Name: group.Name, Version: group.Version}.String()
+	in := s.configs.interpolator(ctx, strict)
+
+	resolved := make([]*model.ConfigWithLabels, 0, len(configs))
+	for _, config := range configs {
+		params, tainted, err := in.interpolate(scope{
+			id:      groupID + "/" + model.Ref{Name: config.Name, Version: config.Version}.String(),
+			params:  config.Params,
+			secrets: config.Secrets,
+			groupID: groupID,
+			group:   group.Variables,
+		})
+		if err != nil {
+			return nil, err
+		}
+		copied := *config
+		copied.Params = params
+		copied.Secrets = append(append([]string{}, config.Secrets...), tainted...)
+		resolved = append(resolved, &copied)
+	}
+	return resolved, nil
 }
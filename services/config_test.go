@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"project/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryConfigRepository map[model.Ref]model.Config
+
+func (repo memoryConfigRepository) Add(_ context.Context, config model.Config) error {
+	repo[model.Ref{Name: config.Name, Version: config.Version}] = config
+	return nil
+}
+
+func (repo memoryConfigRepository) Get(_ context.Context, name string, version string) (model.Config, error) {
+	config, ok := repo[model.Ref{Name: name, Version: version}]
+	if !ok {
+		return model.Config{}, fmt.Errorf("%w with name %s and version %s", model.ErrConfigNotFound, name, version)
+	}
+	return config, nil
+}
+
+func (repo memoryConfigRepository) Delete(_ context.Context, name string, version string) error {
+	delete(repo, model.Ref{Name: name, Version: version})
+	return nil
+}
+
+func (repo memoryConfigRepository) List(_ context.Context) ([]model.Config, error) {
+	configs := make([]model.Config, 0, len(repo))
+	for _, config := range repo {
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+func TestConfigService_Resolve(t *testing.T) {
+	repo := memoryConfigRepository{}
+	service := NewConfigService(repo, nil)
+
+	assert.NoError(t, service.Add(context.Background(), model.Config{Name: "base", Version: "1.0", Params: map[string]string{"host": "localhost", "port": "5432"}}))
+	assert.NoError(t, service.Add(context.Background(), model.Config{Name: "staging", Version: "1.0", Extends: "base@1.0", Params: map[string]string{"host": "db.staging"}}))
+	assert.NoError(t, service.Add(context.Background(), model.Config{Name: "prod", Version: "1.0", Extends: "staging@1.0", Params: map[string]string{"pool": "50"}}))
+
+	resolved, err := service.Resolve(context.Background(), "prod", "1.0", false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"host": "db.staging", "port": "5432", "pool": "50"}, resolved.Params)
+	assert.Equal(t, map[string]string{"host": "staging@1.0", "port": "base@1.0", "pool": "prod@1.0"}, resolved.Provenance)
+	assert.Equal(t, []string{"base@1.0", "staging@1.0", "prod@1.0"}, resolved.Layers)
+
+	raw, err := service.GetRaw(context.Background(), "prod", "1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"pool": "50"}, raw.Params)
+
+	// A parent which doesn't exist is rejected
+	assert.Error(t, service.Add(context.Background(), model.Config{Name: "orphan", Version: "1.0", Extends: "missing@1.0"}))
+
+	// A cycle written directly to the store is detected on read
+	repo.Add(context.Background(), model.Config{Name: "base", Version: "1.0", Extends: "prod@1.0"})
+	_, err = service.Resolve(context.Background(), "prod", "1.0", false)
+	assert.True(t, errors.Is(err, ErrInheritanceCycle))
+}
@@ -0,0 +1,92 @@
+// The `FsckService` checks the keyspace of the store for the inconsistencies interrupted writes and
+// older versions leave behind, and repairs the ones which have a safe repair.
+package services
+
+import (
+	"context"
+	"fmt"
+	"project/model"
+	"project/tracing"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type FsckService struct {
+	repo model.StoreCheckRepository
+}
+
+func NewFsckService(repo model.StoreCheckRepository) FsckService {
+	return FsckService{
+		repo: repo,
+	}
+}
+
+// Check scans the store and reports its issues, including references to configs which don't exist.
+// With repair the repairs of every issue which has one are applied; dangling references, undecodable
+// values and conflicting duplicates are left to an operator.
+func (s FsckService) Check(ctx context.Context, repair bool) (model.FsckReport, error) {
+	ctx, span := tracing.Start(ctx, "FsckService.Check", attribute.Bool("repair", repair))
+	defer span.End()
+
+	report := model.FsckReport{Repair: repair, StartedAt: time.Now().UTC()}
+	scan, err := s.repo.Scan(ctx)
+	if err != nil {
+		return model.FsckReport{}, err
+	}
+	report.Scanned = scan.Scanned
+	report.Issues = append([]model.Issue{}, scan.Issues...)
+	report.Issues = append(report.Issues, danglingReferences(scan.Configs, scan.Configs)...)
+	report.Issues = append(report.Issues, danglingReferences(scan.GroupConfigs, scan.Configs)...)
+	sort.SliceStable(report.Issues, func(i, j int) bool { return report.Issues[i].Key < report.Issues[j].Key })
+
+	if repair {
+		var changes []model.KeyChange
+		for _, issue := range report.Issues {
+			changes = append(changes, issue.Repair...)
+		}
+		if err := s.repo.Apply(ctx, changes); err != nil {
+			return model.FsckReport{}, fmt.Errorf("repairing the store: %w", err)
+		}
+		for i := range report.Issues {
+			if len(report.Issues[i].Repair) > 0 {
+				report.Issues[i].Repaired = true
+				report.Repaired++
+			}
+		}
+	}
+	report.FinishedAt = time.Now().UTC()
+	return report, nil
+}
+
+// danglingReferences reports the parents and ${config:...} references of the configs which point at a
+// config missing from standalone. References inside encrypted params can't be checked.
+func danglingReferences(configs map[string]model.Config, standalone map[string]model.Config) []model.Issue {
+	existing := make(map[model.Ref]bool, len(standalone))
+	for _, config := range standalone {
+		existing[model.Ref{Name: config.Name, Version: config.Version}] = true
+	}
+
+	var issues []model.Issue
+	for key, config := range configs {
+		if config.Extends != "" {
+			if parent, err := model.ParseRef(config.Extends); err != nil || !existing[parent] {
+				issues = append(issues, model.Issue{Kind: model.IssueDanglingReference, Key: key, Detail: "extends " + config.Extends + ", which doesn't exist"})
+			}
+		}
+		params := make([]string, 0, len(config.Params))
+		for param := range config.Params {
+			params = append(params, param)
+		}
+		sort.Strings(params)
+		for _, param := range params {
+			for _, ref := range configReferences(config.Params[param]) {
+				if !existing[ref] {
+					issues = append(issues, model.Issue{Kind: model.IssueDanglingReference, Key: key, Detail: fmt.Sprintf("param %s references %s, which doesn't exist", param, ref)})
+				}
+			}
+		}
+	}
+	return issues
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"project/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubStoreCheckRepository struct {
+	scan    model.StoreScan
+	applied []model.KeyChange
+}
+
+func (repo *stubStoreCheckRepository) Scan(_ context.Context) (model.StoreScan, error) {
+	return repo.scan, nil
+}
+
+func (repo *stubStoreCheckRepository) Apply(_ context.Context, changes []model.KeyChange) error {
+	repo.applied = append(repo.applied, changes...)
+	return nil
+}
+
+func TestFsckService_Check(t *testing.T) {
+	repair := model.KeyChange{Action: model.KeyDelete, Key: "configs/db/1.0-copy"}
+	repo := &stubStoreCheckRepository{scan: model.StoreScan{
+		Scanned: 4,
+		Issues: []model.Issue{
+			{Kind: model.IssueDuplicate, Key: "configs/db/1.0-copy", Repair: []model.KeyChange{repair}},
+			{Kind: model.IssueUndecodable, Key: "configs/broken/1.0"},
+		},
+		Configs: map[string]model.Config{
+			"configs/db/1.0":  {Name: "db", Version: "1.0", Params: map[string]string{"url": "${config:base@1.0:host}", "literal": "$${config:gone@1.0:host}"}},
+			"configs/app/1.0": {Name: "app", Version: "1.0", Extends: "db@1.0"},
+		},
+		GroupConfigs: map[string]model.Config{
+			"config-groups/payments/1.0/configs/db/1.0": {Name: "db", Version: "1.0", Extends: "missing@1.0"},
+		},
+	}}
+	service := NewFsckService(repo)
+
+	// References to configs which don't exist are reported, escaped ones aren't references
+	report, err := service.Check(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Scanned)
+	assert.Equal(t, []string{
+		"config-groups/payments/1.0/configs/db/1.0",
+		"configs/broken/1.0",
+		"configs/db/1.0",
+		"configs/db/1.0-copy",
+	}, []string{report.Issues[0].Key, report.Issues[1].Key, report.Issues[2].Key, report.Issues[3].Key})
+	assert.Equal(t, model.IssueDanglingReference, report.Issues[0].Kind)
+	assert.Equal(t, "param url references base@1.0, which doesn't exist", report.Issues[2].Detail)
+	assert.Empty(t, repo.applied)
+
+	// Only the issues with a repair are repaired
+	report, err = service.Check(context.Background(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Repaired)
+	assert.Equal(t, []model.KeyChange{repair}, repo.applied)
+	assert.True(t, report.Issues[3].Repaired)
+	assert.False(t, report.Issues[1].Repaired)
+}
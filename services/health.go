@@ -0,0 +1,127 @@
+// The `HealthService` answers the health endpoints: whether the process is alive, whether it is ready
+// to serve requests and the detailed status of the store behind it.
+package services
+
+import (
+	"context"
+	"project/model"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// healthCheckTimeout bounds each readiness check, so a hanging store doesn't hang the probes.
+const healthCheckTimeout = 2 * time.Second
+
+// ReadinessCheck fails while the server can't serve requests yet, e.g. until migrations are done.
+type ReadinessCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+type HealthService struct {
+	repo      model.StatusRepository
+	startedAt time.Time
+
+	shuttingDown atomic.Bool
+	mu           sync.Mutex
+	checks       []namedCheck
+}
+
+// NewHealthService creates the service. The store being reachable is always checked, other checks are
+// registered with AddCheck.
+func NewHealthService(repo model.StatusRepository) *HealthService {
+	return &HealthService{
+		repo:      repo,
+		startedAt: time.Now().UTC(),
+	}
+}
+
+// AddCheck registers a readiness check.
+func (s *HealthService) AddCheck(name string, check ReadinessCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, namedCheck{name: name, check: check})
+}
+
+// SetShuttingDown makes the server report not ready for the rest of its life, so load balancers stop
+// sending it requests while the in-flight ones finish.
+func (s *HealthService) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+// Ready runs every readiness check and reports the server ready only if all of them pass and it isn't
+// shutting down. With details the status of the store is included.
+func (s *HealthService) Ready(ctx context.Context, details bool) model.HealthReport {
+	// The store check may outlive Ready when it times out, so its status is handed over on a channel
+	storeStatus := make(chan model.StoreStatus, 1)
+	checks := []namedCheck{{name: "store", check: func(ctx context.Context) error {
+		status, err := s.repo.Status(ctx)
+		storeStatus <- status
+		return err
+	}}}
+	s.mu.Lock()
+	checks = append(checks, s.checks...)
+	s.mu.Unlock()
+
+	report := model.HealthReport{
+		Ready:        true,
+		ShuttingDown: s.shuttingDown.Load(),
+		StartedAt:    s.startedAt,
+		Uptime:       time.Since(s.startedAt).Round(time.Second).String(),
+		Checks:       make([]model.CheckResult, len(checks)),
+	}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check namedCheck) {
+			defer wg.Done()
+			report.Checks[i] = runCheck(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		report.Ready = report.Ready && result.OK
+	}
+	if report.ShuttingDown {
+		report.Ready = false
+	}
+	report.Status = "ok"
+	if !report.Ready {
+		report.Status = "unavailable"
+	}
+	if details {
+		select {
+		case store := <-storeStatus:
+			report.Store = &store
+		default:
+		}
+	}
+	return report
+}
+
+// runCheck runs the check with a timeout. A check which doesn't return in time is reported failed and
+// left running in the background.
+func runCheck(ctx context.Context, check namedCheck) model.CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check.check(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := model.CheckResult{Name: check.name, OK: err == nil, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"project/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubStatusRepository struct {
+	err error
+}
+
+func (repo stubStatusRepository) Status(_ context.Context) (model.StoreStatus, error) {
+	return model.StoreStatus{Backend: "consul", Leader: "127.0.0.1:8300"}, repo.err
+}
+
+func TestHealthService_Ready(t *testing.T) {
+	service := NewHealthService(stubStatusRepository{})
+	report := service.Ready(context.Background(), true)
+	assert.True(t, report.Ready)
+	assert.Equal(t, "ok", report.Status)
+	assert.Equal(t, "127.0.0.1:8300", report.Store.Leader)
+
+	// A failing check makes the server not ready
+	pending := errors.New("migrations pending")
+	service.AddCheck("migrations", func(ctx context.Context) error { return pending })
+	report = service.Ready(context.Background(), false)
+	assert.False(t, report.Ready)
+	assert.Nil(t, report.Store)
+	assert.Equal(t, []string{"store", "migrations"}, []string{report.Checks[0].Name, report.Checks[1].Name})
+	assert.True(t, report.Checks[0].OK)
+	assert.Equal(t, pending.Error(), report.Checks[1].Error)
+
+	// An unreachable store makes the server not ready
+	service = NewHealthService(stubStatusRepository{err: errors.New("connection refused")})
+	assert.False(t, service.Ready(context.Background(), false).Ready)
+
+	// The server stays not ready once the shutdown started
+	service = NewHealthService(stubStatusRepository{})
+	service.SetShuttingDown()
+	report = service.Ready(context.Background(), false)
+	assert.False(t, report.Ready)
+	assert.True(t, report.ShuttingDown)
+}
@@ -0,0 +1,205 @@
+// The interpolator resolves ${...} references inside param values at read time. A reference can point
+// at another param of the same config (${host}), at a param of another config (${config:db@1.0:host})
+// or at a variable of the group the config belongs to (${group:region}). $${ is a literal ${.
+// A param which references a secret param becomes secret itself, so its value is masked as well.
+package services
+
+import (
+	"errors"
+	"fmt"
+	"project/model"
+	"strings"
+)
+
+var (
+	// ErrInterpolationCycle is returned when a param references itself through other references.
+	ErrInterpolationCycle = errors.New("interpolation cycle detected")
+	// ErrUnresolvedReference is returned in strict mode when a reference can't be resolved.
+	ErrUnresolvedReference = errors.New("unresolved reference")
+)
+
+// scope is the context a value is interpolated in.
+type scope struct {
+	// id names the config or group the value belongs to, e.g. db@1.0
+	id string
+	// params are the params of the config, nil when interpolating a group variable
+	params map[string]string
+	// secrets lists the secret params of the config
+	secrets []string
+	// groupID and group are the name and variables of the enclosing group, if any
+	groupID string
+	group   map[string]string
+}
+
+type interpolator struct {
+	// lookupConfig returns the effective, not yet interpolated, config
+	lookupConfig func(ref model.Ref) (model.Config, error)
+	strict       bool
+	stack        []string
+	// tainted is set when a secret param was read while resolving the current param
+	tainted bool
+}
+
+// interpolate returns a copy of the params of the scope with every reference resolved, and the
+// params which are not secret themselves but reference a secret param.
+func (in *interpolator) interpolate(sc scope) (map[string]string, []string, error) {
+	if sc.params == nil {
+		return nil, nil, nil
+	}
+	config := model.Config{Secrets: sc.secrets}
+	result := make(map[string]string, len(sc.params))
+	var tainted []string
+	for key := range sc.params {
+		in.tainted = false
+		value, _, err := in.resolveLocal(sc, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		result[key] = value
+		if in.tainted && !config.IsSecret(key) {
+			tainted = append(tainted, key)
+		}
+	}
+	return result, tainted, nil
+}
+
+// expand replaces every reference inside s.
+func (in *interpolator) expand(sc scope, s string) (string, error) {
+	var out strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			out.WriteString(s)
+			return out.String(), nil
+		}
+		// $${ escapes a literal ${
+		if start > 0 && s[start-1] == '$' {
+			out.WriteString(s[:start-1])
+			out.WriteString("${")
+			s = s[start+2:]
+			continue
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			out.WriteString(s)
+			return out.String(), nil
+		}
+		end += start
+
+		out.WriteString(s[:start])
+		expr := s[start+2 : end]
+		value, ok, err := in.resolve(sc, expr)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			if in.strict {
+				return "", fmt.Errorf("%w ${%s} in %s", ErrUnresolvedReference, expr, sc.id)
+			}
+			value = s[start : end+1]
+		}
+		out.WriteString(value)
+		s = s[end+1:]
+	}
+}
+
+// resolve returns the value of a single reference, and false when it doesn't point at anything.
+func (in *interpolator) resolve(sc scope, expr string) (string, bool, error) {
+	switch {
+	case strings.HasPrefix(expr, "config:"):
+		refString, key, ok := strings.Cut(strings.TrimPrefix(expr, "config:"), ":")
+		if !ok {
+			return "", false, nil
+		}
+		ref, err := model.ParseRef(refString)
+		if err != nil {
+			return "", false, nil
+		}
+		config, err := in.lookupConfig(ref)
+		if errors.Is(err, model.ErrConfigNotFound) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return in.resolveLocal(scope{id: ref.String(), params: config.Params, secrets: config.Secrets}, key)
+	case strings.HasPrefix(expr, "group:"):
+		if sc.group == nil {
+			return "", false, nil
+		}
+		return in.resolveVariable(sc, strings.TrimPrefix(expr, "group:"))
+	default:
+		return in.resolveLocal(sc, expr)
+	}
+}
+
+// resolveLocal returns the interpolated value of a param of the scope.
+func (in *interpolator) resolveLocal(sc scope, key string) (string, bool, error) {
+	raw, ok := sc.params[key]
+	if !ok {
+		return "", false, nil
+	}
+	if (model.Config{Secrets: sc.secrets}).IsSecret(key) {
+		in.tainted = true
+	}
+	value, err := in.enter(sc.id+":"+key, func() (string, error) {
+		return in.expand(sc, raw)
+	})
+	return value, true, err
+}
+
+// resolveVariable returns the interpolated value of a group variable, which may reference other
+// variables of the group and params of other configs.
+func (in *interpolator) resolveVariable(sc scope, name string) (string, bool, error) {
+	raw, ok := sc.group[name]
+	if !ok {
+		return "", false, nil
+	}
+	groupScope := scope{id: sc.groupID, groupID: sc.groupID, group: sc.group}
+	value, err := in.enter(sc.groupID+":group:"+name, func() (string, error) {
+		return in.expand(groupScope, raw)
+	})
+	return value, true, err
+}
+
+// enter runs resolve with id on the stack of references being resolved, detecting cycles.
+func (in *interpolator) enter(id string, resolve func() (string, error)) (string, error) {
+	for i, visited := range in.stack {
+		if visited == id {
+			path := append(append([]string{}, in.stack[i:]...), id)
+			return "", fmt.Errorf("%w: %s", ErrInterpolationCycle, strings.Join(path, " -> "))
+		}
+	}
+	in.stack = append(in.stack, id)
+	defer func() { in.stack = in.stack[:len(in.stack)-1] }()
+	return resolve()
+}
+
+// configReferences returns the configs the ${config:...} references inside s point at, with the same
+// syntax as expand. References which don't parse are skipped.
+func configReferences(s string) []model.Ref {
+	var refs []model.Ref
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			return refs
+		}
+		if start > 0 && s[start-1] == '$' {
+			s = s[start+2:]
+			continue
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return refs
+		}
+		end += start
+
+		expr := s[start+2 : end]
+		if refString, _, ok := strings.Cut(strings.TrimPrefix(expr, "config:"), ":"); ok && strings.HasPrefix(expr, "config:") {
+			if ref, err := model.ParseRef(refString); err == nil {
+				refs = append(refs, ref)
+			}
+		}
+		s = s[end+1:]
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"project/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigService_Interpolation(t *testing.T) {
+	repo := memoryConfigRepository{}
+	service := NewConfigService(repo, nil)
+
+	repo.Add(context.Background(), model.Config{Name: "db", Version: "1.0", Params: map[string]string{"host": "db.internal", "port": "5432"}})
+	repo.Add(context.Background(), model.Config{Name: "app", Version: "1.0", Params: map[string]string{
+		"dsn":     "postgres://${config:db@1.0:host}:${config:db@1.0:port}/${name}",
+		"name":    "orders",
+		"literal": "$${name}",
+		"missing": "${nope}",
+	}})
+
+	resolved, err := service.Resolve(context.Background(), "app", "1.0", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://db.internal:5432/orders", resolved.Params["dsn"])
+	assert.Equal(t, "${name}", resolved.Params["literal"])
+	assert.Equal(t, "${nope}", resolved.Params["missing"])
+
+	// Strict mode reports references which can't be resolved
+	_, err = service.Resolve(context.Background(), "app", "1.0", true)
+	assert.True(t, errors.Is(err, ErrUnresolvedReference))
+
+	// Params referencing a secret param become secret as well
+	repo.Add(context.Background(), model.Config{Name: "creds", Version: "1.0", Params: map[string]string{"password": "hunter2"}, Secrets: []string{"password"}})
+	repo.Add(context.Background(), model.Config{Name: "client", Version: "1.0", Params: map[string]string{"url": "pg://app:${config:creds@1.0:password}@db", "pool": "5"}})
+	resolved, err = service.Resolve(context.Background(), "client", "1.0", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "pg://app:hunter2@db", resolved.Params["url"])
+	assert.Equal(t, []string{"url"}, resolved.Secrets)
+	assert.Equal(t, model.SecretMask, resolved.Masked().Params["url"])
+
+	// References which point back at themselves are a cycle
+	repo.Add(context.Background(), model.Config{Name: "loop", Version: "1.0", Params: map[string]string{"a": "${b}", "b": "${config:loop@1.0:a}"}})
+	_, err = service.Resolve(context.Background(), "loop", "1.0", false)
+	assert.True(t, errors.Is(err, ErrInterpolationCycle))
+
+	// Only a referenced config which doesn't exist leaves the reference as it is, other failures to
+	// read it are returned
+	repo.Add(context.Background(), model.Config{Name: "ring", Version: "1.0", Extends: "ring@1.0"})
+	repo.Add(context.Background(), model.Config{Name: "ringed", Version: "1.0", Params: map[string]string{"a": "${config:ring@1.0:a}", "b": "${config:gone@1.0:b}"}})
+	_, err = service.Resolve(context.Background(), "ringed", "1.0", false)
+	assert.True(t, errors.Is(err, ErrInheritanceCycle))
+	repo.Add(context.Background(), model.Config{Name: "ringed", Version: "1.0", Params: map[string]string{"b": "${config:gone@1.0:b}"}})
+	resolved, err = service.Resolve(context.Background(), "ringed", "1.0", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "${config:gone@1.0:b}", resolved.Params["b"])
+}
+
+func TestConfigGroupService_InterpolateGroupVariables(t *testing.T) {
+	service := NewConfigGroupService(nil, nil, NewConfigService(memoryConfigRepository{}, nil))
+	group := model.ConfigGroup{
+		Name:      "payments",
+		Version:   "1.0",
+		Variables: map[string]string{"region": "eu", "bucket": "payments-${group:region}"},
+	}
+	configs := []*model.ConfigWithLabels{
+		{Config: model.Config{Name: "s3", Version: "1.0", Params: map[string]string{"url": "s3://${group:bucket}/${group:unknown}"}}},
+	}
+
+	resolved, err := service.interpolateConfigs(context.Background(), group, configs, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://payments-eu/${group:unknown}", resolved[0].Params["url"])
+	// The stored config is left untouched
+	assert.Equal(t, "s3://${group:bucket}/${group:unknown}", configs[0].Params["url"])
+}
@@ -0,0 +1,123 @@
+// The `LockService` struct locks configs and config groups so they can't be deleted, until the owner
+// of the lock, or a caller allowed to break locks, unlocks them. The repositories check the locks in the
+// transactions of the writes they forbid, the services read them before with checkUnlocked for an error
+// naming the owner before anything else is read.
+package services
+
+import (
+	"context"
+	"fmt"
+	"project/auth"
+	"project/model"
+	"project/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type LockService struct {
+	repo    model.LockRepository
+	configs model.ConfigRepository
+	groups  model.ConfigGroupRepository
+	now     func() time.Time
+}
+
+func NewLockService(repo model.LockRepository, configs model.ConfigRepository, groups model.ConfigGroupRepository) LockService {
+	return LockService{
+		repo:    repo,
+		configs: configs,
+		groups:  groups,
+		now:     time.Now,
+	}
+}
+
+// Lock locks the existing config or group on behalf of the caller, who becomes the owner of the lock.
+// Anonymous callers can't lock, every one of them would own the lock.
+func (s LockService) Lock(ctx context.Context, kind string, name string, version string, reason string) (model.Lock, error) {
+	ctx, span := tracing.Start(ctx, "LockService.Lock", attribute.String("kind", kind), attribute.String("name", name), attribute.String("version", version))
+	defer span.End()
+
+	caller := auth.FromContext(ctx)
+	if caller.Subject == auth.Anonymous.Subject {
+		return model.Lock{}, fmt.Errorf("%w: locking requires an authenticated caller", model.ErrNotLockOwner)
+	}
+	if reason == "" {
+		return model.Lock{}, fmt.Errorf("%w: a lock needs a reason", model.ErrInvalid)
+	}
+	var err error
+	if kind == model.KindConfigGroup {
+		_, err = s.groups.Get(ctx, name, version)
+	} else {
+		_, err = s.configs.Get(ctx, name, version)
+	}
+	if err != nil {
+		return model.Lock{}, err
+	}
+
+	lock := model.Lock{Kind: kind, Name: name, Version: version, Owner: caller.Subject, Reason: reason, LockedAt: s.now().UTC()}
+	locked, err := s.repo.Lock(ctx, lock)
+	if err != nil {
+		return model.Lock{}, err
+	}
+	if !locked {
+		return model.Lock{}, checkUnlocked(ctx, s.repo, kind, name, version)
+	}
+	return lock, nil
+}
+
+// Unlock removes the lock. Only its owner and callers holding the locks:break permission may, locks
+// owned by the anonymous identity only the latter.
+func (s LockService) Unlock(ctx context.Context, kind string, name string, version string) error {
+	ctx, span := tracing.Start(ctx, "LockService.Unlock", attribute.String("kind", kind), attribute.String("name", name), attribute.String("version", version))
+	defer span.End()
+
+	lock, err := s.repo.Get(ctx, kind, name, version)
+	if err != nil {
+		return err
+	}
+	ref := model.Ref{Name: name, Version: version}
+	if lock == nil {
+		return fmt.Errorf("%w: %s %s", model.ErrNotLocked, kind, ref)
+	}
+	caller := auth.FromContext(ctx)
+	owner := caller.Subject == lock.Owner && lock.Owner != auth.Anonymous.Subject
+	if !owner && !caller.Can(auth.PermissionBreakLocks) {
+		return fmt.Errorf("%w: %s %s is locked by %s, unlocking it requires the %s permission", model.ErrNotLockOwner, kind, ref, lock.Owner, auth.PermissionBreakLocks)
+	}
+	return s.repo.Unlock(ctx, kind, name, version)
+}
+
+// Get returns the lock of the config or group, or an error wrapping model.ErrNotLocked.
+func (s LockService) Get(ctx context.Context, kind string, name string, version string) (model.Lock, error) {
+	ctx, span := tracing.Start(ctx, "LockService.Get", attribute.String("kind", kind), attribute.String("name", name), attribute.String("version", version))
+	defer span.End()
+
+	lock, err := s.repo.Get(ctx, kind, name, version)
+	if err != nil {
+		return model.Lock{}, err
+	}
+	if lock == nil {
+		return model.Lock{}, fmt.Errorf("%w: %s %s", model.ErrNotLocked, kind, model.Ref{Name: name, Version: version})
+	}
+	return *lock, nil
+}
+
+func (s LockService) List(ctx context.Context) ([]model.Lock, error) {
+	ctx, span := tracing.Start(ctx, "LockService.List")
+	defer span.End()
+
+	return s.repo.List(ctx)
+}
+
+// checkUnlocked returns an error wrapping model.ErrLocked, naming the owner and the reason, if the
+// config or group is locked.
+func checkUnlocked(ctx context.Context, locks model.LockRepository, kind string, name string, version string) error {
+	lock, err := locks.Get(ctx, kind, name, version)
+	if err != nil {
+		return err
+	}
+	if lock != nil {
+		return fmt.Errorf("%w: %s %s is locked by %s: %s", model.ErrLocked, kind, model.Ref{Name: name, Version: version}, lock.Owner, lock.Reason)
+	}
+	return nil
+}
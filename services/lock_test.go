@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"project/auth"
+	"project/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryLockRepository map[string]model.Lock
+
+func (repo memoryLockRepository) Get(_ context.Context, kind string, name string, version string) (*model.Lock, error) {
+	lock, ok := repo[kind+"/"+name+"/"+version]
+	if !ok {
+		return nil, nil
+	}
+	return &lock, nil
+}
+
+func (repo memoryLockRepository) List(_ context.Context) ([]model.Lock, error) {
+	locks := make([]model.Lock, 0, len(repo))
+	for _, lock := range repo {
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
+
+func (repo memoryLockRepository) Lock(_ context.Context, lock model.Lock) (bool, error) {
+	key := lock.Kind + "/" + lock.Name + "/" + lock.Version
+	if _, ok := repo[key]; ok {
+		return false, nil
+	}
+	repo[key] = lock
+	return true, nil
+}
+
+func (repo memoryLockRepository) Unlock(_ context.Context, kind string, name string, version string) error {
+	delete(repo, kind+"/"+name+"/"+version)
+	return nil
+}
+
+func TestLockService(t *testing.T) {
+	locks := memoryLockRepository{}
+	configs := memoryConfigRepository{{Name: "db", Version: "1.0"}: {Name: "db", Version: "1.0", Params: map[string]string{"host": "a"}}}
+	groups := &memoryConfigGroupRepository{groups: []model.ConfigGroup{{Name: "payments", Version: "2.0", Configs: []*model.ConfigWithLabels{
+		{Config: model.Config{Name: "db", Version: "1.0"}},
+	}}}}
+	service := NewLockService(locks, configs, groups)
+	configService := NewConfigService(configs, locks)
+	groupService := NewConfigGroupService(groups, locks, configService)
+	as := func(subject string, permissions ...string) context.Context {
+		return auth.WithIdentity(context.Background(), auth.Identity{Subject: subject, Permissions: permissions})
+	}
+
+	// Locks need a reason and an existing object
+	_, err := service.Lock(as("alice"), model.KindConfigGroup, "payments", "2.0", "")
+	assert.ErrorIs(t, err, model.ErrInvalid)
+	_, err = service.Lock(as("alice"), model.KindConfigGroup, "payments", "3.0", "release")
+	assert.ErrorIs(t, err, model.ErrGroupNotFound)
+
+	lock, err := service.Lock(as("alice"), model.KindConfigGroup, "payments", "2.0", "release")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", lock.Owner)
+	_, err = service.Lock(as("bob"), model.KindConfigGroup, "payments", "2.0", "mine")
+	assert.ErrorIs(t, err, model.ErrLocked)
+	_, err = service.Lock(as("alice"), model.KindConfig, "db", "1.0", "release")
+	require.NoError(t, err)
+
+	// Locked objects can't be deleted, locked groups don't lose configs
+	err = configService.Delete(context.Background(), "db", "1.0")
+	assert.ErrorIs(t, err, model.ErrLocked)
+	assert.ErrorContains(t, err, "locked by alice: release")
+	assert.ErrorIs(t, groupService.Delete(context.Background(), "payments", "2.0"), model.ErrLocked)
+	assert.ErrorIs(t, groupService.RemoveConfigFromGroup(context.Background(), "payments", "2.0", "db", "1.0"), model.ErrLocked)
+	assert.ErrorIs(t, groupService.RemoveConfigsWithLabelsFromGroup(context.Background(), "payments", "2.0", nil, "db", "1.0"), model.ErrLocked)
+	assert.Len(t, groups.groups[0].Configs, 1)
+
+	// Anonymous callers can't lock, and can't unlock a lock owned by the anonymous identity
+	_, err = service.Lock(context.Background(), model.KindConfigGroup, "payments", "2.0", "release")
+	assert.ErrorIs(t, err, model.ErrNotLockOwner)
+	locks[model.KindConfig+"/db/2.0"] = model.Lock{Kind: model.KindConfig, Name: "db", Version: "2.0", Owner: auth.Anonymous.Subject, Reason: "legacy"}
+	assert.ErrorIs(t, service.Unlock(context.Background(), model.KindConfig, "db", "2.0"), model.ErrNotLockOwner)
+	require.NoError(t, service.Unlock(as("carol", auth.PermissionBreakLocks), model.KindConfig, "db", "2.0"))
+
+	// Only the owner or a caller allowed to break locks unlocks them
+	assert.ErrorIs(t, service.Unlock(as("bob"), model.KindConfigGroup, "payments", "2.0"), model.ErrNotLockOwner)
+	require.NoError(t, service.Unlock(as("carol", auth.PermissionBreakLocks), model.KindConfigGroup, "payments", "2.0"))
+	assert.ErrorIs(t, service.Unlock(as("alice"), model.KindConfigGroup, "payments", "2.0"), model.ErrNotLocked)
+	require.NoError(t, service.Unlock(as("alice"), model.KindConfig, "db", "1.0"))
+	_, err = service.Get(context.Background(), model.KindConfig, "db", "1.0")
+	assert.ErrorIs(t, err, model.ErrNotLocked)
+
+	require.NoError(t, groupService.RemoveConfigFromGroup(context.Background(), "payments", "2.0", "db", "1.0"))
+	require.NoError(t, configService.Delete(context.Background(), "db", "1.0"))
+}
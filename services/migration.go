@@ -0,0 +1,148 @@
+// The `MigrationService` brings the keyspace of the store to the layout the current version reads and
+// writes, running the migrations the schema marker of the store doesn't record yet.
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"project/model"
+	"project/tracing"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrMigrationRunning is returned by Run while another run is in progress.
+	ErrMigrationRunning = errors.New("migrations are already running")
+	// ErrMigrationsPending is returned by Ready until the store is at the latest schema version.
+	ErrMigrationsPending = errors.New("store migrations are pending")
+)
+
+type MigrationService struct {
+	repo model.MigrationRepository
+
+	// running serializes the runs
+	running sync.Mutex
+	// version is the schema version of the store after the last run, -1 before the first one
+	version atomic.Int64
+
+	mu      sync.Mutex
+	lastRun *model.MigrationReport
+}
+
+func NewMigrationService(repo model.MigrationRepository) *MigrationService {
+	s := &MigrationService{repo: repo}
+	s.version.Store(-1)
+	return s
+}
+
+// Run plans every migration newer than the schema marker in order. Unless dryRun is set each of them is
+// applied and the marker advanced right after, so a run which fails resumes with the migration which
+// failed. A dry run plans every pending migration against the store as it is, so later migrations may
+// list changes an earlier one would have made unnecessary.
+func (s *MigrationService) Run(ctx context.Context, dryRun bool) (model.MigrationReport, error) {
+	if !s.running.TryLock() {
+		return model.MigrationReport{}, ErrMigrationRunning
+	}
+	defer s.running.Unlock()
+	ctx, span := tracing.Start(ctx, "MigrationService.Run", attribute.Bool("dry_run", dryRun))
+	defer span.End()
+
+	report := model.MigrationReport{DryRun: dryRun, StartedAt: time.Now().UTC(), Migrations: []model.MigrationResult{}}
+	marker, err := s.repo.GetSchema(ctx)
+	if err != nil {
+		return s.finish(report, fmt.Errorf("reading schema marker: %w", err))
+	}
+	report.FromVersion = marker.Version
+	report.ToVersion = marker.Version
+
+	for _, migration := range s.repo.Migrations() {
+		if migration.Version <= marker.Version {
+			continue
+		}
+		changes, conflicts, err := s.repo.Plan(ctx, migration.Version)
+		if err != nil {
+			return s.finish(report, fmt.Errorf("planning migration %d %s: %w", migration.Version, migration.Name, err))
+		}
+		result := model.MigrationResult{Migration: migration, Changes: changes, Conflicts: conflicts}
+		if result.Changes == nil {
+			result.Changes = []model.KeyChange{}
+		}
+		if !dryRun {
+			if err := s.repo.Apply(ctx, changes); err != nil {
+				report.Migrations = append(report.Migrations, result)
+				return s.finish(report, fmt.Errorf("applying migration %d %s: %w", migration.Version, migration.Name, err))
+			}
+			marker = model.SchemaMarker{Version: migration.Version, UpdatedAt: time.Now().UTC()}
+			if err := s.repo.SaveSchema(ctx, marker); err != nil {
+				report.Migrations = append(report.Migrations, result)
+				return s.finish(report, fmt.Errorf("saving schema marker: %w", err))
+			}
+			result.Applied = true
+			report.ToVersion = migration.Version
+		}
+		report.Migrations = append(report.Migrations, result)
+	}
+	return s.finish(report, nil)
+}
+
+// finish records the outcome of the run for Status and Ready.
+func (s *MigrationService) finish(report model.MigrationReport, err error) (model.MigrationReport, error) {
+	report.FinishedAt = time.Now().UTC()
+	if err != nil {
+		report.Error = err.Error()
+	}
+	if !report.DryRun && report.ToVersion > int(s.version.Load()) {
+		s.version.Store(int64(report.ToVersion))
+	}
+	s.mu.Lock()
+	s.lastRun = &report
+	s.mu.Unlock()
+	return report, err
+}
+
+// Status returns the schema version of the store, the migrations it still needs and the report of the
+// last run.
+func (s *MigrationService) Status(ctx context.Context) (model.MigrationStatus, error) {
+	marker, err := s.repo.GetSchema(ctx)
+	if err != nil {
+		return model.MigrationStatus{}, err
+	}
+	status := model.MigrationStatus{Version: marker.Version, Pending: []model.Migration{}}
+	for _, migration := range s.repo.Migrations() {
+		status.Latest = migration.Version
+		if migration.Version > marker.Version {
+			status.Pending = append(status.Pending, migration)
+		}
+	}
+	s.mu.Lock()
+	if s.lastRun != nil {
+		lastRun := *s.lastRun
+		status.LastRun = &lastRun
+	}
+	s.mu.Unlock()
+	return status, nil
+}
+
+// Ready fails until a run brought the store to the latest schema version, so the server isn't sent
+// requests while keys are still in a legacy layout. It is registered as a readiness check.
+func (s *MigrationService) Ready(_ context.Context) error {
+	migrations := s.repo.Migrations()
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	if s.version.Load() >= int64(latest) {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastRun != nil && s.lastRun.Error != "" {
+		return fmt.Errorf("%w: %s", ErrMigrationsPending, s.lastRun.Error)
+	}
+	return ErrMigrationsPending
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"project/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryMigrationKeys are the keys the migrations of memoryMigrationRepository delete.
+var memoryMigrationKeys = map[int]string{1: "first", 2: "second"}
+
+// memoryMigrationRepository plans a single change per migration and records the ones applied.
+type memoryMigrationRepository struct {
+	marker  model.SchemaMarker
+	applied []model.KeyChange
+	// failAt makes Apply fail for the migration
+	failAt int
+}
+
+func (repo *memoryMigrationRepository) GetSchema(_ context.Context) (model.SchemaMarker, error) {
+	return repo.marker, nil
+}
+
+func (repo *memoryMigrationRepository) SaveSchema(_ context.Context, marker model.SchemaMarker) error {
+	repo.marker = marker
+	return nil
+}
+
+func (repo *memoryMigrationRepository) Migrations() []model.Migration {
+	return []model.Migration{{Version: 1, Name: "first"}, {Version: 2, Name: "second"}}
+}
+
+func (repo *memoryMigrationRepository) Plan(_ context.Context, version int) ([]model.KeyChange, []string, error) {
+	return []model.KeyChange{{Action: model.KeyDelete, Key: memoryMigrationKeys[version]}}, nil, nil
+}
+
+func (repo *memoryMigrationRepository) Apply(_ context.Context, changes []model.KeyChange) error {
+	if len(changes) > 0 && changes[0].Key == memoryMigrationKeys[repo.failAt] {
+		return errors.New("store unavailable")
+	}
+	repo.applied = append(repo.applied, changes...)
+	return nil
+}
+
+func TestMigrationService_Run(t *testing.T) {
+	repo := &memoryMigrationRepository{failAt: 2}
+	service := NewMigrationService(repo)
+	ctx := context.Background()
+	assert.ErrorIs(t, service.Ready(ctx), ErrMigrationsPending)
+
+	// A dry run plans every pending migration and writes nothing
+	report, err := service.Run(ctx, true)
+	assert.NoError(t, err)
+	assert.Len(t, report.Migrations, 2)
+	assert.Empty(t, repo.applied)
+	assert.Equal(t, 0, repo.marker.Version)
+	assert.ErrorIs(t, service.Ready(ctx), ErrMigrationsPending)
+
+	// The marker advances after every migration, so a failed run resumes with the migration which failed
+	report, err = service.Run(ctx, false)
+	assert.Error(t, err)
+	assert.Equal(t, 1, report.ToVersion)
+	assert.Equal(t, 1, repo.marker.Version)
+	assert.True(t, report.Migrations[0].Applied)
+	assert.False(t, report.Migrations[1].Applied)
+	assert.ErrorContains(t, service.Ready(ctx), "store unavailable")
+
+	repo.failAt = 0
+	report, err = service.Run(ctx, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.FromVersion)
+	assert.Equal(t, 2, report.ToVersion)
+	assert.Equal(t, []model.KeyChange{{Action: model.KeyDelete, Key: "first"}, {Action: model.KeyDelete, Key: "second"}}, repo.applied)
+	assert.NoError(t, service.Ready(ctx))
+
+	status, err := service.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, status.Version)
+	assert.Equal(t, 2, status.Latest)
+	assert.Empty(t, status.Pending)
+	assert.Equal(t, 2, status.LastRun.ToVersion)
+}
@@ -0,0 +1,204 @@
+// The `ReencryptionService` runs the background job which rewraps every encrypted param with the
+// primary key of the keyring, so a rotated key can eventually be removed from the keyring.
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"project/model"
+	"project/secrets"
+	"project/tracing"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrReencryptionRunning is returned by Start while a job is already running.
+	ErrReencryptionRunning = errors.New("a re-encryption job is already running")
+	// ErrNoKeyring is returned by Start when no encryption keyring is configured.
+	ErrNoKeyring = errors.New("no encryption keyring is configured")
+)
+
+// maxReportedFailures caps the number of failed keys kept in the job state.
+const maxReportedFailures = 100
+
+// reencryptionBatchSize is how many keys are processed between two saves of the job state.
+const reencryptionBatchSize = 50
+
+type ReencryptionService struct {
+	repo    model.ReencryptionRepository
+	keyring *secrets.Keyring
+
+	mu      sync.Mutex
+	running *model.ReencryptionJob
+}
+
+func NewReencryptionService(repo model.ReencryptionRepository, keyring *secrets.Keyring) *ReencryptionService {
+	return &ReencryptionService{
+		repo:    repo,
+		keyring: keyring,
+	}
+}
+
+// Start starts a job in the background and returns its initial state. A previous job which didn't
+// complete is resumed from its last processed key, unless the primary key changed since it started.
+func (s *ReencryptionService) Start(ctx context.Context) (model.ReencryptionJob, error) {
+	if s.keyring == nil {
+		return model.ReencryptionJob{}, ErrNoKeyring
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running != nil {
+		return *s.running, ErrReencryptionRunning
+	}
+
+	previous, err := s.repo.GetJob(ctx)
+	if err != nil {
+		return model.ReencryptionJob{}, err
+	}
+	now := time.Now().UTC()
+	var job model.ReencryptionJob
+	if previous != nil && previous.Status != model.ReencryptionCompleted && previous.KeyID == s.keyring.Primary() {
+		job = *previous
+	} else {
+		job = model.ReencryptionJob{
+			ID:        now.Format("20060102T150405Z"),
+			KeyID:     s.keyring.Primary(),
+			StartedAt: now,
+		}
+	}
+	job.Status = model.ReencryptionRunning
+	job.Error = ""
+	job.UpdatedAt = now
+	if err := s.repo.SaveJob(ctx, job); err != nil {
+		return model.ReencryptionJob{}, err
+	}
+
+	s.start(job)
+	return job, nil
+}
+
+// Resume continues a job which was still running when the server stopped. It returns nil if there is
+// nothing to resume.
+func (s *ReencryptionService) Resume(ctx context.Context) (*model.ReencryptionJob, error) {
+	if s.keyring == nil {
+		return nil, nil
+	}
+	job, err := s.repo.GetJob(ctx)
+	if err != nil || job == nil || job.Status != model.ReencryptionRunning {
+		return nil, err
+	}
+	resumed, err := s.Start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &resumed, nil
+}
+
+// Status returns the state of the running job, or of the last job if none is running. It returns nil
+// if no job was ever started.
+func (s *ReencryptionService) Status(ctx context.Context) (*model.ReencryptionJob, error) {
+	s.mu.Lock()
+	if s.running != nil {
+		job := *s.running
+		s.mu.Unlock()
+		return &job, nil
+	}
+	s.mu.Unlock()
+	return s.repo.GetJob(ctx)
+}
+
+// start runs the job in a goroutine; the caller holds s.mu. The job outlives the request which
+// started it, so it is traced separately.
+func (s *ReencryptionService) start(job model.ReencryptionJob) {
+	snapshot := job
+	s.running = &snapshot
+	go func() {
+		ctx, span := tracing.Start(context.Background(), "ReencryptionService.run", attribute.String("job.id", job.ID))
+		job := s.run(ctx, job)
+		span.End()
+		s.mu.Lock()
+		s.running = nil
+		s.mu.Unlock()
+		if job.Status == model.ReencryptionFailed {
+			log.Printf("Re-encryption job %s failed: %s", job.ID, job.Error)
+		} else {
+			log.Printf("Re-encryption job %s completed: %d of %d keys re-encrypted, %d failed", job.ID, job.Reencrypted, job.Total, len(job.FailedKeys))
+		}
+	}()
+}
+
+// run walks every key after the cursor of the job, saving the state after every batch, and returns
+// the final state of the job.
+func (s *ReencryptionService) run(ctx context.Context, job model.ReencryptionJob) model.ReencryptionJob {
+	keys, err := s.repo.Keys(ctx)
+	if err != nil {
+		return s.finish(ctx, job, err)
+	}
+	job.Total = len(keys)
+	job.Scanned = 0
+
+	for i, key := range keys {
+		job.Scanned++
+		if key <= job.Cursor {
+			continue
+		}
+
+		changed, err := s.repo.Reencrypt(ctx, key)
+		if errors.Is(err, model.ErrUnreadableSecret) {
+			if len(job.FailedKeys) < maxReportedFailures {
+				job.FailedKeys = append(job.FailedKeys, key)
+			}
+		} else if err != nil {
+			return s.finish(ctx, job, fmt.Errorf("re-encrypting %s: %w", key, err))
+		}
+		if changed {
+			job.Reencrypted++
+		}
+		job.Cursor = key
+
+		if (i+1)%reencryptionBatchSize == 0 {
+			if err := s.save(ctx, &job); err != nil {
+				return s.finish(ctx, job, err)
+			}
+		}
+	}
+	return s.finish(ctx, job, nil)
+}
+
+// save persists the progress of the job and publishes it to Status.
+func (s *ReencryptionService) save(ctx context.Context, job *model.ReencryptionJob) error {
+	job.UpdatedAt = time.Now().UTC()
+	if job.Total > 0 {
+		job.Progress = float64(job.Scanned) / float64(job.Total)
+	}
+	if err := s.repo.SaveJob(ctx, *job); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	snapshot := *job
+	s.running = &snapshot
+	s.mu.Unlock()
+	return nil
+}
+
+// finish marks the job as failed with the error, or as completed without one, and saves it.
+func (s *ReencryptionService) finish(ctx context.Context, job model.ReencryptionJob, err error) model.ReencryptionJob {
+	if err != nil {
+		job.Status = model.ReencryptionFailed
+		job.Error = err.Error()
+	} else {
+		now := time.Now().UTC()
+		job.Status = model.ReencryptionCompleted
+		job.Progress = 1
+		job.FinishedAt = &now
+	}
+	if saveErr := s.save(ctx, &job); saveErr != nil {
+		log.Printf("Saving re-encryption job %s: %v", job.ID, saveErr)
+	}
+	return job
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"project/model"
+	"project/secrets"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryReencryptionRepository holds a single encrypted value per key.
+type memoryReencryptionRepository struct {
+	mu      sync.Mutex
+	keyring *secrets.Keyring
+	values  map[string]string
+	job     *model.ReencryptionJob
+	// failAt makes Reencrypt fail with a store error for the key
+	failAt string
+}
+
+func (repo *memoryReencryptionRepository) GetJob(_ context.Context) (*model.ReencryptionJob, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.job == nil {
+		return nil, nil
+	}
+	job := *repo.job
+	return &job, nil
+}
+
+func (repo *memoryReencryptionRepository) SaveJob(_ context.Context, job model.ReencryptionJob) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.job = &job
+	return nil
+}
+
+func (repo *memoryReencryptionRepository) Keys(_ context.Context) ([]string, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	keys := make([]string, 0, len(repo.values))
+	for key := range repo.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (repo *memoryReencryptionRepository) Reencrypt(_ context.Context, key string) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if key == repo.failAt {
+		return false, errors.New("store unavailable")
+	}
+	value := repo.values[key]
+	if !repo.keyring.NeedsRewrap(value) {
+		return false, nil
+	}
+	rewrapped, err := repo.keyring.Rewrap(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", model.ErrUnreadableSecret, err)
+	}
+	repo.values[key] = rewrapped
+	return true, nil
+}
+
+func waitForJob(t *testing.T, service *ReencryptionService) model.ReencryptionJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := service.Status(context.Background())
+		assert.NoError(t, err)
+		if job != nil && job.Status != model.ReencryptionRunning {
+			return *job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("re-encryption job didn't finish")
+	return model.ReencryptionJob{}
+}
+
+func TestReencryptionService_Start(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+	lostKey := bytes.Repeat([]byte{3}, 32)
+
+	old, _ := secrets.NewKeyring("old", map[string][]byte{"old": oldKey})
+	lost, _ := secrets.NewKeyring("lost", map[string][]byte{"lost": lostKey})
+	rotated, _ := secrets.NewKeyring("new", map[string][]byte{"old": oldKey, "new": newKey})
+
+	repo := &memoryReencryptionRepository{keyring: rotated, values: map[string]string{}}
+	for i := 0; i < 5; i++ {
+		repo.values[fmt.Sprintf("configs/app/%d", i)], _ = old.Encrypt("s3cr3t")
+	}
+	repo.values["configs/lost/1"], _ = lost.Encrypt("s3cr3t")
+	repo.values["configs/plain/1"] = "not a secret"
+	repo.failAt = "configs/app/3"
+
+	service := NewReencryptionService(repo, rotated)
+
+	// A store error stops the job after the last processed key
+	_, err := service.Start(context.Background())
+	assert.NoError(t, err)
+	job := waitForJob(t, service)
+	assert.Equal(t, model.ReencryptionFailed, job.Status)
+	assert.Equal(t, "configs/app/2", job.Cursor)
+	assert.Equal(t, 3, job.Reencrypted)
+
+	// Starting again resumes after the cursor
+	repo.failAt = ""
+	resumed, err := service.Start(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, job.ID, resumed.ID)
+	job = waitForJob(t, service)
+	assert.Equal(t, model.ReencryptionCompleted, job.Status)
+	assert.Equal(t, 5, job.Reencrypted)
+	assert.Equal(t, 7, job.Scanned)
+	assert.Equal(t, 1.0, job.Progress)
+	assert.Equal(t, []string{"configs/lost/1"}, job.FailedKeys)
+
+	for i := 0; i < 5; i++ {
+		keyID, err := secrets.KeyID(repo.values[fmt.Sprintf("configs/app/%d", i)])
+		assert.NoError(t, err)
+		assert.Equal(t, "new", keyID)
+	}
+
+	// A completed job isn't resumed, a new one is started
+	again, err := service.Start(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, again.Cursor)
+	waitForJob(t, service)
+}
+
+func TestReencryptionService_StartWithoutKeyring(t *testing.T) {
+	service := NewReencryptionService(&memoryReencryptionRepository{}, nil)
+	_, err := service.Start(context.Background())
+	assert.ErrorIs(t, err, ErrNoKeyring)
+}
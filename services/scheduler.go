@@ -0,0 +1,246 @@
+// The `Scheduler` follows the activeFrom and activeUntil windows of configs and group configs: it
+// emits an event when a config becomes active, and deletes a config once it expired. Expired configs of
+// protected groups are kept, those groups only change through change requests, and so are locked
+// configs and the expired configs of locked groups.
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"project/metrics"
+	"project/model"
+	"project/tracing"
+	"sort"
+	"time"
+)
+
+// maxScheduleEvents caps the number of recent events kept in the schedule state.
+const maxScheduleEvents = 100
+
+// maxUpcomingEvents caps the number of upcoming events reported by Status.
+const maxUpcomingEvents = 100
+
+type Scheduler struct {
+	repo    model.ScheduleRepository
+	configs model.ConfigRepository
+	groups  model.ConfigGroupRepository
+	locks   model.LockRepository
+	now     func() time.Time
+}
+
+func NewScheduler(repo model.ScheduleRepository, configs model.ConfigRepository, groups model.ConfigGroupRepository, locks model.LockRepository) *Scheduler {
+	return &Scheduler{
+		repo:    repo,
+		configs: configs,
+		groups:  groups,
+		locks:   locks,
+		now:     time.Now,
+	}
+}
+
+// Run calls Tick every interval until the context is cancelled.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Tick(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "schedule run failed", "error", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scheduled is a config with an activity window, and the group holding it.
+type scheduled struct {
+	config model.Config
+	group  *model.ConfigGroup
+}
+
+func (c scheduled) event(kind model.ScheduleEventKind, at time.Time) model.ScheduleEvent {
+	event := model.ScheduleEvent{Kind: kind, Config: model.Ref{Name: c.config.Name, Version: c.config.Version}.String(), At: at.UTC()}
+	if c.group != nil {
+		event.Group = model.Ref{Name: c.group.Name, Version: c.group.Version}.String()
+	}
+	return event
+}
+
+// Tick handles the configs which became active since the last run and deletes the expired ones. It
+// returns the events it emitted, none if another server claimed the run. The first run ever doesn't
+// report activations which happened before it.
+func (s *Scheduler) Tick(ctx context.Context) ([]model.ScheduleEvent, error) {
+	ctx, span := tracing.Start(ctx, "Scheduler.Tick")
+	defer span.End()
+
+	state, index, err := s.repo.GetState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	since := state.LastRun
+	if since.IsZero() {
+		since = now
+	}
+	// Claim the run before acting on it, a server losing the race leaves it to the winner
+	state.LastRun = now
+	if saved, err := s.repo.SaveState(ctx, state, index); err != nil || !saved {
+		return nil, err
+	}
+
+	configs, err := s.scheduled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var events []model.ScheduleEvent
+	var errs []error
+	for _, c := range configs {
+		if from := c.config.ActiveFrom; from != nil && from.After(since) && !from.After(now) {
+			events = append(events, c.event(model.ScheduleActivated, *from))
+		}
+		if until := c.config.ActiveUntil; until != nil && !until.After(now) {
+			if err := s.writable(ctx, c); err != nil {
+				// Reported once, when the config expires
+				if until.After(since) {
+					slog.WarnContext(ctx, "expired config kept", "config", c.event(model.ScheduleExpired, *until).Config, "group", c.event(model.ScheduleExpired, *until).Group, "reason", err.Error())
+				}
+				continue
+			}
+			if err := s.delete(ctx, c); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			events = append(events, c.event(model.ScheduleExpired, *until))
+		}
+	}
+	for i := range events {
+		events[i].HandledAt = &now
+		emit(ctx, events[i])
+	}
+	if err := s.record(ctx, events); err != nil {
+		errs = append(errs, err)
+	}
+	return events, errors.Join(errs...)
+}
+
+// Status returns the recent events and the activations and expirations to come.
+func (s *Scheduler) Status(ctx context.Context) (model.ScheduleStatus, error) {
+	ctx, span := tracing.Start(ctx, "Scheduler.Status")
+	defer span.End()
+
+	state, _, err := s.repo.GetState(ctx)
+	if err != nil {
+		return model.ScheduleStatus{}, err
+	}
+	configs, err := s.scheduled(ctx)
+	if err != nil {
+		return model.ScheduleStatus{}, err
+	}
+	now := s.now()
+	status := model.ScheduleStatus{LastRun: state.LastRun, Events: state.Events, Upcoming: []model.ScheduleEvent{}}
+	if status.Events == nil {
+		status.Events = []model.ScheduleEvent{}
+	}
+	for _, c := range configs {
+		if from := c.config.ActiveFrom; from != nil && from.After(now) {
+			status.Upcoming = append(status.Upcoming, c.event(model.ScheduleActivated, *from))
+		}
+		if until := c.config.ActiveUntil; until != nil && until.After(now) {
+			status.Upcoming = append(status.Upcoming, c.event(model.ScheduleExpired, *until))
+		}
+	}
+	sort.SliceStable(status.Upcoming, func(i, j int) bool {
+		return status.Upcoming[i].At.Before(status.Upcoming[j].At)
+	})
+	if len(status.Upcoming) > maxUpcomingEvents {
+		status.Upcoming = status.Upcoming[:maxUpcomingEvents]
+	}
+	return status, nil
+}
+
+// scheduled returns the configs and group configs which have an activity window.
+func (s *Scheduler) scheduled(ctx context.Context) ([]scheduled, error) {
+	configs, err := s.configs.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.groups.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result []scheduled
+	for _, config := range configs {
+		if config.ActiveFrom != nil || config.ActiveUntil != nil {
+			result = append(result, scheduled{config: config})
+		}
+	}
+	for i := range groups {
+		for _, config := range groups[i].Configs {
+			if config.ActiveFrom != nil || config.ActiveUntil != nil {
+				result = append(result, scheduled{config: config.Config, group: &groups[i]})
+			}
+		}
+	}
+	return result, nil
+}
+
+// writable returns an error if the expired config may not be deleted by the scheduler.
+func (s *Scheduler) writable(ctx context.Context, c scheduled) error {
+	if c.group == nil {
+		return checkUnlocked(ctx, s.locks, model.KindConfig, c.config.Name, c.config.Version)
+	}
+	if c.group.Protected {
+		return protectedError(c.group.Name, c.group.Version)
+	}
+	return checkUnlocked(ctx, s.locks, model.KindConfigGroup, c.group.Name, c.group.Version)
+}
+
+// delete deletes an expired config, from its group if it is a group config.
+func (s *Scheduler) delete(ctx context.Context, c scheduled) error {
+	var err error
+	if c.group == nil {
+		err = s.configs.Delete(ctx, c.config.Name, c.config.Version)
+	} else {
+		err = s.groups.RemoveConfigFromGroup(ctx, c.group.Name, c.group.Version, c.config.Name, c.config.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("deleting expired config %s: %w", c.event(model.ScheduleExpired, *c.config.ActiveUntil).Config, err)
+	}
+	return nil
+}
+
+// record appends the events to the state, keeping the most recent ones.
+func (s *Scheduler) record(ctx context.Context, events []model.ScheduleEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		state, index, err := s.repo.GetState(ctx)
+		if err != nil {
+			return err
+		}
+		state.Events = append(state.Events, events...)
+		if len(state.Events) > maxScheduleEvents {
+			state.Events = state.Events[len(state.Events)-maxScheduleEvents:]
+		}
+		saved, err := s.repo.SaveState(ctx, state, index)
+		if err != nil || saved {
+			return err
+		}
+	}
+	return errors.New("schedule state was modified concurrently, events not recorded")
+}
+
+// emit logs and counts an event.
+func emit(ctx context.Context, event model.ScheduleEvent) {
+	metrics.ScheduleEvents.WithLabelValues(string(event.Kind)).Inc()
+	attrs := []any{"kind", event.Kind, "config", event.Config, "at", event.At}
+	if event.Group != "" {
+		attrs = append(attrs, "group", event.Group)
+	}
+	slog.InfoContext(ctx, "config "+string(event.Kind), attrs...)
+}
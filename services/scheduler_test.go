@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"project/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryScheduleRepository versions the state like the modify index of a Consul key.
+type memoryScheduleRepository struct {
+	state model.ScheduleState
+	index uint64
+}
+
+func (repo *memoryScheduleRepository) GetState(_ context.Context) (model.ScheduleState, uint64, error) {
+	state := repo.state
+	state.Events = append([]model.ScheduleEvent(nil), repo.state.Events...)
+	return state, repo.index, nil
+}
+
+func (repo *memoryScheduleRepository) SaveState(_ context.Context, state model.ScheduleState, index uint64) (bool, error) {
+	if index != repo.index {
+		return false, nil
+	}
+	repo.state = state
+	repo.index++
+	return true, nil
+}
+
+// memoryConfigGroupRepository implements the group methods used by the scheduler.
+type memoryConfigGroupRepository struct {
+	model.ConfigGroupRepository
+	groups []model.ConfigGroup
+}
+
+func (repo *memoryConfigGroupRepository) List(_ context.Context) ([]model.ConfigGroup, error) {
+	return repo.groups, nil
+}
+
+func (repo *memoryConfigGroupRepository) RemoveConfigFromGroup(_ context.Context, groupName string, version string, configName string, configVersion string) error {
+	for i, group := range repo.groups {
+		if group.Name != groupName || group.Version != version {
+			continue
+		}
+		for j, config := range group.Configs {
+			if config.Name == configName && config.Version == configVersion {
+				repo.groups[i].Configs = append(group.Configs[:j:j], group.Configs[j+1:]...)
+				return nil
+			}
+		}
+	}
+	return errors.New("config not found in the group")
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 3, 1, 22, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := start.Add(d)
+		return &t
+	}
+	configs := memoryConfigRepository{}
+	configs.Add(ctx, model.Config{Name: "stable", Version: "1.0"})
+	configs.Add(ctx, model.Config{Name: "staged", Version: "2.0", ActiveFrom: at(time.Minute)})
+	configs.Add(ctx, model.Config{Name: "override", Version: "1.0", ActiveUntil: at(2 * time.Minute)})
+	configs.Add(ctx, model.Config{Name: "pinned", Version: "1.0", ActiveUntil: at(150 * time.Second)})
+	locks := memoryLockRepository{}
+	locks.Lock(ctx, model.Lock{Kind: model.KindConfig, Name: "pinned", Version: "1.0", Owner: "alice", Reason: "audit"})
+	groups := &memoryConfigGroupRepository{groups: []model.ConfigGroup{{Name: "payments", Version: "1.0", Configs: []*model.ConfigWithLabels{
+		{Config: model.Config{Name: "db", Version: "1.0"}},
+		{Config: model.Config{Name: "db", Version: "1.1", ActiveUntil: at(90 * time.Second)}},
+	}}, {Name: "ledger", Version: "1.0", Protected: true, Configs: []*model.ConfigWithLabels{
+		{Config: model.Config{Name: "db", Version: "1.1", ActiveUntil: at(90 * time.Second)}},
+	}}}}
+	repo := &memoryScheduleRepository{}
+	scheduler := NewScheduler(repo, configs, groups, locks)
+	scheduler.now = func() time.Time { return start }
+	service := NewConfigService(configs, nil)
+	service.now = scheduler.now
+
+	// The first run only starts following the windows
+	events, err := scheduler.Tick(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	status, err := scheduler.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, start, status.LastRun)
+	assert.Equal(t, []model.ScheduleEvent{
+		{Kind: model.ScheduleActivated, Config: "staged@2.0", At: *at(time.Minute)},
+		{Kind: model.ScheduleExpired, Config: "db@1.1", Group: "payments@1.0", At: *at(90 * time.Second)},
+		{Kind: model.ScheduleExpired, Config: "db@1.1", Group: "ledger@1.0", At: *at(90 * time.Second)},
+		{Kind: model.ScheduleExpired, Config: "override@1.0", At: *at(2 * time.Minute)},
+		{Kind: model.ScheduleExpired, Config: "pinned@1.0", At: *at(150 * time.Second)},
+	}, status.Upcoming)
+
+	// A staged config can't be read before it is active
+	_, err = service.Get(ctx, "staged", "2.0")
+	assert.ErrorIs(t, err, model.ErrNotActive)
+	_, err = service.GetRaw(ctx, "staged", "2.0")
+	assert.NoError(t, err)
+
+	scheduler.now = func() time.Time { return start.Add(3 * time.Minute) }
+	service.now = scheduler.now
+	events, err = scheduler.Tick(ctx)
+	assert.NoError(t, err)
+	kinds := map[string]model.ScheduleEventKind{}
+	for _, event := range events {
+		kinds[event.Group+"/"+event.Config] = event.Kind
+	}
+	assert.Equal(t, map[string]model.ScheduleEventKind{
+		"/staged@2.0":         model.ScheduleActivated,
+		"/override@1.0":       model.ScheduleExpired,
+		"payments@1.0/db@1.1": model.ScheduleExpired,
+	}, kinds)
+	_, err = service.Get(ctx, "staged", "2.0")
+	assert.NoError(t, err)
+	_, err = configs.Get(ctx, "override", "1.0")
+	assert.Error(t, err)
+	assert.Len(t, groups.groups[0].Configs, 1)
+	// The expired config of the protected group and the locked config are kept
+	assert.Len(t, groups.groups[1].Configs, 1)
+	_, err = configs.Get(ctx, "pinned", "1.0")
+	assert.NoError(t, err)
+	assert.Len(t, repo.state.Events, 3)
+
+	// Events are handled once
+	events, err = scheduler.Tick(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	// A run claimed by another server in the meantime is skipped
+	configs.Add(ctx, model.Config{Name: "later", Version: "1.0", ActiveFrom: at(4 * time.Minute)})
+	scheduler = NewScheduler(&staleScheduleRepository{memoryScheduleRepository: repo}, configs, groups, locks)
+	scheduler.now = func() time.Time { return start.Add(5 * time.Minute) }
+	events, err = scheduler.Tick(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+// staleScheduleRepository loses the race for every run, as if another server saved the state first.
+type staleScheduleRepository struct {
+	*memoryScheduleRepository
+}
+
+func (repo *staleScheduleRepository) SaveState(ctx context.Context, state model.ScheduleState, index uint64) (bool, error) {
+	return repo.memoryScheduleRepository.SaveState(ctx, state, index+1)
+}
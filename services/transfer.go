@@ -0,0 +1,354 @@
+// The `TransferService` struct exports every configuration and configuration group to a tar.gz
+// archive of JSON files and imports such an archive back, e.g. to migrate between environments.
+package services
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"project/model"
+	"project/secrets"
+	"project/tracing"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrImportConflict is returned by Import with the fail strategy when an object already exists.
+	ErrImportConflict = errors.New("import conflicts with existing objects")
+	// ErrInvalidArchive is returned by Import when the archive or the strategy can't be used.
+	ErrInvalidArchive = errors.New("invalid import")
+)
+
+const exportManifestFile = "manifest.json"
+
+type TransferService struct {
+	configRepo model.ConfigRepository
+	groupRepo  model.ConfigGroupRepository
+	locks      model.LockRepository
+	applyRepo  model.ApplyRepository
+	keyring    *secrets.Keyring
+}
+
+// NewTransferService creates the service, using the keyring to keep secret params encrypted inside
+// export archives.
+func NewTransferService(configRepo model.ConfigRepository, groupRepo model.ConfigGroupRepository, locks model.LockRepository, applyRepo model.ApplyRepository, keyring *secrets.Keyring) TransferService {
+	return TransferService{
+		configRepo: configRepo,
+		groupRepo:  groupRepo,
+		locks:      locks,
+		applyRepo:  applyRepo,
+		keyring:    keyring,
+	}
+}
+
+// Export writes every config and config group to w as a tar.gz archive holding a manifest.json file,
+// one configs/{name}@{version}.json file per config and one groups/{name}@{version}.json per group.
+// Secret params are written encrypted, so the archive can only be imported where the keyring is known.
+func (s TransferService) Export(ctx context.Context, w io.Writer) error {
+	ctx, span := tracing.Start(ctx, "TransferService.Export")
+	defer span.End()
+
+	configs, err := s.configRepo.List(ctx)
+	if err != nil {
+		return err
+	}
+	groups, err := s.groupRepo.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+
+	manifest := model.ExportManifest{
+		FormatVersion: model.ExportFormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		Configs:       len(configs),
+		Groups:        len(groups),
+	}
+	if err := writeJSONFile(archive, exportManifestFile, manifest); err != nil {
+		return err
+	}
+	for _, config := range configs {
+		config.Params, err = s.keyring.EncryptParams(config.Params, config.Secrets)
+		if err != nil {
+			return err
+		}
+		if err := writeJSONFile(archive, archivePath("configs", config.Name, config.Version), config); err != nil {
+			return err
+		}
+	}
+	for _, group := range groups {
+		for _, config := range group.Configs {
+			config.Params, err = s.keyring.EncryptParams(config.Params, config.Secrets)
+			if err != nil {
+				return err
+			}
+		}
+		if err := writeJSONFile(archive, archivePath("groups", group.Name, group.Version), group); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Import reads an archive written by Export and adds its objects to the store. Objects which already
+// exist are skipped, overwritten, or make the whole import fail before anything is written,
+// depending on the strategy. Protected groups are never overwritten, they change through change
+// requests only, and neither are locked configs and groups.
+func (s TransferService) Import(ctx context.Context, r io.Reader, strategy model.ImportStrategy) (model.ImportReport, error) {
+	ctx, span := tracing.Start(ctx, "TransferService.Import")
+	defer span.End()
+
+	switch strategy {
+	case model.ImportSkip, model.ImportOverwrite, model.ImportFail:
+	default:
+		return model.ImportReport{}, fmt.Errorf("%w: unknown import strategy %q. Expected skip, overwrite or fail", ErrInvalidArchive, strategy)
+	}
+
+	configs, groups, err := readArchive(r)
+	if err != nil {
+		return model.ImportReport{}, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	// Every object is checked before the first one is written
+	for _, config := range configs {
+		if err := s.checkConfig(config); err != nil {
+			return model.ImportReport{}, fmt.Errorf("%w: config %s: %w", ErrInvalidArchive, model.Ref{Name: config.Name, Version: config.Version}, err)
+		}
+	}
+	for _, group := range groups {
+		if err := s.checkGroup(group); err != nil {
+			return model.ImportReport{}, fmt.Errorf("%w: configGroup %s: %w", ErrInvalidArchive, model.Ref{Name: group.Name, Version: group.Version}, err)
+		}
+	}
+
+	// The indexes are read first, so a write between them and the lists fails the import of the object
+	indexes, err := s.applyRepo.Indexes(ctx)
+	if err != nil {
+		return model.ImportReport{}, err
+	}
+	existingConfigs, err := s.configRepo.List(ctx)
+	if err != nil {
+		return model.ImportReport{}, err
+	}
+	existingGroups, err := s.groupRepo.List(ctx)
+	if err != nil {
+		return model.ImportReport{}, err
+	}
+	exists := make(map[string]bool)
+	protected := make(map[string]bool)
+	for _, config := range existingConfigs {
+		exists[model.ObjectID(model.KindConfig, config.Name, config.Version)] = true
+	}
+	for _, group := range existingGroups {
+		id := model.ObjectID(model.KindConfigGroup, group.Name, group.Version)
+		exists[id] = true
+		protected[id] = group.Protected
+	}
+
+	var steps []model.PlanStep
+	for i := range configs {
+		config := &configs[i]
+		steps = append(steps, model.PlanStep{Kind: model.KindConfig, Name: config.Name, Version: config.Version, Config: config})
+	}
+	for i := range groups {
+		group := &groups[i]
+		steps = append(steps, model.PlanStep{Kind: model.KindConfigGroup, Name: group.Name, Version: group.Version, Group: group})
+	}
+
+	report := model.ImportReport{Strategy: strategy, Created: []string{}, Overwritten: []string{}, Skipped: []string{}, Refused: []string{}}
+	var conflicts []string
+	for i := range steps {
+		step := &steps[i]
+		id := model.ObjectID(step.Kind, step.Name, step.Version)
+		step.Action = model.PlanCreate
+		if exists[id] {
+			// Overwritten objects can be restored from the trash
+			step.Action = model.PlanUpdate
+			step.TrashReplaced = true
+			step.Index = indexes[id]
+			step.RequireUnlocked = true
+			conflicts = append(conflicts, id)
+		}
+	}
+	if strategy == model.ImportFail && len(conflicts) > 0 {
+		return model.ImportReport{}, fmt.Errorf("%w: %s", ErrImportConflict, strings.Join(conflicts, ", "))
+	}
+
+	// Every object is written in its own transaction, so a large archive doesn't hit the
+	// transaction size limit of the store, and a single object too large for one is refused
+	for _, step := range steps {
+		id := model.ObjectID(step.Kind, step.Name, step.Version)
+		if step.Action == model.PlanUpdate && strategy == model.ImportSkip {
+			report.Skipped = append(report.Skipped, id)
+			continue
+		}
+		if step.Action == model.PlanUpdate {
+			if protected[id] {
+				report.Refused = append(report.Refused, id)
+				continue
+			}
+			err := checkUnlocked(ctx, s.locks, step.Kind, step.Name, step.Version)
+			if errors.Is(err, model.ErrLocked) {
+				report.Refused = append(report.Refused, id)
+				continue
+			}
+			if err != nil {
+				return report, err
+			}
+		}
+		err := s.applyRepo.Apply(ctx, model.ApplyPlan{Steps: []model.PlanStep{step}})
+		if errors.Is(err, model.ErrLocked) {
+			// Locked since the check above
+			report.Refused = append(report.Refused, id)
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("importing %s: %w", id, err)
+		}
+		if step.Action == model.PlanUpdate {
+			report.Overwritten = append(report.Overwritten, id)
+		} else {
+			report.Created = append(report.Created, id)
+		}
+	}
+	return report, nil
+}
+
+// checkConfig validates a config of an archive as it will be stored, with its secret params decrypted.
+// Params encrypted with a key missing from the keyring fail here, instead of being stored unreadable.
+func (s TransferService) checkConfig(config model.Config) error {
+	params, err := s.keyring.DecryptParams(config.Params, config.Secrets)
+	if err != nil {
+		return err
+	}
+	config.Params = params
+	return config.Validate()
+}
+
+// checkGroup validates a group of an archive and its configs like checkConfig.
+func (s TransferService) checkGroup(group model.ConfigGroup) error {
+	configs := make([]*model.ConfigWithLabels, 0, len(group.Configs))
+	for _, config := range group.Configs {
+		if config == nil {
+			// Validate refuses it
+			configs = append(configs, nil)
+			continue
+		}
+		params, err := s.keyring.DecryptParams(config.Params, config.Secrets)
+		if err != nil {
+			return fmt.Errorf("config %s@%s: %w", config.Name, config.Version, err)
+		}
+		opened := *config
+		opened.Params = params
+		configs = append(configs, &opened)
+	}
+	group.Configs = configs
+	return group.Validate()
+}
+
+// readArchive decodes the objects of an archive, refusing archives holding an object more than once.
+func readArchive(r io.Reader) ([]model.Config, []model.ConfigGroup, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer gz.Close()
+
+	var (
+		manifest *model.ExportManifest
+		configs  []model.Config
+		groups   []model.ConfigGroup
+		seen     = make(map[string]bool)
+	)
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		decoder := json.NewDecoder(archive)
+		switch dir := path.Dir(header.Name); {
+		case header.Name == exportManifestFile:
+			manifest = &model.ExportManifest{}
+			err = decoder.Decode(manifest)
+		case dir == "configs":
+			var config model.Config
+			err = decoder.Decode(&config)
+			if err == nil {
+				err = once(seen, model.ObjectID(model.KindConfig, config.Name, config.Version))
+			}
+			configs = append(configs, config)
+		case dir == "groups":
+			var group model.ConfigGroup
+			err = decoder.Decode(&group)
+			if err == nil {
+				err = once(seen, model.ObjectID(model.KindConfigGroup, group.Name, group.Version))
+			}
+			groups = append(groups, group)
+		default:
+			err = fmt.Errorf("unexpected file in archive")
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", header.Name, err)
+		}
+	}
+
+	if manifest == nil {
+		return nil, nil, errors.New("archive has no " + exportManifestFile)
+	}
+	if manifest.FormatVersion != model.ExportFormatVersion {
+		return nil, nil, fmt.Errorf("unsupported export format version %d, expected %d", manifest.FormatVersion, model.ExportFormatVersion)
+	}
+	return configs, groups, nil
+}
+
+// once records the object as read, failing if it already was.
+func once(seen map[string]bool, id string) error {
+	if seen[id] {
+		return fmt.Errorf("%s is in the archive more than once", id)
+	}
+	seen[id] = true
+	return nil
+}
+
+func writeJSONFile(archive *tar.Writer, name string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = archive.Write(content)
+	return err
+}
+
+// archivePath returns the file name of an object, escaping names which contain a slash.
+func archivePath(dir string, name string, version string) string {
+	return dir + "/" + url.PathEscape(model.Ref{Name: name, Version: version}.String()) + ".json"
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"project/model"
+	"project/secrets"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferService_Import(t *testing.T) {
+	ctx := context.Background()
+	keyring, _ := secrets.NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	other, _ := secrets.NewKeyring("k2", map[string][]byte{"k2": bytes.Repeat([]byte{2}, 32)})
+
+	// The archive is exported from another store
+	source := NewTransferService(
+		memoryConfigRepository{
+			{Name: "db", Version: "1.0"}: {Name: "db", Version: "1.0", Params: map[string]string{"host": "new", "password": "s3cr3t"}, Secrets: []string{"password"}},
+		},
+		&memoryConfigGroupRepository{groups: []model.ConfigGroup{{Name: "payments", Version: "1.0", Configs: []*model.ConfigWithLabels{
+			{Config: model.Config{Name: "svc", Version: "1.0", Params: map[string]string{"a": "1"}}, Labels: []model.Label{{Key: "env", Value: "prod"}}},
+		}}}},
+		memoryLockRepository{}, nil, keyring,
+	)
+	var archive bytes.Buffer
+	require.NoError(t, source.Export(ctx, &archive))
+
+	configs := memoryConfigRepository{
+		{Name: "db", Version: "1.0"}: {Name: "db", Version: "1.0", Params: map[string]string{"host": "old"}},
+	}
+	groups := &memoryConfigGroupRepository{}
+	applyRepo := &memoryApplyRepository{configs: configs, groups: groups}
+	service := NewTransferService(configs, groups, memoryLockRepository{}, applyRepo, keyring)
+
+	// Fail writes nothing when an object exists
+	_, err := service.Import(ctx, bytes.NewReader(archive.Bytes()), model.ImportFail)
+	assert.ErrorIs(t, err, ErrImportConflict)
+	assert.Equal(t, 0, applyRepo.applied)
+
+	// Skip keeps the existing objects and creates the others
+	report, err := service.Import(ctx, bytes.NewReader(archive.Bytes()), model.ImportSkip)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Config/db@1.0"}, report.Skipped)
+	assert.Equal(t, []string{"ConfigGroup/payments@1.0"}, report.Created)
+	assert.Equal(t, "old", configs[model.Ref{Name: "db", Version: "1.0"}].Params["host"])
+	require.Len(t, groups.groups, 1)
+
+	// Overwrite replaces them
+	report, err = service.Import(ctx, bytes.NewReader(archive.Bytes()), model.ImportOverwrite)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Config/db@1.0", "ConfigGroup/payments@1.0"}, report.Overwritten)
+	assert.Empty(t, report.Created)
+	assert.Equal(t, "new", configs[model.Ref{Name: "db", Version: "1.0"}].Params["host"])
+
+	// Secret params encrypted with a key the store doesn't know are refused before anything is written
+	applied := applyRepo.applied
+	foreign := NewTransferService(configs, groups, memoryLockRepository{}, applyRepo, other)
+	_, err = foreign.Import(ctx, bytes.NewReader(archive.Bytes()), model.ImportOverwrite)
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+	assert.Equal(t, applied, applyRepo.applied)
+
+	// So are archives holding an object twice, or an invalid one
+	duplicated := testArchive(t, model.Config{Name: "a", Version: "1.0"}, model.Config{Name: "a", Version: "1.0"})
+	_, err = service.Import(ctx, duplicated, model.ImportOverwrite)
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+	invalid := testArchive(t, model.Config{Name: "a", Version: "1.0"}, model.Config{Name: "b c", Version: "1.0"})
+	_, err = service.Import(ctx, invalid, model.ImportOverwrite)
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+	assert.Equal(t, applied, applyRepo.applied)
+	assert.NotContains(t, configs, model.Ref{Name: "a", Version: "1.0"})
+}
+
+// testArchive writes an archive holding the configs, each in a file of its own.
+func testArchive(t *testing.T, configs ...model.Config) *bytes.Buffer {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	require.NoError(t, writeJSONFile(archive, exportManifestFile, model.ExportManifest{FormatVersion: model.ExportFormatVersion, Configs: len(configs)}))
+	for i, config := range configs {
+		require.NoError(t, writeJSONFile(archive, "configs/"+string(rune('a'+i))+".json", config))
+	}
+	require.NoError(t, archive.Close())
+	require.NoError(t, gz.Close())
+	return &buf
+}
@@ -0,0 +1,108 @@
+// The `TrashService` struct lists the configs and config groups in the trash, restores them, and purges
+// the entries older than the retention period. Every server runs the purger, purging an entry twice is
+// harmless.
+package services
+
+import (
+	"context"
+	"log/slog"
+	"project/model"
+	"project/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type TrashService struct {
+	repo model.TrashRepository
+	// retention is how long entries are kept before they are purged
+	retention time.Duration
+	now       func() time.Time
+}
+
+func NewTrashService(repo model.TrashRepository, retention time.Duration) TrashService {
+	return TrashService{
+		repo:      repo,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// List returns the entries oldest first, with the time each one is purged at.
+func (s TrashService) List(ctx context.Context) ([]model.TrashEntry, error) {
+	ctx, span := tracing.Start(ctx, "TrashService.List")
+	defer span.End()
+
+	entries, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i] = s.withExpiry(entries[i])
+	}
+	return entries, nil
+}
+
+// Restore brings the deleted config or group back, unless one with the same name and version was
+// created since.
+func (s TrashService) Restore(ctx context.Context, id string) (model.TrashEntry, error) {
+	ctx, span := tracing.Start(ctx, "TrashService.Restore", attribute.String("id", id))
+	defer span.End()
+
+	entry, err := s.repo.Restore(ctx, id)
+	if err != nil {
+		return model.TrashEntry{}, err
+	}
+	return s.withExpiry(entry), nil
+}
+
+// Purge removes the entries whose retention period passed and returns them.
+func (s TrashService) Purge(ctx context.Context) ([]model.TrashEntry, error) {
+	ctx, span := tracing.Start(ctx, "TrashService.Purge")
+	defer span.End()
+
+	entries, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	var purged []model.TrashEntry
+	for _, entry := range entries {
+		entry = s.withExpiry(entry)
+		if entry.ExpiresAt.After(now) {
+			// Entries are listed oldest first
+			break
+		}
+		if err := s.repo.Purge(ctx, entry.ID); err != nil {
+			return purged, err
+		}
+		purged = append(purged, entry)
+	}
+	return purged, nil
+}
+
+// Run purges the expired entries every interval until the context is cancelled.
+func (s TrashService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := s.Purge(ctx)
+		for _, entry := range purged {
+			slog.InfoContext(ctx, "trash entry purged", "id", entry.ID, "kind", entry.Kind, "name", entry.Name, "version", entry.Version)
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "trash purge failed", "error", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s TrashService) withExpiry(entry model.TrashEntry) model.TrashEntry {
+	expiresAt := entry.DeletedAt.Add(s.retention).UTC()
+	entry.ExpiresAt = &expiresAt
+	return entry
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"project/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryTrashRepository keeps the entries oldest first, like the IDs of stored entries sort.
+type memoryTrashRepository struct {
+	entries []model.TrashEntry
+}
+
+func (repo *memoryTrashRepository) List(_ context.Context) ([]model.TrashEntry, error) {
+	return append([]model.TrashEntry(nil), repo.entries...), nil
+}
+
+func (repo *memoryTrashRepository) Restore(ctx context.Context, id string) (model.TrashEntry, error) {
+	for _, entry := range repo.entries {
+		if entry.ID == id {
+			return entry, repo.Purge(ctx, id)
+		}
+	}
+	return model.TrashEntry{}, model.ErrTrashEntryNotFound
+}
+
+func (repo *memoryTrashRepository) Purge(_ context.Context, id string) error {
+	for i, entry := range repo.entries {
+		if entry.ID == id {
+			repo.entries = append(repo.entries[:i:i], repo.entries[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func TestTrashService(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	repo := &memoryTrashRepository{entries: []model.TrashEntry{
+		{ID: "1", Kind: model.KindConfigGroup, Name: "payments", Version: "2.0", DeletedAt: now.Add(-8 * 24 * time.Hour)},
+		{ID: "2", Kind: model.KindConfig, Name: "db", Version: "1.0", DeletedAt: now.Add(-7 * 24 * time.Hour)},
+		{ID: "3", Kind: model.KindConfig, Name: "cache", Version: "1.0", DeletedAt: now.Add(-time.Hour)},
+	}}
+	service := NewTrashService(repo, 7*24*time.Hour)
+	service.now = func() time.Time { return now }
+
+	entries, err := service.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, now.Add(-24*time.Hour), *entries[0].ExpiresAt)
+
+	// Entries are purged once their retention period passed, the rest can still be restored
+	purged, err := service.Purge(ctx)
+	require.NoError(t, err)
+	require.Len(t, purged, 2)
+	assert.Equal(t, "1", purged[0].ID)
+	assert.Equal(t, "2", purged[1].ID)
+	_, err = service.Restore(ctx, "1")
+	assert.ErrorIs(t, err, model.ErrTrashEntryNotFound)
+	restored, err := service.Restore(ctx, "3")
+	require.NoError(t, err)
+	assert.Equal(t, "cache", restored.Name)
+	assert.Empty(t, repo.entries)
+}
@@ -0,0 +1,235 @@
+package settings
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// option is a setting which can be overridden by an environment variable and a flag of the same
+// meaning. Settings without an option, e.g. the per-route rate-limit policies, are only read from the
+// file.
+type option struct {
+	flag  string
+	env   string
+	usage string
+	set   func(s *Settings, value string) error
+}
+
+var options = []option{
+	{"addr", "CONFIG_ADDR", "address the server listens on", stringSetter(func(s *Settings) *string { return &s.Server.Address })},
+	{"read-header-timeout", "CONFIG_READ_HEADER_TIMEOUT", "time allowed to read the request headers", durationSetter(func(s *Settings) *time.Duration { return &s.Server.ReadHeaderTimeout })},
+	{"read-timeout", "CONFIG_READ_TIMEOUT", "time allowed to read the whole request", durationSetter(func(s *Settings) *time.Duration { return &s.Server.ReadTimeout })},
+	{"write-timeout", "CONFIG_WRITE_TIMEOUT", "time allowed to write the response", durationSetter(func(s *Settings) *time.Duration { return &s.Server.WriteTimeout })},
+	{"idle-timeout", "CONFIG_IDLE_TIMEOUT", "time a keep-alive connection is kept open", durationSetter(func(s *Settings) *time.Duration { return &s.Server.IdleTimeout })},
+	{"shutdown-delay", "CONFIG_SHUTDOWN_DELAY", "time the server reports not ready before it stops accepting requests", durationSetter(func(s *Settings) *time.Duration { return &s.Server.ShutdownDelay })},
+	{"shutdown-timeout", "CONFIG_SHUTDOWN_TIMEOUT", "time in-flight requests are given on shutdown", durationSetter(func(s *Settings) *time.Duration { return &s.Server.ShutdownTimeout })},
+	{"max-body-bytes", "CONFIG_MAX_BODY_BYTES", "largest request body accepted by the routes without a policy of their own", routeSetter(func(l *RouteLimits, value string) error {
+		maxBodyBytes, err := strconv.ParseInt(value, 10, 64)
+		l.MaxBodyBytes = maxBodyBytes
+		return err
+	})},
+	{"request-timeout", "CONFIG_REQUEST_TIMEOUT", "time a handler is given by the routes without a policy of their own, 0 for no limit", routeSetter(func(l *RouteLimits, value string) error {
+		timeout, err := time.ParseDuration(value)
+		l.Timeout = timeout
+		return err
+	})},
+	{"tls-cert", "CONFIG_TLS_CERT", "certificate file, enables HTTPS together with --tls-key", stringSetter(func(s *Settings) *string { return &s.TLS.CertFile })},
+	{"tls-key", "CONFIG_TLS_KEY", "private key file of the certificate", stringSetter(func(s *Settings) *string { return &s.TLS.KeyFile })},
+	{"tls-client-ca", "CONFIG_TLS_CLIENT_CA", "CA file client certificates are verified against", stringSetter(func(s *Settings) *string { return &s.TLS.ClientCAFile })},
+	{"tls-client-auth", "CONFIG_TLS_CLIENT_AUTH", "client certificate verification: none, optional or require", stringSetter(func(s *Settings) *string { return &s.TLS.ClientAuth })},
+	{"tls-reload-interval", "CONFIG_TLS_RELOAD_INTERVAL", "how often certificate files are checked for changes, 0 disables the reload", durationSetter(func(s *Settings) *time.Duration { return &s.TLS.ReloadInterval })},
+	{"storage", "CONFIG_STORAGE_BACKEND", "storage backend", stringSetter(func(s *Settings) *string { return &s.Storage.Backend })},
+	{"consul-addr", "CONFIG_CONSUL_ADDR", "address of the Consul agent", stringSetter(func(s *Settings) *string { return &s.Storage.Consul.Address })},
+	{"consul-datacenter", "CONFIG_CONSUL_DATACENTER", "Consul datacenter", stringSetter(func(s *Settings) *string { return &s.Storage.Consul.Datacenter })},
+	{"consul-token", "CONFIG_CONSUL_TOKEN", "Consul ACL token", stringSetter(func(s *Settings) *string { return &s.Storage.Consul.Token })},
+	{"consul-read-timeout", "CONFIG_CONSUL_READ_TIMEOUT", "time allowed for a read from Consul, 0 for no limit", durationSetter(func(s *Settings) *time.Duration { return &s.Storage.Consul.ReadTimeout })},
+	{"consul-write-timeout", "CONFIG_CONSUL_WRITE_TIMEOUT", "time allowed for a write to Consul, 0 for no limit", durationSetter(func(s *Settings) *time.Duration { return &s.Storage.Consul.WriteTimeout })},
+	{"migrations", "CONFIG_MIGRATIONS", "migrations of the keyspace at startup: apply, dry-run or off", stringSetter(func(s *Settings) *string { return &s.Storage.Migrations })},
+	{"cache", "CONFIG_CACHE", "keep configs and groups in memory, kept up to date by Consul watches", boolSetter(func(s *Settings) *bool { return &s.Storage.Cache.Enabled })},
+	{"cache-max-stale", "CONFIG_CACHE_MAX_STALE", "time the cache is served after the last answer from Consul", durationSetter(func(s *Settings) *time.Duration { return &s.Storage.Cache.MaxStale })},
+	{"cache-wait-time", "CONFIG_CACHE_WAIT_TIME", "longest wait of the Consul watches of the cache", durationSetter(func(s *Settings) *time.Duration { return &s.Storage.Cache.WaitTime })},
+	{"rate-limit", "CONFIG_RATE_LIMIT", "enable rate limiting", boolSetter(func(s *Settings) *bool { return &s.RateLimit.Enabled })},
+	{"rate-limit-rps", "CONFIG_RATE_LIMIT_RPS", "requests per second of the default rate-limit policy", policySetter(func(p *RateLimitPolicy, value string) error {
+		rps, err := strconv.ParseFloat(value, 64)
+		p.RequestsPerSecond = rps
+		return err
+	})},
+	{"rate-limit-burst", "CONFIG_RATE_LIMIT_BURST", "burst of the default rate-limit policy", policySetter(func(p *RateLimitPolicy, value string) error {
+		burst, err := strconv.Atoi(value)
+		p.Burst = burst
+		return err
+	})},
+	{"log-level", "CONFIG_LOG_LEVEL", "log level: debug, info, warn or error", stringSetter(func(s *Settings) *string { return &s.Log.Level })},
+	{"log-format", "CONFIG_LOG_FORMAT", "log format: json or text", stringSetter(func(s *Settings) *string { return &s.Log.Format })},
+	{"trace-exporter", "CONFIG_TRACE_EXPORTER", "trace exporter: none, stdout or otlp", stringSetter(func(s *Settings) *string { return &s.Tracing.Exporter })},
+	{"trace-endpoint", "CONFIG_TRACE_ENDPOINT", "host:port of the OTLP HTTP collector", stringSetter(func(s *Settings) *string { return &s.Tracing.Endpoint })},
+	{"trace-insecure", "CONFIG_TRACE_INSECURE", "send spans to the collector over plain HTTP", boolSetter(func(s *Settings) *bool { return &s.Tracing.Insecure })},
+	{"trace-sample-ratio", "CONFIG_TRACE_SAMPLE_RATIO", "share of new traces which are recorded", floatSetter(func(s *Settings) *float64 { return &s.Tracing.SampleRatio })},
+	{"tokens-file", "CONFIG_TOKENS_FILE", "file with the API tokens identifying callers", stringSetter(func(s *Settings) *string { return &s.Auth.TokensFile })},
+	{"keyring-file", "CONFIG_KEYRING_FILE", "file with the keys encrypting secret params", stringSetter(func(s *Settings) *string { return &s.Secrets.KeyringFile })},
+	{"schedule-interval", "CONFIG_SCHEDULE_INTERVAL", "how often configs are activated and expired ones deleted, 0 to disable", durationSetter(func(s *Settings) *time.Duration { return &s.Scheduler.Interval })},
+	{"change-request-approvals", "CONFIG_CHANGE_REQUEST_APPROVALS", "approvals of reviewers a change request needs before it is applied", intSetter(func(s *Settings) *int { return &s.ChangeRequests.Approvals })},
+	{"trash-retention", "CONFIG_TRASH_RETENTION", "how long deleted configs and groups can be restored", durationSetter(func(s *Settings) *time.Duration { return &s.Trash.Retention })},
+	{"trash-purge-interval", "CONFIG_TRASH_PURGE_INTERVAL", "how often expired trash entries are purged, 0 to disable", durationSetter(func(s *Settings) *time.Duration { return &s.Trash.PurgeInterval })},
+}
+
+// Load builds the settings from the defaults, the YAML file named by --config or CONFIG_FILE, the
+// environment and the flags in args, and validates them. getenv is os.Getenv outside of tests.
+func Load(args []string, getenv func(string) string, output io.Writer) (Settings, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(output)
+	file := fs.String("config", getenv("CONFIG_FILE"), "YAML settings file (env CONFIG_FILE)")
+	flagValues := map[string]string{}
+	for _, opt := range options {
+		name := opt.flag
+		fs.Func(name, fmt.Sprintf("%s (env %s)", opt.usage, opt.env), func(value string) error {
+			flagValues[name] = value
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return Settings{}, err
+	}
+	if fs.NArg() > 0 {
+		return Settings{}, fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	s := Default()
+	if *file != "" {
+		if err := s.readFile(*file); err != nil {
+			return Settings{}, err
+		}
+	}
+
+	var errs []error
+	for _, opt := range options {
+		if value := getenv(opt.env); value != "" {
+			if err := opt.set(&s, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", opt.env, err))
+			}
+		}
+	}
+	for _, opt := range options {
+		if value, ok := flagValues[opt.flag]; ok {
+			if err := opt.set(&s, value); err != nil {
+				errs = append(errs, fmt.Errorf("--%s: %w", opt.flag, err))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Settings{}, err
+	}
+
+	if err := s.Validate(); err != nil {
+		return Settings{}, fmt.Errorf("invalid settings:\n%w", err)
+	}
+	return s, nil
+}
+
+// readFile overrides the settings with the ones present in the YAML file. Unknown fields are an error,
+// so typos don't silently fall back to the defaults.
+func (s *Settings) readFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(s); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("settings file %s: %w", path, err)
+	}
+	return nil
+}
+
+func stringSetter(field func(s *Settings) *string) func(s *Settings, value string) error {
+	return func(s *Settings, value string) error {
+		*field(s) = value
+		return nil
+	}
+}
+
+func durationSetter(field func(s *Settings) *time.Duration) func(s *Settings, value string) error {
+	return func(s *Settings, value string) error {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(s) = duration
+		return nil
+	}
+}
+
+func boolSetter(field func(s *Settings) *bool) func(s *Settings, value string) error {
+	return func(s *Settings, value string) error {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(s) = enabled
+		return nil
+	}
+}
+
+func intSetter(field func(s *Settings) *int) func(s *Settings, value string) error {
+	return func(s *Settings, value string) error {
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(s) = number
+		return nil
+	}
+}
+
+func floatSetter(field func(s *Settings) *float64) func(s *Settings, value string) error {
+	return func(s *Settings, value string) error {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*field(s) = number
+		return nil
+	}
+}
+
+// routeSetter updates the default route limits.
+func routeSetter(update func(l *RouteLimits, value string) error) func(s *Settings, value string) error {
+	return func(s *Settings, value string) error {
+		routes := make(map[string]RouteLimits, len(s.Server.Routes))
+		for name, limits := range s.Server.Routes {
+			routes[name] = limits
+		}
+		limits := routes[PolicyDefault]
+		if err := update(&limits, value); err != nil {
+			return err
+		}
+		routes[PolicyDefault] = limits
+		s.Server.Routes = routes
+		return nil
+	}
+}
+
+// policySetter updates the default rate-limit policy.
+func policySetter(update func(p *RateLimitPolicy, value string) error) func(s *Settings, value string) error {
+	return func(s *Settings, value string) error {
+		policies := make(map[string]RateLimitPolicy, len(s.RateLimit.Policies))
+		for name, policy := range s.RateLimit.Policies {
+			policies[name] = policy
+		}
+		policy := policies[PolicyDefault]
+		if err := update(&policy, value); err != nil {
+			return err
+		}
+		policies[PolicyDefault] = policy
+		s.RateLimit.Policies = policies
+		return nil
+	}
+}
@@ -0,0 +1,406 @@
+// Package settings defines the typed settings of the server and loads them from an optional YAML
+// file, environment variables and command-line flags.
+//
+// Every source overrides the previous one: the defaults are overridden by the file, the file by the
+// environment and the environment by the flags. Load validates the result, so main can refuse to start
+// with settings which would only fail later, e.g. a TLS certificate without its key.
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	// StorageConsul stores configurations in the Consul key-value store, the only backend so far
+	StorageConsul = "consul"
+)
+
+// The ways the server runs the migrations of the keyspace at startup.
+const (
+	// MigrationsApply migrates the store in the background and reports not ready until it is done
+	MigrationsApply = "apply"
+	// MigrationsDryRun only logs what the migrations would change
+	MigrationsDryRun = "dry-run"
+	// MigrationsOff leaves the migrations to the admin API
+	MigrationsOff = "off"
+)
+
+// The rate-limit policies the routes are assigned to. Routes whose policy isn't configured share the
+// limiter of the default policy.
+const (
+	PolicyDefault = "default"
+	PolicyRead    = "read"
+	PolicyWrite   = "write"
+	PolicyAdmin   = "admin"
+)
+
+type Settings struct {
+	Server    ServerSettings    `yaml:"server"`
+	TLS       TLSSettings       `yaml:"tls"`
+	Storage   StorageSettings   `yaml:"storage"`
+	RateLimit RateLimitSettings `yaml:"rateLimit"`
+	Log       LogSettings       `yaml:"log"`
+	Tracing   TracingSettings   `yaml:"tracing"`
+	Auth      AuthSettings      `yaml:"auth"`
+	Secrets   SecretsSettings   `yaml:"secrets"`
+	Scheduler SchedulerSettings `yaml:"scheduler"`
+	// ChangeRequests configures the review of changes to groups
+	ChangeRequests ChangeRequestSettings `yaml:"changeRequests"`
+	Trash          TrashSettings         `yaml:"trash"`
+}
+
+type ServerSettings struct {
+	Address           string        `yaml:"address"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	ReadTimeout       time.Duration `yaml:"readTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+	// ShutdownDelay is how long the server keeps serving after SIGINT or SIGTERM while reporting not
+	// ready, so load balancers stop routing to it first
+	ShutdownDelay time.Duration `yaml:"shutdownDelay"`
+	// ShutdownTimeout is how long in-flight requests are given to finish on SIGINT or SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	// Routes are indexed by the policy name like the rate-limit policies: default, read, write or
+	// admin. Policies which aren't set use the default limits
+	Routes map[string]RouteLimits `yaml:"routes"`
+}
+
+// RouteLimits bound the requests of the routes of a policy. Bodies larger than MaxBodyBytes are
+// rejected with 413 and handlers are cancelled after Timeout, 0 for no limit.
+type RouteLimits struct {
+	MaxBodyBytes int64         `yaml:"maxBodyBytes"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+// Client certificate verification modes.
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// TLSSettings enable HTTPS when both the certificate and its key are set.
+type TLSSettings struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// ClientCAFile holds the CAs client certificates are verified against
+	ClientCAFile string `yaml:"clientCAFile"`
+	// ClientAuth is none, optional (verify a certificate if the client sends one) or require
+	ClientAuth string `yaml:"clientAuth"`
+	// ReloadInterval is how often the files are checked for changes, 0 disables the reload
+	ReloadInterval time.Duration `yaml:"reloadInterval"`
+}
+
+// Enabled reports whether the server serves HTTPS.
+func (t TLSSettings) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+type StorageSettings struct {
+	Backend string         `yaml:"backend"`
+	Consul  ConsulSettings `yaml:"consul"`
+	// Migrations is apply, dry-run or off
+	Migrations string        `yaml:"migrations"`
+	Cache      CacheSettings `yaml:"cache"`
+}
+
+// CacheSettings keep the configs and groups in memory, kept up to date by Consul blocking queries.
+type CacheSettings struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxStale is how long after the last answer from Consul the copy is still served, it must be
+	// longer than WaitTime, the longest Consul waits before answering a blocking query
+	MaxStale time.Duration `yaml:"maxStale"`
+	WaitTime time.Duration `yaml:"waitTime"`
+}
+
+// ConsulSettings left empty fall back to the Consul defaults, which read the CONSUL_HTTP_* environment
+// variables.
+type ConsulSettings struct {
+	Address    string `yaml:"address"`
+	Scheme     string `yaml:"scheme"`
+	Datacenter string `yaml:"datacenter"`
+	Token      string `yaml:"token"`
+	// ReadTimeout and WriteTimeout bound every read and write sent to Consul, also for requests with
+	// a longer deadline. 0 leaves them bounded by the request only
+	ReadTimeout  time.Duration `yaml:"readTimeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout"`
+}
+
+type RateLimitSettings struct {
+	Enabled bool `yaml:"enabled"`
+	// Policies are indexed by the policy name: default, read, write or admin
+	Policies map[string]RateLimitPolicy `yaml:"policies"`
+}
+
+// RateLimitPolicy is a token bucket refilled at RequestsPerSecond and holding at most Burst requests.
+type RateLimitPolicy struct {
+	RequestsPerSecond float64 `yaml:"requestsPerSecond"`
+	Burst             int     `yaml:"burst"`
+}
+
+type LogSettings struct {
+	// Level is one of debug, info, warn or error
+	Level string `yaml:"level"`
+	// Format is json or text
+	Format string `yaml:"format"`
+}
+
+// Trace exporters.
+const (
+	TraceExporterNone   = "none"
+	TraceExporterStdout = "stdout"
+	TraceExporterOTLP   = "otlp"
+)
+
+type TracingSettings struct {
+	// Exporter is none, stdout or otlp
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the host:port of the OTLP HTTP collector
+	Endpoint string `yaml:"endpoint"`
+	// Insecure sends spans to the collector over plain HTTP
+	Insecure bool `yaml:"insecure"`
+	// SampleRatio is the share of new traces which are recorded, traces started by callers follow
+	// their sampling decision
+	SampleRatio float64 `yaml:"sampleRatio"`
+	ServiceName string  `yaml:"serviceName"`
+}
+
+type AuthSettings struct {
+	// TokensFile lists the API tokens identifying callers, without it every caller is anonymous
+	TokensFile string `yaml:"tokensFile"`
+}
+
+type SecretsSettings struct {
+	// KeyringFile holds the keys encrypting secret params, without it secret params are rejected
+	KeyringFile string `yaml:"keyringFile"`
+}
+
+type SchedulerSettings struct {
+	// Interval is how often configs are activated and expired configs deleted, 0 disables the scheduler
+	Interval time.Duration `yaml:"interval"`
+}
+
+type ChangeRequestSettings struct {
+	// Approvals is how many reviewers must approve a change request before it is applied
+	Approvals int `yaml:"approvals"`
+}
+
+type TrashSettings struct {
+	// Retention is how long deleted configs and groups can be restored before they are purged
+	Retention time.Duration `yaml:"retention"`
+	// PurgeInterval is how often entries past their retention are purged, 0 disables the purger
+	PurgeInterval time.Duration `yaml:"purgeInterval"`
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() Settings {
+	return Settings{
+		Server: ServerSettings{
+			Address:           "0.0.0.0:8000",
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+			Routes: map[string]RouteLimits{
+				PolicyDefault: {MaxBodyBytes: 1 << 20, Timeout: 30 * time.Second},
+				// Imports carry whole archives
+				PolicyAdmin: {MaxBodyBytes: 64 << 20, Timeout: 2 * time.Minute},
+			},
+		},
+		TLS: TLSSettings{ClientAuth: ClientAuthNone, ReloadInterval: 30 * time.Second},
+		Storage: StorageSettings{
+			Backend:    StorageConsul,
+			Migrations: MigrationsApply,
+			Consul: ConsulSettings{
+				ReadTimeout:  5 * time.Second,
+				WriteTimeout: 10 * time.Second,
+			},
+			Cache: CacheSettings{MaxStale: time.Minute, WaitTime: 30 * time.Second},
+		},
+		RateLimit: RateLimitSettings{
+			Enabled: true,
+			Policies: map[string]RateLimitPolicy{
+				PolicyDefault: {RequestsPerSecond: 1, Burst: 5},
+			},
+		},
+		Log:            LogSettings{Level: "info", Format: "json"},
+		Scheduler:      SchedulerSettings{Interval: 30 * time.Second},
+		ChangeRequests: ChangeRequestSettings{Approvals: 1},
+		Trash:          TrashSettings{Retention: 30 * 24 * time.Hour, PurgeInterval: time.Hour},
+		Tracing: TracingSettings{
+			Exporter:    TraceExporterNone,
+			SampleRatio: 1,
+			ServiceName: "config-service",
+		},
+	}
+}
+
+// Validate reports every invalid setting at once.
+func (s Settings) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(s.Server.Address); err != nil {
+		errs = append(errs, fmt.Errorf("server.address: %w", err))
+	}
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"server.readHeaderTimeout", s.Server.ReadHeaderTimeout},
+		{"server.readTimeout", s.Server.ReadTimeout},
+		{"server.writeTimeout", s.Server.WriteTimeout},
+		{"server.idleTimeout", s.Server.IdleTimeout},
+		{"server.shutdownDelay", s.Server.ShutdownDelay},
+		{"storage.consul.readTimeout", s.Storage.Consul.ReadTimeout},
+		{"storage.consul.writeTimeout", s.Storage.Consul.WriteTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value < 0 {
+			errs = append(errs, fmt.Errorf("%s can't be negative", timeout.name))
+		}
+	}
+	if s.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdownTimeout must be positive"))
+	}
+	if _, ok := s.Server.Routes[PolicyDefault]; !ok {
+		errs = append(errs, errors.New("server.routes must define the default policy"))
+	}
+	for name, limits := range s.Server.Routes {
+		switch name {
+		case PolicyDefault, PolicyRead, PolicyWrite, PolicyAdmin:
+		default:
+			errs = append(errs, fmt.Errorf("server.routes.%s is not a known policy. Expected default, read, write or admin", name))
+			continue
+		}
+		if limits.MaxBodyBytes <= 0 {
+			errs = append(errs, fmt.Errorf("server.routes.%s.maxBodyBytes must be positive", name))
+		}
+		if limits.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("server.routes.%s.timeout can't be negative", name))
+		}
+	}
+
+	if s.TLS.Enabled() {
+		if s.TLS.CertFile == "" || s.TLS.KeyFile == "" {
+			errs = append(errs, errors.New("tls.certFile and tls.keyFile must be set together"))
+		}
+		errs = append(errs, fileExists("tls.certFile", s.TLS.CertFile), fileExists("tls.keyFile", s.TLS.KeyFile))
+	}
+	switch s.TLS.ClientAuth {
+	case ClientAuthNone:
+	case ClientAuthOptional, ClientAuthRequire:
+		if !s.TLS.Enabled() {
+			errs = append(errs, errors.New("tls.clientAuth requires tls.certFile and tls.keyFile"))
+		}
+		if s.TLS.ClientCAFile == "" {
+			errs = append(errs, errors.New("tls.clientAuth requires tls.clientCAFile"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tls.clientAuth %q is not valid. Expected none, optional or require", s.TLS.ClientAuth))
+	}
+	errs = append(errs, fileExists("tls.clientCAFile", s.TLS.ClientCAFile))
+	if s.TLS.ReloadInterval < 0 {
+		errs = append(errs, errors.New("tls.reloadInterval can't be negative"))
+	}
+
+	if s.Storage.Backend != StorageConsul {
+		errs = append(errs, fmt.Errorf("storage.backend %q is not supported. Expected %s", s.Storage.Backend, StorageConsul))
+	}
+	switch s.Storage.Migrations {
+	case MigrationsApply, MigrationsDryRun, MigrationsOff:
+	default:
+		errs = append(errs, fmt.Errorf("storage.migrations %q is not valid. Expected apply, dry-run or off", s.Storage.Migrations))
+	}
+	if s.Storage.Cache.Enabled {
+		if s.Storage.Cache.WaitTime <= 0 {
+			errs = append(errs, errors.New("storage.cache.waitTime must be positive"))
+		}
+		if s.Storage.Cache.MaxStale <= s.Storage.Cache.WaitTime {
+			errs = append(errs, errors.New("storage.cache.maxStale must be longer than storage.cache.waitTime"))
+		}
+	}
+
+	if s.RateLimit.Enabled {
+		if _, ok := s.RateLimit.Policies[PolicyDefault]; !ok {
+			errs = append(errs, errors.New("rateLimit.policies must define the default policy"))
+		}
+		for name, policy := range s.RateLimit.Policies {
+			switch name {
+			case PolicyDefault, PolicyRead, PolicyWrite, PolicyAdmin:
+			default:
+				errs = append(errs, fmt.Errorf("rateLimit.policies.%s is not a known policy. Expected default, read, write or admin", name))
+				continue
+			}
+			if policy.RequestsPerSecond <= 0 {
+				errs = append(errs, fmt.Errorf("rateLimit.policies.%s.requestsPerSecond must be positive", name))
+			}
+			if policy.Burst < 1 {
+				errs = append(errs, fmt.Errorf("rateLimit.policies.%s.burst must be at least 1", name))
+			}
+		}
+	}
+
+	if _, err := s.Log.SlogLevel(); err != nil {
+		errs = append(errs, err)
+	}
+	if s.Log.Format != "json" && s.Log.Format != "text" {
+		errs = append(errs, fmt.Errorf("log.format %q is not valid. Expected json or text", s.Log.Format))
+	}
+
+	switch s.Tracing.Exporter {
+	case TraceExporterNone, TraceExporterStdout, TraceExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter %q is not valid. Expected none, stdout or otlp", s.Tracing.Exporter))
+	}
+	if s.Tracing.SampleRatio < 0 || s.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sampleRatio must be between 0 and 1"))
+	}
+	if s.Tracing.ServiceName == "" {
+		errs = append(errs, errors.New("tracing.serviceName can't be empty"))
+	}
+
+	if s.Auth.TokensFile != "" {
+		errs = append(errs, fileExists("auth.tokensFile", s.Auth.TokensFile))
+	}
+	if s.Secrets.KeyringFile != "" {
+		errs = append(errs, fileExists("secrets.keyringFile", s.Secrets.KeyringFile))
+	}
+	if s.Scheduler.Interval < 0 {
+		errs = append(errs, errors.New("scheduler.interval can't be negative"))
+	}
+	if s.ChangeRequests.Approvals < 1 {
+		errs = append(errs, errors.New("changeRequests.approvals must be at least 1"))
+	}
+	if s.Trash.Retention <= 0 {
+		errs = append(errs, errors.New("trash.retention must be positive"))
+	}
+	if s.Trash.PurgeInterval < 0 {
+		errs = append(errs, errors.New("trash.purgeInterval can't be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// SlogLevel parses the log level.
+func (l LogSettings) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		return 0, fmt.Errorf("log.level %q is not valid. Expected debug, info, warn or error", l.Level)
+	}
+	return level, nil
+}
+
+func fileExists(name string, path string) error {
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package settings
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(name string) string { return values[name] }
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	s, err := Load(nil, env(nil), io.Discard)
+	assert.NoError(t, err)
+	assert.Equal(t, Default(), s)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "settings.yaml")
+	err := os.WriteFile(file, []byte(`
+server:
+  address: 127.0.0.1:9000
+  shutdownTimeout: 5s
+rateLimit:
+  policies:
+    admin: {requestsPerSecond: 0.5, burst: 1}
+log:
+  level: debug
+`), 0o600)
+	assert.NoError(t, err)
+
+	s, err := Load(
+		[]string{"--log-level", "error", "--rate-limit-burst", "20"},
+		env(map[string]string{"CONFIG_FILE": file, "CONFIG_LOG_LEVEL": "warn", "CONFIG_ADDR": "127.0.0.1:9100"}),
+		io.Discard,
+	)
+	assert.NoError(t, err)
+	// The environment overrides the file and the flags override the environment
+	assert.Equal(t, "127.0.0.1:9100", s.Server.Address)
+	assert.Equal(t, 5*time.Second, s.Server.ShutdownTimeout)
+	assert.Equal(t, 30*time.Second, s.Server.ReadTimeout)
+	assert.Equal(t, "error", s.Log.Level)
+	// Policies from the file are merged with the default one
+	assert.Equal(t, RateLimitPolicy{RequestsPerSecond: 1, Burst: 20}, s.RateLimit.Policies[PolicyDefault])
+	assert.Equal(t, RateLimitPolicy{RequestsPerSecond: 0.5, Burst: 1}, s.RateLimit.Policies[PolicyAdmin])
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "settings.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("server:\n  adress: :8000\n"), 0o600))
+	_, err := Load([]string{"--config", file}, env(nil), io.Discard)
+	assert.ErrorContains(t, err, "adress")
+
+	_, err = Load([]string{"--read-timeout", "soon"}, env(nil), io.Discard)
+	assert.ErrorContains(t, err, "--read-timeout")
+
+	_, err = Load(nil, env(map[string]string{
+		"CONFIG_ADDR":                     "no-port",
+		"CONFIG_TLS_CERT":                 "cert.pem",
+		"CONFIG_STORAGE_BACKEND":          "etcd",
+		"CONFIG_RATE_LIMIT_RPS":           "0",
+		"CONFIG_LOG_LEVEL":                "loud",
+		"CONFIG_TRACE_EXPORTER":           "jaeger",
+		"CONFIG_MIGRATIONS":               "later",
+		"CONFIG_CACHE":                    "true",
+		"CONFIG_CACHE_MAX_STALE":          "10s",
+		"CONFIG_CHANGE_REQUEST_APPROVALS": "0",
+		"CONFIG_TRASH_RETENTION":          "0s",
+	}), io.Discard)
+	assert.ErrorContains(t, err, "server.address")
+	assert.ErrorContains(t, err, "tls.certFile and tls.keyFile must be set together")
+	assert.ErrorContains(t, err, `storage.backend "etcd"`)
+	assert.ErrorContains(t, err, "rateLimit.policies.default.requestsPerSecond")
+	assert.ErrorContains(t, err, `log.level "loud"`)
+	assert.ErrorContains(t, err, `tracing.exporter "jaeger"`)
+	assert.ErrorContains(t, err, `storage.migrations "later"`)
+	assert.ErrorContains(t, err, "storage.cache.maxStale must be longer than storage.cache.waitTime")
+	assert.ErrorContains(t, err, "changeRequests.approvals must be at least 1")
+	assert.ErrorContains(t, err, "trash.retention must be positive")
+
+	// Rate-limit policies aren't validated while rate limiting is disabled
+	_, err = Load([]string{"--rate-limit=false", "--rate-limit-rps", "0"}, env(nil), io.Discard)
+	assert.NoError(t, err)
+}
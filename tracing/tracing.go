@@ -0,0 +1,89 @@
+// Package tracing sets up OpenTelemetry tracing and provides the helpers the handlers, services,
+// repositories and the database use to start their spans.
+//
+// Trace context is read from and written to W3C traceparent and baggage headers. Spans are exported
+// to an OTLP collector over HTTP or printed to stdout for local use, and dropped when no exporter is
+// configured.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"project/settings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "project"
+
+// Setup installs the global tracer provider and propagator. The returned func flushes the spans still
+// buffered and must be called on shutdown.
+func Setup(ctx context.Context, tracingSettings settings.TracingSettings) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch tracingSettings.Exporter {
+	case settings.TraceExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case settings.TraceExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case settings.TraceExporterOTLP:
+		// Without an endpoint the exporter reads OTEL_EXPORTER_OTLP_* or uses localhost:4318
+		var options []otlptracehttp.Option
+		if tracingSettings.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(tracingSettings.Endpoint))
+		}
+		if tracingSettings.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", tracingSettings.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(tracingSettings.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(tracingSettings.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of the span in the context, if any.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// StartServer starts the span of a request handled by the server, as a child of the span of the
+// caller extracted into the context, if any.
+func StartServer(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attributes...))
+}
+
+// End ends the span, marking it failed if err isn't nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}